@@ -0,0 +1,9 @@
+// Package api holds the contracts of the service: the OpenAPI document of the HTTP API and the protobuf definitions of gRPC
+package api
+
+import _ "embed"
+
+// OpenAPI is the OpenAPI 3.1 document served at /api/openapi.json and used to validate requests
+//
+//go:embed openapi.json
+var OpenAPI []byte
@@ -17,7 +17,8 @@ CREATE TABLE IF NOT EXISTS feature (
     id BIGSERIAL PRIMARY KEY,
     feature_data TEXT
 
-        CONSTRAINT feature_data_length CHECK (char_length(feature_data) <= 255)
+        CONSTRAINT feature_data_length CHECK (char_length(feature_data) <= 255),
+    content_schema JSONB
     
 );
 
@@ -25,16 +26,25 @@ CREATE TABLE IF NOT EXISTS banner (
       id BIGSERIAL PRIMARY KEY,
       feature_id BIGSERIAL REFERENCES feature (id)
 	NOT NULL,
-      title TEXT
+      content JSONB
         NOT NULL
-        CONSTRAINT title_length CHECK (char_length(title) <= 255),
-      banner_data TEXT
-      CONSTRAINT banner_data_length CHECK (char_length(title) <= 3000),
-      url TEXT
-        NOT NULL
-        CONSTRAINT banner_title_length CHECK (char_length(title) <= 255),
+        CONSTRAINT content_is_object CHECK (jsonb_typeof(content) = 'object'),
       is_active BOOLEAN DEFAULT ('true')
         NOT NULL,
+      active_from TIMESTAMP,
+      active_until TIMESTAMP,
+      weight BIGINT DEFAULT (0)
+        NOT NULL
+        CONSTRAINT weight_not_negative CHECK (weight >= 0),
+      impression_cap BIGINT DEFAULT (0)
+        NOT NULL
+        CONSTRAINT impression_cap_not_negative CHECK (impression_cap >= 0),
+      version BIGINT DEFAULT (1)
+        NOT NULL,
+      status TEXT DEFAULT ('draft')
+        NOT NULL
+        CONSTRAINT banner_status CHECK (status IN ('draft', 'in_review', 'approved', 'published', 'archived')),
+      deleted_at TIMESTAMP,
       create_time TIMESTAMP
         NOT NULL,
       update_time TIMESTAMP
@@ -45,6 +55,9 @@ CREATE TABLE IF NOT EXISTS banner (
 
 );
 
+-- баннер фичи по умолчанию отдаётся тегам без собственного баннера
+ALTER TABLE feature ADD COLUMN IF NOT EXISTS default_banner_id BIGINT REFERENCES banner (id) ON DELETE SET NULL;
+
 CREATE TABLE IF NOT EXISTS tag (
     id BIGSERIAL PRIMARY KEY,
     tag_data TEXT
@@ -57,8 +70,157 @@ CREATE TABLE IF NOT EXISTS banner_tag (
     tag_id BIGSERIAL REFERENCES tag (id),
     banner_id BIGSERIAL REFERENCES banner(id),
     feature_id BIGSERIAL REFERENCES feature(id),
-	  UNIQUE (tag_id, feature_id)
+	  UNIQUE (banner_id, tag_id)
+);
+-- пара (tag_id, feature_id) больше не уникальна: её могут делить варианты эксперимента,
+-- правило проверяется в BannerRepo при записи баннера
+CREATE INDEX IF NOT EXISTS banner_tag_tag_feature ON banner_tag (tag_id, feature_id);
+CREATE INDEX IF NOT EXISTS banner_deleted_at ON banner (deleted_at) WHERE deleted_at IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS banner_revision (
+    id BIGSERIAL PRIMARY KEY,
+    banner_id BIGINT REFERENCES banner (id) ON DELETE CASCADE
+        NOT NULL,
+    revision BIGINT
+        NOT NULL,
+    feature_id BIGINT
+        NOT NULL,
+    content JSONB
+        NOT NULL,
+    tag_ids BIGINT[]
+        NOT NULL,
+    is_active BOOLEAN
+        NOT NULL,
+    active_from TIMESTAMP,
+    active_until TIMESTAMP,
+    weight BIGINT
+        NOT NULL,
+    impression_cap BIGINT DEFAULT (0)
+        NOT NULL,
+    author TEXT
+        NOT NULL,
+    create_time TIMESTAMP
+        NOT NULL,
+    UNIQUE (banner_id, revision)
+);
+
+CREATE TABLE IF NOT EXISTS banner_transition (
+    id BIGSERIAL PRIMARY KEY,
+    banner_id BIGINT REFERENCES banner (id) ON DELETE CASCADE
+        NOT NULL,
+    action TEXT
+        NOT NULL,
+    from_status TEXT
+        NOT NULL,
+    to_status TEXT
+        NOT NULL,
+    actor TEXT
+        NOT NULL,
+    create_time TIMESTAMP
+        NOT NULL
+);
+CREATE INDEX IF NOT EXISTS banner_transition_banner ON banner_transition (banner_id);
+
+CREATE TABLE IF NOT EXISTS job (
+    id BIGSERIAL PRIMARY KEY,
+    kind TEXT
+        NOT NULL,
+    status TEXT
+        NOT NULL,
+    feature_id BIGINT DEFAULT (0)
+        NOT NULL,
+    tag_id BIGINT DEFAULT (0)
+        NOT NULL,
+    actor TEXT DEFAULT ('')
+        NOT NULL,
+    total BIGINT DEFAULT (0)
+        NOT NULL,
+    processed BIGINT DEFAULT (0)
+        NOT NULL,
+    error TEXT DEFAULT ('')
+        NOT NULL,
+    create_time TIMESTAMP
+        NOT NULL,
+    update_time TIMESTAMP
+        NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    actor TEXT
+        NOT NULL,
+    action TEXT
+        NOT NULL,
+    entity TEXT
+        NOT NULL,
+    -- без внешнего ключа: запись должна пережить удаление сущности
+    entity_id BIGINT
+        NOT NULL,
+    before JSONB,
+    after JSONB,
+    create_time TIMESTAMP
+        NOT NULL
+);
+CREATE INDEX IF NOT EXISTS audit_log_entity ON audit_log (entity, entity_id);
+CREATE INDEX IF NOT EXISTS audit_log_actor ON audit_log (actor);
+CREATE INDEX IF NOT EXISTS audit_log_create_time ON audit_log (create_time);
+
+-- журнал только дополняется
+CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+DROP TRIGGER IF EXISTS audit_log_append_only ON audit_log;
+CREATE TRIGGER audit_log_append_only BEFORE UPDATE OR DELETE ON audit_log
+    FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
+
+-- показы и клики по дням, воркер переносит их сюда из redis пачками
+CREATE TABLE IF NOT EXISTS banner_stats (
+    banner_id BIGINT REFERENCES banner (id) ON DELETE CASCADE
+        NOT NULL,
+    day DATE
+        NOT NULL,
+    impressions BIGINT DEFAULT (0)
+        NOT NULL,
+    clicks BIGINT DEFAULT (0)
+        NOT NULL,
+    PRIMARY KEY (banner_id, day)
+);
+
+-- вебхуки и очередь их событий; события пишутся в одной транзакции с изменением баннера
+CREATE TABLE IF NOT EXISTS webhook (
+    id BIGSERIAL PRIMARY KEY,
+    url TEXT
+        NOT NULL,
+    secret TEXT
+        NOT NULL,
+    events TEXT[]
+        NOT NULL,
+    create_time TIMESTAMP
+        NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS webhook_outbox (
+    id BIGSERIAL PRIMARY KEY,
+    webhook_id BIGINT REFERENCES webhook (id) ON DELETE CASCADE
+        NOT NULL,
+    event TEXT
+        NOT NULL,
+    payload JSONB
+        NOT NULL,
+    status TEXT DEFAULT ('pending')
+        NOT NULL
+        CONSTRAINT webhook_outbox_status CHECK (status IN ('pending', 'dead')),
+    attempts BIGINT DEFAULT (0)
+        NOT NULL,
+    last_error TEXT,
+    next_attempt TIMESTAMP
+        NOT NULL,
+    create_time TIMESTAMP
+        NOT NULL
 );
+CREATE INDEX IF NOT EXISTS webhook_outbox_pending ON webhook_outbox (next_attempt) WHERE status = 'pending';
 
 --password: testuser--
 INSERT INTO users(username, password_hash, create_time, is_admin) 
@@ -0,0 +1,38 @@
+-- ревизии баннеров для отката; у существующих баннеров текущее состояние становится ревизией 1,
+-- иначе история у них пустая и GET /api/banner/{id}/versions отвечает 404
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS banner_revision (
+    id BIGSERIAL PRIMARY KEY,
+    banner_id BIGINT REFERENCES banner (id) ON DELETE CASCADE
+        NOT NULL,
+    revision BIGINT
+        NOT NULL,
+    feature_id BIGINT
+        NOT NULL,
+    title TEXT
+        NOT NULL,
+    banner_data TEXT,
+    url TEXT
+        NOT NULL,
+    tag_ids BIGINT[]
+        NOT NULL,
+    is_active BOOLEAN
+        NOT NULL,
+    author TEXT
+        NOT NULL,
+    create_time TIMESTAMP
+        NOT NULL,
+    UNIQUE (banner_id, revision)
+);
+
+INSERT INTO banner_revision (banner_id, revision, feature_id, title, banner_data, url, tag_ids, is_active, author, create_time)
+    SELECT b.id, 1, b.feature_id, b.title, b.banner_data, b.url,
+           coalesce(array_agg(bt.tag_id ORDER BY bt.tag_id) FILTER (WHERE bt.tag_id IS NOT NULL), '{}'),
+           b.is_active, 'migration', b.update_time
+    FROM banner b
+    LEFT JOIN banner_tag bt ON bt.banner_id = b.id
+    WHERE NOT EXISTS (SELECT 1 FROM banner_revision r WHERE r.banner_id = b.id)
+    GROUP BY b.id;
+
+COMMIT;
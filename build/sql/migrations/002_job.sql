@@ -0,0 +1,22 @@
+-- фоновые задачи, например массовое удаление баннеров
+CREATE TABLE IF NOT EXISTS job (
+    id BIGSERIAL PRIMARY KEY,
+    kind TEXT
+        NOT NULL,
+    status TEXT
+        NOT NULL,
+    feature_id BIGINT DEFAULT (0)
+        NOT NULL,
+    tag_id BIGINT DEFAULT (0)
+        NOT NULL,
+    total BIGINT DEFAULT (0)
+        NOT NULL,
+    processed BIGINT DEFAULT (0)
+        NOT NULL,
+    error TEXT DEFAULT ('')
+        NOT NULL,
+    create_time TIMESTAMP
+        NOT NULL,
+    update_time TIMESTAMP
+        NOT NULL
+);
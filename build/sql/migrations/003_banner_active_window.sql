@@ -0,0 +1,5 @@
+-- окно показа баннера, NULL - без ограничения с этой стороны
+ALTER TABLE banner ADD COLUMN IF NOT EXISTS active_from TIMESTAMP;
+ALTER TABLE banner ADD COLUMN IF NOT EXISTS active_until TIMESTAMP;
+ALTER TABLE banner_revision ADD COLUMN IF NOT EXISTS active_from TIMESTAMP;
+ALTER TABLE banner_revision ADD COLUMN IF NOT EXISTS active_until TIMESTAMP;
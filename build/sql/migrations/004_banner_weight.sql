@@ -0,0 +1,14 @@
+-- пару (tag_id, feature_id) могут делить варианты эксперимента с весами,
+-- правило проверяется в BannerRepo при записи баннера
+BEGIN;
+
+ALTER TABLE banner ADD COLUMN IF NOT EXISTS weight BIGINT DEFAULT (0) NOT NULL
+    CONSTRAINT weight_not_negative CHECK (weight >= 0);
+ALTER TABLE banner_revision ADD COLUMN IF NOT EXISTS weight BIGINT DEFAULT (0) NOT NULL;
+ALTER TABLE banner_revision ALTER COLUMN weight DROP DEFAULT;
+
+ALTER TABLE banner_tag DROP CONSTRAINT IF EXISTS banner_tag_tag_id_feature_id_key;
+ALTER TABLE banner_tag ADD CONSTRAINT banner_tag_banner_id_tag_id_key UNIQUE (banner_id, tag_id);
+CREATE INDEX IF NOT EXISTS banner_tag_tag_feature ON banner_tag (tag_id, feature_id);
+
+COMMIT;
@@ -0,0 +1,20 @@
+-- баннеры хранят произвольный JSON вместо фиксированных title/banner_data/url,
+-- старые поля переносятся в объект {"title", "text", "url"}
+BEGIN;
+
+ALTER TABLE banner ADD COLUMN IF NOT EXISTS content JSONB;
+UPDATE banner SET content = jsonb_build_object('title', title, 'text', banner_data, 'url', url)
+    WHERE content IS NULL;
+ALTER TABLE banner ALTER COLUMN content SET NOT NULL;
+ALTER TABLE banner ADD CONSTRAINT content_is_object CHECK (jsonb_typeof(content) = 'object');
+ALTER TABLE banner DROP COLUMN title, DROP COLUMN banner_data, DROP COLUMN url;
+
+ALTER TABLE banner_revision ADD COLUMN IF NOT EXISTS content JSONB;
+UPDATE banner_revision SET content = jsonb_build_object('title', title, 'text', banner_data, 'url', url)
+    WHERE content IS NULL;
+ALTER TABLE banner_revision ALTER COLUMN content SET NOT NULL;
+ALTER TABLE banner_revision DROP COLUMN title, DROP COLUMN banner_data, DROP COLUMN url;
+
+ALTER TABLE feature ADD COLUMN IF NOT EXISTS content_schema JSONB;
+
+COMMIT;
@@ -0,0 +1,2 @@
+-- счётчик версий баннера для If-Match, растёт при каждом изменении
+ALTER TABLE banner ADD COLUMN IF NOT EXISTS version BIGINT DEFAULT (1) NOT NULL;
@@ -0,0 +1,26 @@
+-- статусы баннеров: уже существующие баннеры остаются в выдаче как опубликованные,
+-- новые создаются черновиками
+BEGIN;
+
+ALTER TABLE banner ADD COLUMN IF NOT EXISTS status TEXT DEFAULT ('published') NOT NULL
+    CONSTRAINT banner_status CHECK (status IN ('draft', 'in_review', 'approved', 'published', 'archived'));
+ALTER TABLE banner ALTER COLUMN status SET DEFAULT ('draft');
+
+CREATE TABLE IF NOT EXISTS banner_transition (
+    id BIGSERIAL PRIMARY KEY,
+    banner_id BIGINT REFERENCES banner (id) ON DELETE CASCADE
+        NOT NULL,
+    action TEXT
+        NOT NULL,
+    from_status TEXT
+        NOT NULL,
+    to_status TEXT
+        NOT NULL,
+    actor TEXT
+        NOT NULL,
+    create_time TIMESTAMP
+        NOT NULL
+);
+CREATE INDEX IF NOT EXISTS banner_transition_banner ON banner_transition (banner_id);
+
+COMMIT;
@@ -0,0 +1,34 @@
+-- журнал действий админов
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    actor TEXT
+        NOT NULL,
+    action TEXT
+        NOT NULL,
+    entity TEXT
+        NOT NULL,
+    -- без внешнего ключа: запись должна пережить удаление сущности
+    entity_id BIGINT
+        NOT NULL,
+    before JSONB,
+    after JSONB,
+    create_time TIMESTAMP
+        NOT NULL
+);
+CREATE INDEX IF NOT EXISTS audit_log_entity ON audit_log (entity, entity_id);
+CREATE INDEX IF NOT EXISTS audit_log_actor ON audit_log (actor);
+CREATE INDEX IF NOT EXISTS audit_log_create_time ON audit_log (create_time);
+
+-- журнал только дополняется
+CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+DROP TRIGGER IF EXISTS audit_log_append_only ON audit_log;
+CREATE TRIGGER audit_log_append_only BEFORE UPDATE OR DELETE ON audit_log
+    FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
+
+COMMIT;
@@ -0,0 +1,3 @@
+-- удалённые баннеры лежат в корзине до очистки воркером
+ALTER TABLE banner ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+CREATE INDEX IF NOT EXISTS banner_deleted_at ON banner (deleted_at) WHERE deleted_at IS NOT NULL;
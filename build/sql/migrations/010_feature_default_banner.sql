@@ -0,0 +1,2 @@
+-- баннер фичи по умолчанию отдаётся тегам без собственного баннера
+ALTER TABLE feature ADD COLUMN IF NOT EXISTS default_banner_id BIGINT REFERENCES banner (id) ON DELETE SET NULL;
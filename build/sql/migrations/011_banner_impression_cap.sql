@@ -0,0 +1,4 @@
+-- не больше impression_cap показов баннера одному пользователю в сутки, 0 - без ограничения
+ALTER TABLE banner ADD COLUMN IF NOT EXISTS impression_cap BIGINT DEFAULT (0) NOT NULL
+    CONSTRAINT impression_cap_not_negative CHECK (impression_cap >= 0);
+ALTER TABLE banner_revision ADD COLUMN IF NOT EXISTS impression_cap BIGINT DEFAULT (0) NOT NULL;
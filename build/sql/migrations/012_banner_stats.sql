@@ -0,0 +1,12 @@
+-- показы и клики по дням, воркер переносит их сюда из redis пачками
+CREATE TABLE IF NOT EXISTS banner_stats (
+    banner_id BIGINT REFERENCES banner (id) ON DELETE CASCADE
+        NOT NULL,
+    day DATE
+        NOT NULL,
+    impressions BIGINT DEFAULT (0)
+        NOT NULL,
+    clicks BIGINT DEFAULT (0)
+        NOT NULL,
+    PRIMARY KEY (banner_id, day)
+);
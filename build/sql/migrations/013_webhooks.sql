@@ -0,0 +1,33 @@
+-- вебхуки и очередь их событий; события пишутся в одной транзакции с изменением баннера
+CREATE TABLE IF NOT EXISTS webhook (
+    id BIGSERIAL PRIMARY KEY,
+    url TEXT
+        NOT NULL,
+    secret TEXT
+        NOT NULL,
+    events TEXT[]
+        NOT NULL,
+    create_time TIMESTAMP
+        NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS webhook_outbox (
+    id BIGSERIAL PRIMARY KEY,
+    webhook_id BIGINT REFERENCES webhook (id) ON DELETE CASCADE
+        NOT NULL,
+    event TEXT
+        NOT NULL,
+    payload JSONB
+        NOT NULL,
+    status TEXT DEFAULT ('pending')
+        NOT NULL
+        CONSTRAINT webhook_outbox_status CHECK (status IN ('pending', 'dead')),
+    attempts BIGINT DEFAULT (0)
+        NOT NULL,
+    last_error TEXT,
+    next_attempt TIMESTAMP
+        NOT NULL,
+    create_time TIMESTAMP
+        NOT NULL
+);
+CREATE INDEX IF NOT EXISTS webhook_outbox_pending ON webhook_outbox (next_attempt) WHERE status = 'pending';
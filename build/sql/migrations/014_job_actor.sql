@@ -0,0 +1,2 @@
+-- кто поставил задачу: от его имени воркер пишет журнал изменений
+ALTER TABLE job ADD COLUMN IF NOT EXISTS actor TEXT DEFAULT ('') NOT NULL;
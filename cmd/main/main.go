@@ -3,20 +3,52 @@ package main
 import (
 	"context"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
+	"github.com/Alladan04/avito_test/api"
+	"github.com/Alladan04/avito_test/internal/pb"
+	auditDelivery "github.com/Alladan04/avito_test/internal/pkg/audit/delivery/http"
+	auditRepo "github.com/Alladan04/avito_test/internal/pkg/audit/repo"
+	auditUsecase "github.com/Alladan04/avito_test/internal/pkg/audit/usecase"
+	authGrpc "github.com/Alladan04/avito_test/internal/pkg/auth/delivery/grpc"
 	authDelivery "github.com/Alladan04/avito_test/internal/pkg/auth/delivery/http"
 	authRepo "github.com/Alladan04/avito_test/internal/pkg/auth/repo"
 	authUsecase "github.com/Alladan04/avito_test/internal/pkg/auth/usecase"
+	"github.com/Alladan04/avito_test/internal/pkg/banner"
+	bannerGrpc "github.com/Alladan04/avito_test/internal/pkg/banner/delivery/grpc"
 	bannerDelivery "github.com/Alladan04/avito_test/internal/pkg/banner/delivery/http"
 	bannerRepo "github.com/Alladan04/avito_test/internal/pkg/banner/repo"
 	bannerUsecase "github.com/Alladan04/avito_test/internal/pkg/banner/usecase"
+	bannerWorker "github.com/Alladan04/avito_test/internal/pkg/banner/worker"
+	featureDelivery "github.com/Alladan04/avito_test/internal/pkg/feature/delivery/http"
+	featureRepo "github.com/Alladan04/avito_test/internal/pkg/feature/repo"
+	featureUsecase "github.com/Alladan04/avito_test/internal/pkg/feature/usecase"
+	jobDelivery "github.com/Alladan04/avito_test/internal/pkg/job/delivery/http"
+	jobRepo "github.com/Alladan04/avito_test/internal/pkg/job/repo"
+	jobUsecase "github.com/Alladan04/avito_test/internal/pkg/job/usecase"
 	"github.com/Alladan04/avito_test/internal/pkg/middleware"
+	statsDelivery "github.com/Alladan04/avito_test/internal/pkg/stats/delivery/http"
+	statsRepo "github.com/Alladan04/avito_test/internal/pkg/stats/repo"
+	statsUsecase "github.com/Alladan04/avito_test/internal/pkg/stats/usecase"
+	statsWorker "github.com/Alladan04/avito_test/internal/pkg/stats/worker"
+	tagDelivery "github.com/Alladan04/avito_test/internal/pkg/tag/delivery/http"
+	tagRepo "github.com/Alladan04/avito_test/internal/pkg/tag/repo"
+	tagUsecase "github.com/Alladan04/avito_test/internal/pkg/tag/usecase"
+	webhookDelivery "github.com/Alladan04/avito_test/internal/pkg/webhook/delivery/http"
+	webhookRepo "github.com/Alladan04/avito_test/internal/pkg/webhook/repo"
+	webhookUsecase "github.com/Alladan04/avito_test/internal/pkg/webhook/usecase"
+	webhookWorker "github.com/Alladan04/avito_test/internal/pkg/webhook/worker"
+	workflowDelivery "github.com/Alladan04/avito_test/internal/pkg/workflow/delivery/http"
+	workflowRepo "github.com/Alladan04/avito_test/internal/pkg/workflow/repo"
+	workflowUsecase "github.com/Alladan04/avito_test/internal/pkg/workflow/usecase"
 	"github.com/redis/go-redis/v9"
+	"google.golang.org/grpc"
 
 	"github.com/gorilla/mux"
 	"github.com/jackc/pgx/v4"
@@ -53,18 +85,121 @@ func main() {
 	AuthUsecase := authUsecase.NewAuthUsecase(AuthRepo)
 	AuthDelivery := authDelivery.NewAuthHandler(AuthUsecase)
 
-	CacheRepo := bannerRepo.NewCacheRepo(*redisDB)
+	AuditRepo := auditRepo.NewAuditRepo(db)
+	AuditUsecase := auditUsecase.NewAuditUsecase(AuditRepo)
+	AuditDelivery := auditDelivery.NewAuditHandler(AuditUsecase)
+
+	JobRepo := jobRepo.NewJobRepo(db)
+	JobUsecase := jobUsecase.NewJobUsecase(JobRepo)
+	JobDelivery := jobDelivery.NewJobHandler(JobUsecase)
+
+	CounterRepo := statsRepo.NewCounterRepo(*redisDB)
+	StatsRepo := statsRepo.NewStatsRepo(db)
+	StatsUsecase := statsUsecase.NewStatsUsecase(StatsRepo, CounterRepo)
+	StatsDelivery := statsDelivery.NewStatsHandler(StatsUsecase)
+
+	localCacheSize := bannerRepo.DefaultLocalCacheSize
+	if value := os.Getenv("LOCAL_CACHE_SIZE"); value != "" {
+		localCacheSize, err = strconv.Atoi(value)
+		if err != nil {
+			fmt.Println("wrong LOCAL_CACHE_SIZE:", err)
+			return
+		}
+	}
+	localCacheTTL := bannerRepo.DefaultLocalCacheTTL
+	if value := os.Getenv("LOCAL_CACHE_TTL"); value != "" {
+		localCacheTTL, err = time.ParseDuration(value)
+		if err != nil {
+			fmt.Println("wrong LOCAL_CACHE_TTL:", err)
+			return
+		}
+	}
+	// локальный LRU снимает с redis чтения горячих пар, redis остаётся общим уровнем для всех реплик
+	CacheRepo := bannerRepo.NewTieredCacheRepo(bannerRepo.NewCacheRepo(*redisDB), localCacheSize, localCacheTTL)
 	BannerRepo := bannerRepo.NewBannerRepo(db, *conn)
-	BannerUsecase := bannerUsecase.NewBannerUsecase(BannerRepo, CacheRepo)
+	// с CACHE_REBUILD_LOCK=true истёкшую пару пересобирает одна реплика, остальные ждут её результат в redis
+	var RebuildLock banner.LockRepo
+	if value := os.Getenv("CACHE_REBUILD_LOCK"); value != "" {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			fmt.Println("wrong CACHE_REBUILD_LOCK:", err)
+			return
+		}
+		if enabled {
+			RebuildLock = bannerRepo.NewLockRepo(*redisDB)
+		}
+	}
+	BannerUsecase := bannerUsecase.NewBannerUsecase(BannerRepo, CacheRepo, JobRepo, bannerRepo.NewImpressionRepo(*redisDB), CounterRepo, RebuildLock)
 	BannerDelivery := bannerDelivery.NewBannerHandler(BannerUsecase)
+	UpdatesHub := bannerUsecase.NewUpdatesHub(CacheRepo)
+	StreamDelivery := bannerDelivery.NewStreamHandler(BannerUsecase, UpdatesHub)
+	CacheDelivery := bannerDelivery.NewCacheHandler(CacheRepo)
+
+	FeatureRepo := featureRepo.NewFeatureRepo(db, *conn)
+	FeatureUsecase := featureUsecase.NewFeatureUsecase(FeatureRepo, CacheRepo)
+	FeatureDelivery := featureDelivery.NewFeatureHandler(FeatureUsecase)
+
+	TagRepo := tagRepo.NewTagRepo(db, *conn)
+	TagUsecase := tagUsecase.NewTagUsecase(TagRepo, CacheRepo)
+	TagDelivery := tagDelivery.NewTagHandler(TagUsecase)
+
+	WorkflowRepo := workflowRepo.NewWorkflowRepo(db, *conn)
+	WorkflowUsecase := workflowUsecase.NewWorkflowUsecase(WorkflowRepo, CacheRepo)
+	WorkflowDelivery := workflowDelivery.NewWorkflowHandler(WorkflowUsecase)
+
+	WebhookRepo := webhookRepo.NewWebhookRepo(db)
+	WebhookUsecase := webhookUsecase.NewWebhookUsecase(WebhookRepo)
+	WebhookDelivery := webhookDelivery.NewWebhookHandler(WebhookUsecase)
+
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	// у воркеров свои соединения, чтобы их транзакции не пересекались с транзакциями хендлеров и друг с другом
+	workerConn, err := pgx.Connect(context.Background(), os.Getenv("DATABASE_URL"))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	DeleteWorker := bannerWorker.NewDeleteWorker(JobRepo, bannerRepo.NewBannerRepo(db, *workerConn), CacheRepo)
+	go DeleteWorker.Run(workerCtx)
+	scheduleConn, err := pgx.Connect(context.Background(), os.Getenv("DATABASE_URL"))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	ScheduleWorker := bannerWorker.NewScheduleWorker(bannerRepo.NewBannerRepo(db, *scheduleConn), CacheRepo)
+	go ScheduleWorker.Run(workerCtx)
+	trashRetention := bannerWorker.DefaultTrashRetention
+	if value := os.Getenv("TRASH_RETENTION"); value != "" {
+		trashRetention, err = time.ParseDuration(value)
+		if err != nil {
+			fmt.Println("wrong TRASH_RETENTION:", err)
+			return
+		}
+	}
+	PurgeWorker := bannerWorker.NewPurgeWorker(BannerRepo, trashRetention)
+	go PurgeWorker.Run(workerCtx)
+	go UpdatesHub.Run(workerCtx)
+	FlushWorker := statsWorker.NewFlushWorker(CounterRepo, StatsRepo)
+	go FlushWorker.Run(workerCtx)
+	DeliveryWorker := webhookWorker.NewDeliveryWorker(WebhookRepo, nil)
+	go DeliveryWorker.Run(workerCtx)
+
+	OpenAPIValidator, err := middleware.NewOpenAPIValidator(api.OpenAPI)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
 
 	r := mux.NewRouter().PathPrefix("/api").Subrouter()
+	// запросы, не совпадающие со спецификацией, отклоняются до хендлеров
+	r.Use(OpenAPIValidator.Middleware)
 
 	r.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusNotFound)
 		fmt.Println("not found")
 	})
 	http.Handle("/", r)
+	r.Handle("/openapi.json", http.HandlerFunc(OpenAPIValidator.ServeSpec)).Methods(http.MethodGet, http.MethodOptions)
 	auth := r.PathPrefix("/auth").Subrouter()
 	{
 		auth.Handle("/signup", http.HandlerFunc(AuthDelivery.SignUp)).Methods(http.MethodPost, http.MethodOptions)
@@ -77,10 +212,54 @@ func main() {
 		banner.Handle("/banner", middleware.JwtMiddleware(middleware.CheckAdminPermissionMiddleware(http.HandlerFunc(BannerDelivery.AddItem)))).Methods(http.MethodPost, http.MethodOptions)
 		banner.Handle("/banner", middleware.JwtMiddleware(middleware.CheckAdminPermissionMiddleware(http.HandlerFunc(BannerDelivery.GetAll)))).Methods(http.MethodGet, http.MethodOptions)
 		banner.Handle("/user_banner", middleware.JwtMiddleware(http.HandlerFunc(BannerDelivery.GetOne))).Methods(http.MethodGet, http.MethodOptions)
+		banner.Handle("/user_banner/stream", middleware.JwtMiddleware(http.HandlerFunc(StreamDelivery.Stream))).Methods(http.MethodGet, http.MethodOptions)
+		banner.Handle("/user_banners", middleware.JwtMiddleware(http.HandlerFunc(BannerDelivery.GetMany))).Methods(http.MethodGet, http.MethodPost, http.MethodOptions)
+		banner.Handle("/banner/import", middleware.JwtMiddleware(middleware.CheckAdminPermissionMiddleware(http.HandlerFunc(BannerDelivery.ImportBanners)))).Methods(http.MethodPost, http.MethodOptions)
+		banner.Handle("/banner/export", middleware.JwtMiddleware(middleware.CheckAdminPermissionMiddleware(http.HandlerFunc(BannerDelivery.ExportBanners)))).Methods(http.MethodGet, http.MethodOptions)
+		banner.Handle("/banner/trash", middleware.JwtMiddleware(middleware.CheckAdminPermissionMiddleware(http.HandlerFunc(BannerDelivery.GetTrash)))).Methods(http.MethodGet, http.MethodOptions)
+		banner.Handle("/banner/{id}/restore", middleware.JwtMiddleware(middleware.CheckAdminPermissionMiddleware(http.HandlerFunc(BannerDelivery.RestoreBanner)))).Methods(http.MethodPost, http.MethodOptions)
+		banner.Handle("/banner/{id}", middleware.JwtMiddleware(middleware.CheckAdminPermissionMiddleware(http.HandlerFunc(BannerDelivery.GetBanner)))).Methods(http.MethodGet, http.MethodOptions)
 		banner.Handle("/banner/{id}", middleware.JwtMiddleware(middleware.CheckAdminPermissionMiddleware(http.HandlerFunc(BannerDelivery.UpdateBanner)))).Methods(http.MethodPatch, http.MethodOptions)
 		banner.Handle("/banner/{id}", middleware.JwtMiddleware(middleware.CheckAdminPermissionMiddleware(http.HandlerFunc(BannerDelivery.DeleteBanner)))).Methods(http.MethodDelete, http.MethodOptions)
+		banner.Handle("/banner", middleware.JwtMiddleware(middleware.CheckAdminPermissionMiddleware(http.HandlerFunc(BannerDelivery.DeleteFiltered)))).Methods(http.MethodDelete, http.MethodOptions)
+		banner.Handle("/banner/{id}/versions", middleware.JwtMiddleware(middleware.CheckAdminPermissionMiddleware(http.HandlerFunc(BannerDelivery.GetRevisions)))).Methods(http.MethodGet, http.MethodOptions)
+		banner.Handle("/banner/{id}/versions/{revision}/restore", middleware.JwtMiddleware(middleware.CheckAdminPermissionMiddleware(http.HandlerFunc(BannerDelivery.RestoreRevision)))).Methods(http.MethodPost, http.MethodOptions)
+		banner.Handle("/banner/{id}/stats", middleware.JwtMiddleware(middleware.CheckAdminPermissionMiddleware(http.HandlerFunc(StatsDelivery.GetStats)))).Methods(http.MethodGet, http.MethodOptions)
+		// переход по ссылке из баннера открывается браузером без токена
+		banner.Handle("/r/{banner_id}", http.HandlerFunc(StatsDelivery.Redirect)).Methods(http.MethodGet, http.MethodOptions)
+		banner.Handle("/banner/{id}/transitions", middleware.JwtMiddleware(middleware.CheckAdminPermissionMiddleware(http.HandlerFunc(WorkflowDelivery.GetTransitions)))).Methods(http.MethodGet, http.MethodOptions)
+		banner.Handle("/banner/{id}/{action:submit|approve|reject|publish|archive}", middleware.JwtMiddleware(middleware.CheckAdminPermissionMiddleware(http.HandlerFunc(WorkflowDelivery.Apply)))).Methods(http.MethodPost, http.MethodOptions)
 
 	}
+	feature := r.PathPrefix("/feature").Subrouter()
+	{
+		feature.Handle("", middleware.JwtMiddleware(middleware.CheckAdminPermissionMiddleware(http.HandlerFunc(FeatureDelivery.AddFeature)))).Methods(http.MethodPost, http.MethodOptions)
+		feature.Handle("", middleware.JwtMiddleware(middleware.CheckAdminPermissionMiddleware(http.HandlerFunc(FeatureDelivery.GetFeatures)))).Methods(http.MethodGet, http.MethodOptions)
+		feature.Handle("/{id}", middleware.JwtMiddleware(middleware.CheckAdminPermissionMiddleware(http.HandlerFunc(FeatureDelivery.UpdateFeature)))).Methods(http.MethodPatch, http.MethodOptions)
+		feature.Handle("/{id}", middleware.JwtMiddleware(middleware.CheckAdminPermissionMiddleware(http.HandlerFunc(FeatureDelivery.DeleteFeature)))).Methods(http.MethodDelete, http.MethodOptions)
+		feature.Handle("/{id}/schema", middleware.JwtMiddleware(middleware.CheckAdminPermissionMiddleware(http.HandlerFunc(FeatureDelivery.SetContentSchema)))).Methods(http.MethodPut, http.MethodOptions)
+		feature.Handle("/{id}/default_banner", middleware.JwtMiddleware(middleware.CheckAdminPermissionMiddleware(http.HandlerFunc(FeatureDelivery.SetDefaultBanner)))).Methods(http.MethodPut, http.MethodOptions)
+	}
+	tag := r.PathPrefix("/tag").Subrouter()
+	{
+		tag.Handle("", middleware.JwtMiddleware(middleware.CheckAdminPermissionMiddleware(http.HandlerFunc(TagDelivery.AddTag)))).Methods(http.MethodPost, http.MethodOptions)
+		tag.Handle("", middleware.JwtMiddleware(middleware.CheckAdminPermissionMiddleware(http.HandlerFunc(TagDelivery.GetTags)))).Methods(http.MethodGet, http.MethodOptions)
+		tag.Handle("/{id}", middleware.JwtMiddleware(middleware.CheckAdminPermissionMiddleware(http.HandlerFunc(TagDelivery.UpdateTag)))).Methods(http.MethodPatch, http.MethodOptions)
+		tag.Handle("/{id}", middleware.JwtMiddleware(middleware.CheckAdminPermissionMiddleware(http.HandlerFunc(TagDelivery.DeleteTag)))).Methods(http.MethodDelete, http.MethodOptions)
+	}
+	jobs := r.PathPrefix("/jobs").Subrouter()
+	{
+		jobs.Handle("/{id}", middleware.JwtMiddleware(middleware.CheckAdminPermissionMiddleware(http.HandlerFunc(JobDelivery.GetJob)))).Methods(http.MethodGet, http.MethodOptions)
+	}
+	webhooks := r.PathPrefix("/webhook").Subrouter()
+	{
+		webhooks.Handle("", middleware.JwtMiddleware(middleware.CheckAdminPermissionMiddleware(http.HandlerFunc(WebhookDelivery.AddWebhook)))).Methods(http.MethodPost, http.MethodOptions)
+		webhooks.Handle("", middleware.JwtMiddleware(middleware.CheckAdminPermissionMiddleware(http.HandlerFunc(WebhookDelivery.GetWebhooks)))).Methods(http.MethodGet, http.MethodOptions)
+		webhooks.Handle("/dead_letters", middleware.JwtMiddleware(middleware.CheckAdminPermissionMiddleware(http.HandlerFunc(WebhookDelivery.GetDeadLetters)))).Methods(http.MethodGet, http.MethodOptions)
+		webhooks.Handle("/{id}", middleware.JwtMiddleware(middleware.CheckAdminPermissionMiddleware(http.HandlerFunc(WebhookDelivery.DeleteWebhook)))).Methods(http.MethodDelete, http.MethodOptions)
+	}
+	r.Handle("/cache/stats", middleware.JwtMiddleware(middleware.CheckAdminPermissionMiddleware(http.HandlerFunc(CacheDelivery.GetStats)))).Methods(http.MethodGet, http.MethodOptions)
+	r.Handle("/audit", middleware.JwtMiddleware(middleware.CheckAdminPermissionMiddleware(http.HandlerFunc(AuditDelivery.GetEntries)))).Methods(http.MethodGet, http.MethodOptions)
 
 	signalCh := make(chan os.Signal, 1)
 	signal.Notify(signalCh, syscall.SIGINT, syscall.SIGTERM)
@@ -92,12 +271,37 @@ func main() {
 		WriteTimeout:      10 * time.Second,
 		ReadHeaderTimeout: 10 * time.Second,
 	}
+	// потоки /user_banner/stream не завершаются сами: останавливаем хаб, и они закрываются
+	server.RegisterOnShutdown(stopWorkers)
+
+	// gRPC обслуживает те же usecase на отдельном порту
+	grpcServer := grpc.NewServer(grpc.UnaryInterceptor(middleware.JwtInterceptor(map[string]middleware.Access{
+		pb.AuthService_SignUp_FullMethodName:         middleware.AccessPublic,
+		pb.AuthService_SignIn_FullMethodName:         middleware.AccessPublic,
+		pb.BannerService_AddItem_FullMethodName:      middleware.AccessAdmin,
+		pb.BannerService_GetOne_FullMethodName:       middleware.AccessUser,
+		pb.BannerService_GetAll_FullMethodName:       middleware.AccessAdmin,
+		pb.BannerService_UpdateBanner_FullMethodName: middleware.AccessAdmin,
+		pb.BannerService_DeleteBanner_FullMethodName: middleware.AccessAdmin,
+	})))
+	pb.RegisterAuthServiceServer(grpcServer, authGrpc.NewAuthHandler(AuthUsecase))
+	pb.RegisterBannerServiceServer(grpcServer, bannerGrpc.NewBannerHandler(BannerUsecase))
+	grpcListener, err := net.Listen("tcp", ":9090")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
 
 	go func() {
 		if err := server.ListenAndServe(); err != nil {
 			fmt.Println("Server Stopped")
 		}
 	}()
+	go func() {
+		if err := grpcServer.Serve(grpcListener); err != nil {
+			fmt.Println("gRPC server stopped:", err)
+		}
+	}()
 	fmt.Println("Server started ")
 
 	sig := <-signalCh
@@ -109,4 +313,5 @@ func main() {
 	if err := server.Shutdown(ctx); err != nil {
 		fmt.Println("Server shutdown failed: " + err.Error())
 	}
+	grpcServer.GracefulStop()
 }
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	AuditEntityBanner  = "banner"
+	AuditEntityFeature = "feature"
+	AuditEntityTag     = "tag"
+
+	AuditActionCreate    = "create"
+	AuditActionUpdate    = "update"
+	AuditActionDelete    = "delete"
+	AuditActionRestore   = "restore"
+	AuditActionSetSchema = "set_schema"
+	// the feature default banner was set or removed
+	AuditActionSetDefaultBanner = "set_default_banner"
+)
+
+// AuditEntry records a single admin mutation with the entity state before and after it.
+// Workflow actions are logged under their own names, see WorkflowAction* constants
+type AuditEntry struct {
+	Id         int64           `json:"id"`
+	Actor      string          `json:"actor"`
+	Action     string          `json:"action"`
+	Entity     string          `json:"entity"`
+	EntityId   int64           `json:"entity_id"`
+	Before     json.RawMessage `json:"before,omitempty"`
+	After      json.RawMessage `json:"after,omitempty"`
+	CreateTime time.Time       `json:"create_time"`
+}
+
+type AuditFilter struct {
+	Actor    string
+	Action   string
+	Entity   string
+	EntityId int64
+	From     *time.Time
+	To       *time.Time
+	// id of the last entry of the previous page, 0 for the first page
+	Cursor int64
+	Limit  int64
+}
+
+type AuditPage struct {
+	Entries []AuditEntry `json:"entries"`
+	// pass as cursor to get the next page, absent on the last page
+	NextCursor int64 `json:"next_cursor,omitempty"`
+}
+
+// AuditSnapshot encodes entity state for AuditEntry, nil stays absent
+func AuditSnapshot(state any) json.RawMessage {
+	if state == nil {
+		return nil
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return nil
+	}
+	return data
+}
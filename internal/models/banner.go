@@ -1,45 +1,200 @@
 package models
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"time"
 )
 
-type BannerContent struct {
-	Title string `json:"title"`
-	Data  string `json:"text"`
-	Url   string `json:"url"`
+// BannerContent is free-form JSON object sent by admins.
+// It is stored as JSONB and given back to clients without any processing
+type BannerContent json.RawMessage
+
+func (c BannerContent) MarshalJSON() ([]byte, error) {
+	if len(c) == 0 {
+		return []byte("null"), nil
+	}
+	return c, nil
+}
+
+func (c *BannerContent) UnmarshalJSON(data []byte) error {
+	*c = append((*c)[0:0], data...)
+	return nil
+}
+
+// IsObject reports whether the content is a JSON object
+func (c BannerContent) IsObject() bool {
+	var object map[string]json.RawMessage
+	return json.Unmarshal(c, &object) == nil && object != nil
+}
+
+// Hash returns hex encoded SHA-256 of the content, it is used as ETag of /api/user_banner
+func (c BannerContent) Hash() string {
+	sum := sha256.Sum256(c)
+	return hex.EncodeToString(sum[:])
+}
+
+// Url returns the "url" field of the content, /api/r/{banner_id} redirects there.
+// It is empty when the content has no such string field
+func (c BannerContent) Url() string {
+	var object struct {
+		Url string `json:"url"`
+	}
+	if json.Unmarshal(c, &object) != nil {
+		return ""
+	}
+	return object.Url
+}
+
+// FeatureTag identifies the banner served by /api/user_banner
+type FeatureTag struct {
+	FeatureId int64 `json:"feature_id"`
+	TagId     int64 `json:"tag_id"`
+}
+
+// String formats the pair as "feature_id:tag_id"
+func (k FeatureTag) String() string {
+	return fmt.Sprintf("%d:%d", k.FeatureId, k.TagId)
+}
+
+// BannerPairs returns the pairs served by a banner. A banner without tags is served only as the default
+// of its feature, so it gets a single pair with zero TagId: evicting it drops the cached default of the feature
+func BannerPairs(featureId int64, tagIds []int64) []FeatureTag {
+	if len(tagIds) == 0 {
+		return []FeatureTag{{FeatureId: featureId}}
+	}
+	result := make([]FeatureTag, 0, len(tagIds))
+	for _, tagId := range tagIds {
+		result = append(result, FeatureTag{FeatureId: featureId, TagId: tagId})
+	}
+	return result
 }
 
-func (i BannerContent) MarshalBinary() (data []byte, err error) {
-	bytes, err := json.Marshal(i)
-	return bytes, err
+// UserBannerResult is one item of /api/user_banners response, Found is false when there is no banner for the pair
+type UserBannerResult struct {
+	Found   bool          `json:"found"`
+	Content BannerContent `json:"content,omitempty"`
+	// id of the experiment variant, if the pair runs an experiment
+	VariantId int64 `json:"variant_id,omitempty"`
+	// set for inactive banners shown to admins
+	Inactive bool `json:"inactive,omitempty"`
+	// set when the feature default is served because the tag has no banner of its own
+	Fallback bool `json:"fallback,omitempty"`
+}
+
+// UserBanner is a banner as served by /api/user_banner and stored in cache
+type UserBanner struct {
+	Id          int64         `json:"id"`
+	Content     BannerContent `json:"content"`
+	ActiveUntil *time.Time    `json:"active_until,omitempty"`
+	Weight      int64         `json:"weight,omitempty"`
+	// Hash of Content, kept in cache so conditional requests do not touch the database
+	Hash       string    `json:"hash"`
+	UpdateTime time.Time `json:"update_time"`
+	// inactive banners are served to admins only
+	IsActive bool `json:"is_active"`
+	// at most that many impressions per user a day, 0 means no cap
+	ImpressionCap int64 `json:"impression_cap,omitempty"`
+	// the banner is the feature default served because the tag has no banner of its own
+	Fallback bool `json:"fallback,omitempty"`
 }
 
 type Banner struct {
-	Id         int64         `json:"id"`
-	Content    BannerContent `json:"content"`
-	TagIds     []int64       `json:"tag_ids"`
-	CreateTime time.Time     `json:"create_time"`
-	UpdateTime time.Time     `json:"update_time"`
-	FeatureId  int64         `json:"feature_id"`
-	IsActive   bool          `json:"is_active"`
+	Id          int64         `json:"id"`
+	Content     BannerContent `json:"content"`
+	TagIds      []int64       `json:"tag_ids"`
+	CreateTime  time.Time     `json:"create_time"`
+	UpdateTime  time.Time     `json:"update_time"`
+	FeatureId   int64         `json:"feature_id"`
+	IsActive    bool          `json:"is_active"`
+	ActiveFrom  *time.Time    `json:"active_from,omitempty"`
+	ActiveUntil *time.Time    `json:"active_until,omitempty"`
+	// non-zero weight makes the banner an experiment variant for its feature:tag pairs
+	Weight int64 `json:"weight,omitempty"`
+	// at most that many impressions per user a day, 0 means no cap
+	ImpressionCap int64 `json:"impression_cap,omitempty"`
+	// grows on every change, served as ETag for optimistic locking
+	Version int64 `json:"version"`
+	// workflow status, only published banners are served to users
+	Status string `json:"status"`
+	// set while the banner is in trash
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+type BannerRevision struct {
+	BannerId      int64         `json:"banner_id"`
+	Revision      int64         `json:"revision"`
+	Content       BannerContent `json:"content"`
+	FeatureId     int64         `json:"feature_id"`
+	TagIds        []int64       `json:"tag_ids"`
+	IsActive      bool          `json:"is_active"`
+	ActiveFrom    *time.Time    `json:"active_from,omitempty"`
+	ActiveUntil   *time.Time    `json:"active_until,omitempty"`
+	Weight        int64         `json:"weight,omitempty"`
+	ImpressionCap int64         `json:"impression_cap,omitempty"`
+	Author        string        `json:"author"`
+	CreateTime    time.Time     `json:"create_time"`
+}
+
+// OptionalTime tells an omitted time field apart from an explicit null,
+// so PATCH can both leave a value as is and clear it
+type OptionalTime struct {
+	Set   bool
+	Value *time.Time
+}
+
+func (t *OptionalTime) UnmarshalJSON(data []byte) error {
+	t.Set = true
+	return json.Unmarshal(data, &t.Value)
 }
 
-type UpdateContentForm struct {
-	Title *string `json:"title,omitempty"`
-	Data  *string `json:"text,omitempty"`
-	Url   *string `json:"url,omitempty"`
+// ActiveWindowValid reports whether the activation window is not empty
+func ActiveWindowValid(from *time.Time, until *time.Time) bool {
+	return from == nil || until == nil || from.Before(*until)
 }
+
 type BannerUpdateForm struct {
-	Content   *UpdateContentForm `json:"content,omitempty"`
-	FeatureId *int64             `json:"feature_id,omitempty"`
-	TagIds    []int64            `json:"tag_ids,omitempty"`
-	IsActive  *bool              `json:"is_active,omitempty"`
+	// content is replaced as a whole
+	Content   *BannerContent `json:"content,omitempty"`
+	FeatureId *int64         `json:"feature_id,omitempty"`
+	TagIds    []int64        `json:"tag_ids,omitempty"`
+	IsActive  *bool          `json:"is_active,omitempty"`
+	// null clears the bound, omitted field leaves it unchanged
+	ActiveFrom  OptionalTime `json:"active_from"`
+	ActiveUntil OptionalTime `json:"active_until"`
+	// 0 turns an experiment variant back into a regular banner
+	Weight *int64 `json:"weight,omitempty"`
+	// 0 removes the cap
+	ImpressionCap *int64 `json:"impression_cap,omitempty"`
 }
 type BannerForm struct {
-	Content   BannerContent `json:"content"`
-	FeatureId int64         `json:"feature_id"`
-	TagIds    []int64       `json:"tag_ids"`
-	IsActive  bool          `json:"is_active"`
+	Content     BannerContent `json:"content"`
+	FeatureId   int64         `json:"feature_id"`
+	TagIds      []int64       `json:"tag_ids"`
+	IsActive    bool          `json:"is_active"`
+	ActiveFrom  *time.Time    `json:"active_from,omitempty"`
+	ActiveUntil *time.Time    `json:"active_until,omitempty"`
+	Weight      int64         `json:"weight,omitempty"`
+	// at most that many impressions per user a day, 0 means no cap
+	ImpressionCap int64 `json:"impression_cap,omitempty"`
+}
+
+// ImportRow is a banner read from an import file, Row is its 1-based number among data rows
+type ImportRow struct {
+	Row  int
+	Form BannerForm
+}
+
+type ImportRowError struct {
+	Row     int    `json:"row"`
+	Message string `json:"message"`
+}
+
+type ImportReport struct {
+	DryRun   bool             `json:"dry_run"`
+	Total    int              `json:"total"`
+	Imported int              `json:"imported"`
+	Errors   []ImportRowError `json:"errors"`
 }
@@ -0,0 +1,17 @@
+package models
+
+// CacheTierStats counts lookups of one cache tier since the start of the replica.
+// Evictions are entries dropped before they expired: pushed out of the full local tier or invalidated by a change
+type CacheTierStats struct {
+	Hits      int64 `json:"hits"`
+	Misses    int64 `json:"misses"`
+	Evictions int64 `json:"evictions"`
+	// number of entries held, known for the local tier only
+	Size int64 `json:"size,omitempty"`
+}
+
+// CacheStats is served by /api/cache/stats, each replica reports its own counters
+type CacheStats struct {
+	Local CacheTierStats `json:"local"`
+	Redis CacheTierStats `json:"redis"`
+}
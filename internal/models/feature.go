@@ -0,0 +1,37 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+const MaxNameLength = 255
+
+type Feature struct {
+	Id   int64  `json:"id"`
+	Name string `json:"name"`
+	// JSON Schema that content of the feature banners must match
+	ContentSchema json.RawMessage `json:"content_schema,omitempty"`
+	// banner served for tags of the feature that have no banner of their own
+	DefaultBannerId *int64 `json:"default_banner_id,omitempty"`
+}
+
+type DefaultBannerForm struct {
+	BannerId *int64 `json:"banner_id"`
+}
+
+type FeatureForm struct {
+	Name string `json:"name"`
+}
+
+func (form *FeatureForm) Validate() error {
+	return validateName(form.Name)
+}
+
+func validateName(name string) error {
+	length := len([]rune(name))
+	if length == 0 || length > MaxNameLength {
+		return fmt.Errorf("name length must be from 1 to %d characters", MaxNameLength)
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+const (
+	JobKindDeleteBanners = "delete_banners"
+
+	JobStatusPending = "pending"
+	JobStatusRunning = "running"
+	JobStatusDone    = "done"
+	JobStatusFailed  = "failed"
+)
+
+type Job struct {
+	Id        int64  `json:"id"`
+	Kind      string `json:"kind"`
+	Status    string `json:"status"`
+	FeatureId int64  `json:"feature_id,omitempty"`
+	TagId     int64  `json:"tag_id,omitempty"`
+	// the admin who created the job, changes made by it are logged on their behalf
+	Actor      string    `json:"actor"`
+	Total      int64     `json:"total"`
+	Processed  int64     `json:"processed"`
+	Error      string    `json:"error,omitempty"`
+	CreateTime time.Time `json:"create_time"`
+	UpdateTime time.Time `json:"update_time"`
+}
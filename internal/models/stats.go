@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// BannerStats are counters of a banner for one day, days are counted in UTC
+type BannerStats struct {
+	BannerId    int64
+	Day         time.Time
+	Impressions int64
+	Clicks      int64
+}
+
+// BannerDayStats is one day of GET /api/banner/{id}/stats, ctr is clicks per impression
+type BannerDayStats struct {
+	Day         string  `json:"day"`
+	Impressions int64   `json:"impressions"`
+	Clicks      int64   `json:"clicks"`
+	CTR         float64 `json:"ctr"`
+}
+
+type BannerStatsReport struct {
+	BannerId    int64            `json:"banner_id"`
+	From        string           `json:"from"`
+	To          string           `json:"to"`
+	Impressions int64            `json:"impressions"`
+	Clicks      int64            `json:"clicks"`
+	CTR         float64          `json:"ctr"`
+	Days        []BannerDayStats `json:"days"`
+}
+
+// CTR returns clicks per impression, 0 when there were no impressions
+func CTR(impressions int64, clicks int64) float64 {
+	if impressions == 0 {
+		return 0
+	}
+	return float64(clicks) / float64(impressions)
+}
@@ -0,0 +1,14 @@
+package models
+
+type Tag struct {
+	Id   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+type TagForm struct {
+	Name string `json:"name"`
+}
+
+func (form *TagForm) Validate() error {
+	return validateName(form.Name)
+}
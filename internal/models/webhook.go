@@ -0,0 +1,72 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	WebhookEventBannerCreated = "banner.created"
+	WebhookEventBannerUpdated = "banner.updated"
+	WebhookEventBannerDeleted = "banner.deleted"
+)
+
+var WebhookEvents = []string{WebhookEventBannerCreated, WebhookEventBannerUpdated, WebhookEventBannerDeleted}
+
+const (
+	WebhookDeliveryPending = "pending"
+	// delivery gave up after the last retry, delivered events are removed from the outbox
+	WebhookDeliveryDead = "dead"
+)
+
+type Webhook struct {
+	Id  int64  `json:"id"`
+	Url string `json:"url"`
+	// HMAC key of the X-Webhook-Signature header, it is never given back
+	Secret     string    `json:"-"`
+	Events     []string  `json:"events"`
+	CreateTime time.Time `json:"create_time"`
+}
+
+type WebhookForm struct {
+	Url    string   `json:"url"`
+	Secret string   `json:"secret"`
+	Events []string `json:"events"`
+}
+
+// WebhookPayload is the body POSTed to webhooks, Banner is the state after the change or before deletion
+type WebhookPayload struct {
+	Event      string          `json:"event"`
+	BannerId   int64           `json:"banner_id"`
+	Banner     json.RawMessage `json:"banner"`
+	OccurredAt time.Time       `json:"occurred_at"`
+}
+
+// WebhookDelivery is one event queued for one webhook in the outbox
+type WebhookDelivery struct {
+	Id          int64           `json:"id"`
+	WebhookId   int64           `json:"webhook_id"`
+	Url         string          `json:"url"`
+	Secret      string          `json:"-"`
+	Event       string          `json:"event"`
+	Payload     json.RawMessage `json:"payload"`
+	Status      string          `json:"status"`
+	Attempts    int64           `json:"attempts"`
+	LastError   string          `json:"last_error,omitempty"`
+	NextAttempt time.Time       `json:"next_attempt"`
+	CreateTime  time.Time       `json:"create_time"`
+}
+
+// MarshalWebhookPayload builds the body of the event sent to webhooks
+func MarshalWebhookPayload(event string, bannerId int64, banner any, at time.Time) ([]byte, error) {
+	data, err := json.Marshal(banner)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(WebhookPayload{
+		Event:      event,
+		BannerId:   bannerId,
+		Banner:     data,
+		OccurredAt: at,
+	})
+}
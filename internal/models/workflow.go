@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+const (
+	BannerStatusDraft     = "draft"
+	BannerStatusInReview  = "in_review"
+	BannerStatusApproved  = "approved"
+	BannerStatusPublished = "published"
+	BannerStatusArchived  = "archived"
+
+	WorkflowActionSubmit  = "submit"
+	WorkflowActionApprove = "approve"
+	WorkflowActionReject  = "reject"
+	WorkflowActionPublish = "publish"
+	WorkflowActionArchive = "archive"
+	// WorkflowActionEdit is recorded when an edit or a rollback sends a banner back to draft
+	WorkflowActionEdit = "edit"
+)
+
+// BannerTransition is a record of a banner moving from one workflow status to another
+type BannerTransition struct {
+	BannerId   int64     `json:"banner_id"`
+	Action     string    `json:"action"`
+	FromStatus string    `json:"from_status"`
+	ToStatus   string    `json:"to_status"`
+	Actor      string    `json:"actor"`
+	CreateTime time.Time `json:"create_time"`
+}
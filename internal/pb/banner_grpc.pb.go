@@ -0,0 +1,258 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             v4.25.3
+// source: banner.proto
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	BannerService_AddItem_FullMethodName      = "/avito.BannerService/AddItem"
+	BannerService_GetOne_FullMethodName       = "/avito.BannerService/GetOne"
+	BannerService_GetAll_FullMethodName       = "/avito.BannerService/GetAll"
+	BannerService_UpdateBanner_FullMethodName = "/avito.BannerService/UpdateBanner"
+	BannerService_DeleteBanner_FullMethodName = "/avito.BannerService/DeleteBanner"
+)
+
+// BannerServiceClient is the client API for BannerService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type BannerServiceClient interface {
+	AddItem(ctx context.Context, in *BannerForm, opts ...grpc.CallOption) (*Banner, error)
+	GetOne(ctx context.Context, in *GetOneRequest, opts ...grpc.CallOption) (*UserBanner, error)
+	GetAll(ctx context.Context, in *GetAllRequest, opts ...grpc.CallOption) (*BannerList, error)
+	UpdateBanner(ctx context.Context, in *UpdateBannerRequest, opts ...grpc.CallOption) (*UpdateBannerResponse, error)
+	DeleteBanner(ctx context.Context, in *DeleteBannerRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+}
+
+type bannerServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewBannerServiceClient(cc grpc.ClientConnInterface) BannerServiceClient {
+	return &bannerServiceClient{cc}
+}
+
+func (c *bannerServiceClient) AddItem(ctx context.Context, in *BannerForm, opts ...grpc.CallOption) (*Banner, error) {
+	out := new(Banner)
+	err := c.cc.Invoke(ctx, BannerService_AddItem_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *bannerServiceClient) GetOne(ctx context.Context, in *GetOneRequest, opts ...grpc.CallOption) (*UserBanner, error) {
+	out := new(UserBanner)
+	err := c.cc.Invoke(ctx, BannerService_GetOne_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *bannerServiceClient) GetAll(ctx context.Context, in *GetAllRequest, opts ...grpc.CallOption) (*BannerList, error) {
+	out := new(BannerList)
+	err := c.cc.Invoke(ctx, BannerService_GetAll_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *bannerServiceClient) UpdateBanner(ctx context.Context, in *UpdateBannerRequest, opts ...grpc.CallOption) (*UpdateBannerResponse, error) {
+	out := new(UpdateBannerResponse)
+	err := c.cc.Invoke(ctx, BannerService_UpdateBanner_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *bannerServiceClient) DeleteBanner(ctx context.Context, in *DeleteBannerRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, BannerService_DeleteBanner_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// BannerServiceServer is the server API for BannerService service.
+// All implementations must embed UnimplementedBannerServiceServer
+// for forward compatibility
+type BannerServiceServer interface {
+	AddItem(context.Context, *BannerForm) (*Banner, error)
+	GetOne(context.Context, *GetOneRequest) (*UserBanner, error)
+	GetAll(context.Context, *GetAllRequest) (*BannerList, error)
+	UpdateBanner(context.Context, *UpdateBannerRequest) (*UpdateBannerResponse, error)
+	DeleteBanner(context.Context, *DeleteBannerRequest) (*emptypb.Empty, error)
+	mustEmbedUnimplementedBannerServiceServer()
+}
+
+// UnimplementedBannerServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedBannerServiceServer struct {
+}
+
+func (UnimplementedBannerServiceServer) AddItem(context.Context, *BannerForm) (*Banner, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddItem not implemented")
+}
+func (UnimplementedBannerServiceServer) GetOne(context.Context, *GetOneRequest) (*UserBanner, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetOne not implemented")
+}
+func (UnimplementedBannerServiceServer) GetAll(context.Context, *GetAllRequest) (*BannerList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetAll not implemented")
+}
+func (UnimplementedBannerServiceServer) UpdateBanner(context.Context, *UpdateBannerRequest) (*UpdateBannerResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateBanner not implemented")
+}
+func (UnimplementedBannerServiceServer) DeleteBanner(context.Context, *DeleteBannerRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteBanner not implemented")
+}
+func (UnimplementedBannerServiceServer) mustEmbedUnimplementedBannerServiceServer() {}
+
+// UnsafeBannerServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to BannerServiceServer will
+// result in compilation errors.
+type UnsafeBannerServiceServer interface {
+	mustEmbedUnimplementedBannerServiceServer()
+}
+
+func RegisterBannerServiceServer(s grpc.ServiceRegistrar, srv BannerServiceServer) {
+	s.RegisterService(&BannerService_ServiceDesc, srv)
+}
+
+func _BannerService_AddItem_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BannerForm)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BannerServiceServer).AddItem(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BannerService_AddItem_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BannerServiceServer).AddItem(ctx, req.(*BannerForm))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BannerService_GetOne_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetOneRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BannerServiceServer).GetOne(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BannerService_GetOne_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BannerServiceServer).GetOne(ctx, req.(*GetOneRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BannerService_GetAll_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetAllRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BannerServiceServer).GetAll(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BannerService_GetAll_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BannerServiceServer).GetAll(ctx, req.(*GetAllRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BannerService_UpdateBanner_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateBannerRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BannerServiceServer).UpdateBanner(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BannerService_UpdateBanner_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BannerServiceServer).UpdateBanner(ctx, req.(*UpdateBannerRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BannerService_DeleteBanner_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteBannerRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BannerServiceServer).DeleteBanner(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BannerService_DeleteBanner_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BannerServiceServer).DeleteBanner(ctx, req.(*DeleteBannerRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// BannerService_ServiceDesc is the grpc.ServiceDesc for BannerService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var BannerService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "avito.BannerService",
+	HandlerType: (*BannerServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "AddItem",
+			Handler:    _BannerService_AddItem_Handler,
+		},
+		{
+			MethodName: "GetOne",
+			Handler:    _BannerService_GetOne_Handler,
+		},
+		{
+			MethodName: "GetAll",
+			Handler:    _BannerService_GetAll_Handler,
+		},
+		{
+			MethodName: "UpdateBanner",
+			Handler:    _BannerService_UpdateBanner_Handler,
+		},
+		{
+			MethodName: "DeleteBanner",
+			Handler:    _BannerService_DeleteBanner_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "banner.proto",
+}
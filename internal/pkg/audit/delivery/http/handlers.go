@@ -0,0 +1,95 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Alladan04/avito_test/internal/models"
+	"github.com/Alladan04/avito_test/internal/pkg/audit"
+	"github.com/Alladan04/avito_test/internal/pkg/utils"
+)
+
+type AuditHandler struct {
+	uc audit.AuditUsecase
+}
+
+func NewAuditHandler(uc audit.AuditUsecase) *AuditHandler {
+	return &AuditHandler{
+		uc: uc,
+	}
+}
+
+// GetEntries returns the audit log filtered by actor, action, entity, entity_id (or banner_id) and from/to time range.
+// Pages are requested with limit and the next_cursor of the previous page passed as cursor
+// for admins only
+func (h *AuditHandler) GetEntries(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := models.AuditFilter{
+		Actor:  query.Get("actor"),
+		Action: query.Get("action"),
+		Entity: query.Get("entity"),
+	}
+	var err error
+	if filter.EntityId, err = parseIntParam(query.Get("entity_id")); err != nil {
+		utils.WriteErrorMessage(w, http.StatusBadRequest, "wrong entity_id param")
+		return
+	}
+	if bannerParam := query.Get("banner_id"); bannerParam != "" {
+		if filter.EntityId, err = parseIntParam(bannerParam); err != nil {
+			utils.WriteErrorMessage(w, http.StatusBadRequest, "wrong banner_id param")
+			return
+		}
+		filter.Entity = models.AuditEntityBanner
+	}
+	if filter.From, err = parseTimeParam(query.Get("from")); err != nil {
+		utils.WriteErrorMessage(w, http.StatusBadRequest, "wrong from param")
+		return
+	}
+	if filter.To, err = parseTimeParam(query.Get("to")); err != nil {
+		utils.WriteErrorMessage(w, http.StatusBadRequest, "wrong to param")
+		return
+	}
+	if filter.Cursor, err = parseIntParam(query.Get("cursor")); err != nil {
+		utils.WriteErrorMessage(w, http.StatusBadRequest, "wrong cursor param")
+		return
+	}
+	if filter.Limit, err = parseIntParam(query.Get("limit")); err != nil {
+		utils.WriteErrorMessage(w, http.StatusBadRequest, "wrong limit param")
+		return
+	}
+
+	result, err := h.uc.GetEntries(r.Context(), filter)
+	if err != nil {
+		if errors.Is(err, audit.ErrInvalidFilter) {
+			utils.WriteErrorMessage(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	err = utils.WriteResponseData(w, result, http.StatusOK)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func parseIntParam(param string) (int64, error) {
+	if param == "" {
+		return 0, nil
+	}
+	return strconv.ParseInt(param, 10, 64)
+}
+
+func parseTimeParam(param string) (*time.Time, error) {
+	if param == "" {
+		return nil, nil
+	}
+	value, err := time.Parse(time.RFC3339, param)
+	if err != nil {
+		return nil, err
+	}
+	value = value.UTC()
+	return &value, nil
+}
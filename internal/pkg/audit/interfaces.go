@@ -0,0 +1,20 @@
+package audit
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Alladan04/avito_test/internal/models"
+)
+
+var (
+	ErrInvalidFilter = errors.New("invalid audit filter")
+)
+
+type AuditRepo interface {
+	GetEntries(ctx context.Context, filter models.AuditFilter) ([]models.AuditEntry, error)
+}
+
+type AuditUsecase interface {
+	GetEntries(ctx context.Context, filter models.AuditFilter) (models.AuditPage, error)
+}
@@ -0,0 +1,60 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Alladan04/avito_test/internal/models"
+	"github.com/jackc/pgtype/pgxtype"
+)
+
+const (
+	addEntry   = `INSERT INTO audit_log (actor, action, entity, entity_id, before, after, create_time) VALUES ($1, $2, $3, $4, $5, $6, $7);`
+	getEntries = `SELECT id, actor, action, entity, entity_id, before, after, create_time
+					FROM audit_log
+					WHERE ($1 = '' OR actor = $1)
+					AND ($2 = '' OR action = $2)
+					AND ($3 = '' OR entity = $3)
+					AND ($4 = 0 OR entity_id = $4)
+					AND ($5::timestamp IS NULL OR create_time >= $5)
+					AND ($6::timestamp IS NULL OR create_time < $6)
+					AND ($7 = 0 OR id < $7)
+					ORDER BY id DESC
+					LIMIT $8;`
+)
+
+type AuditRepo struct {
+	db pgxtype.Querier
+}
+
+func NewAuditRepo(db pgxtype.Querier) *AuditRepo {
+	return &AuditRepo{
+		db: db,
+	}
+}
+
+// AddEntry appends the entry to the audit log.
+// Repos call it with their transaction, so the entry is saved only together with the change it describes
+func AddEntry(ctx context.Context, db pgxtype.Querier, entry models.AuditEntry) error {
+	_, err := db.Exec(ctx, addEntry, entry.Actor, entry.Action, entry.Entity, entry.EntityId, []byte(entry.Before), []byte(entry.After), entry.CreateTime)
+	return err
+}
+
+// GetEntries returns entries matching the filter, newest first
+func (repo *AuditRepo) GetEntries(ctx context.Context, filter models.AuditFilter) ([]models.AuditEntry, error) {
+	result := make([]models.AuditEntry, 0, filter.Limit)
+	rows, err := repo.db.Query(ctx, getEntries, filter.Actor, filter.Action, filter.Entity, filter.EntityId, filter.From, filter.To, filter.Cursor, filter.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var item models.AuditEntry
+		if err := rows.Scan(&item.Id, &item.Actor, &item.Action, &item.Entity, &item.EntityId, (*[]byte)(&item.Before), (*[]byte)(&item.After), &item.CreateTime); err != nil {
+			return nil, fmt.Errorf("error occured while scanning audit entries:%w", err)
+		}
+		result = append(result, item)
+	}
+
+	return result, rows.Err()
+}
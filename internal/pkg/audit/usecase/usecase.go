@@ -0,0 +1,48 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/Alladan04/avito_test/internal/models"
+	"github.com/Alladan04/avito_test/internal/pkg/audit"
+)
+
+const (
+	pageElementsCount = 50
+	maxPageElements   = 500
+)
+
+type AuditUsecase struct {
+	repo audit.AuditRepo
+}
+
+func NewAuditUsecase(repo audit.AuditRepo) *AuditUsecase {
+	return &AuditUsecase{
+		repo: repo,
+	}
+}
+
+// GetEntries returns a page of the audit log, newest entries first
+func (uc *AuditUsecase) GetEntries(ctx context.Context, filter models.AuditFilter) (models.AuditPage, error) {
+	if filter.Limit < 0 || filter.Cursor < 0 {
+		return models.AuditPage{}, audit.ErrInvalidFilter
+	}
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		return models.AuditPage{}, audit.ErrInvalidFilter
+	}
+	if filter.Limit == 0 {
+		filter.Limit = pageElementsCount
+	}
+	if filter.Limit > maxPageElements {
+		filter.Limit = maxPageElements
+	}
+	entries, err := uc.repo.GetEntries(ctx, filter)
+	if err != nil {
+		return models.AuditPage{}, err
+	}
+	result := models.AuditPage{Entries: entries}
+	if int64(len(entries)) == filter.Limit {
+		result.NextCursor = entries[len(entries)-1].Id
+	}
+	return result, nil
+}
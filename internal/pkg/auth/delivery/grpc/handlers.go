@@ -0,0 +1,77 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Alladan04/avito_test/internal/models"
+	"github.com/Alladan04/avito_test/internal/pb"
+	"github.com/Alladan04/avito_test/internal/pkg/auth"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// AuthHandler serves pb.AuthService, its methods need no token
+type AuthHandler struct {
+	pb.UnimplementedAuthServiceServer
+	uc auth.AuthUsecase
+}
+
+func NewAuthHandler(uc auth.AuthUsecase) *AuthHandler {
+	return &AuthHandler{
+		uc: uc,
+	}
+}
+
+func (h *AuthHandler) SignUp(ctx context.Context, req *pb.UserForm) (*pb.AuthResponse, error) {
+	form := toUserForm(req)
+	if err := form.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	user, token, expTime, err := h.uc.SignUp(ctx, form)
+	if err != nil {
+		if errors.Is(err, auth.ErrCreatingUser) {
+			return nil, status.Error(codes.AlreadyExists, err.Error())
+		}
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+	return toAuthResponse(user, token, expTime), nil
+}
+
+func (h *AuthHandler) SignIn(ctx context.Context, req *pb.UserForm) (*pb.AuthResponse, error) {
+	form := toUserForm(req)
+	if err := form.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	user, token, expTime, err := h.uc.SignIn(ctx, form)
+	if err != nil {
+		if errors.Is(err, auth.ErrUserNotFound) || errors.Is(err, auth.ErrWrongUserData) {
+			return nil, status.Error(codes.Unauthenticated, auth.ErrWrongUserData.Error())
+		}
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+	return toAuthResponse(user, token, expTime), nil
+}
+
+func toUserForm(req *pb.UserForm) models.UserForm {
+	return models.UserForm{
+		Username: req.Username,
+		Password: req.Password,
+		IsAdmin:  req.IsAdmin,
+	}
+}
+
+func toAuthResponse(user models.User, token string, expTime time.Time) *pb.AuthResponse {
+	return &pb.AuthResponse{
+		User: &pb.User{
+			Id:         user.Id,
+			Username:   user.Username,
+			CreateTime: timestamppb.New(user.CreateTime),
+			IsAdmin:    user.IsAdmin,
+		},
+		Token:      token,
+		ExpireTime: timestamppb.New(expTime),
+	}
+}
@@ -0,0 +1,187 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Alladan04/avito_test/internal/models"
+	"github.com/Alladan04/avito_test/internal/pb"
+	"github.com/Alladan04/avito_test/internal/pkg/banner"
+	"github.com/jackc/pgx/v4"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// BannerHandler serves pb.BannerService with the same usecase as the HTTP handlers,
+// permissions are checked by middleware.JwtInterceptor
+type BannerHandler struct {
+	pb.UnimplementedBannerServiceServer
+	uc banner.BannerUsecase
+}
+
+func NewBannerHandler(uc banner.BannerUsecase) *BannerHandler {
+	return &BannerHandler{
+		uc: uc,
+	}
+}
+
+// AddItem to create new banner
+// for admins only
+func (h *BannerHandler) AddItem(ctx context.Context, req *pb.BannerForm) (*pb.Banner, error) {
+	result, err := h.uc.AddItem(ctx, models.BannerForm{
+		Content:       models.BannerContent(req.Content),
+		FeatureId:     req.FeatureId,
+		TagIds:        req.TagIds,
+		IsActive:      req.IsActive,
+		ActiveFrom:    fromTimestamp(req.ActiveFrom),
+		ActiveUntil:   fromTimestamp(req.ActiveUntil),
+		Weight:        req.Weight,
+		ImpressionCap: req.ImpressionCap,
+	})
+	if err != nil {
+		return nil, bannerError(err)
+	}
+	return toBanner(result), nil
+}
+
+// GetOne returns the banner served to the user for the feature and tag
+func (h *BannerHandler) GetOne(ctx context.Context, req *pb.GetOneRequest) (*pb.UserBanner, error) {
+	result, err := h.uc.GetOne(ctx, req.FeatureId, req.TagId, req.UseLastRevision)
+	if err != nil {
+		return nil, bannerError(err)
+	}
+	item := &pb.UserBanner{
+		Content:  string(result.Content),
+		Hash:     result.Hash,
+		Inactive: !result.IsActive,
+		Fallback: result.Fallback,
+	}
+	if result.Weight > 0 {
+		item.VariantId = result.Id
+	}
+	if !result.UpdateTime.IsZero() {
+		item.UpdateTime = timestamppb.New(result.UpdateTime)
+	}
+	return item, nil
+}
+
+// GetAll returns banners filtered by feature and tag
+// for admins only
+func (h *BannerHandler) GetAll(ctx context.Context, req *pb.GetAllRequest) (*pb.BannerList, error) {
+	if req.Limit < 0 || req.Offset < 0 {
+		return nil, status.Error(codes.InvalidArgument, "limit and offset must not be negative")
+	}
+	result, err := h.uc.GetAll(ctx, req.Limit, req.Offset, req.FeatureId, req.TagId)
+	if err != nil {
+		return nil, bannerError(err)
+	}
+	list := &pb.BannerList{Banners: make([]*pb.Banner, 0, len(result))}
+	for _, item := range result {
+		list.Banners = append(list.Banners, toBanner(item))
+	}
+	return list, nil
+}
+
+// UpdateBanner changes the fields set in the request and returns the new version
+// for admins only
+func (h *BannerHandler) UpdateBanner(ctx context.Context, req *pb.UpdateBannerRequest) (*pb.UpdateBannerResponse, error) {
+	update := req.GetBanner()
+	form := models.BannerUpdateForm{
+		FeatureId:     update.FeatureId,
+		TagIds:        update.GetTagIds(),
+		IsActive:      update.IsActive,
+		Weight:        update.Weight,
+		ImpressionCap: update.ImpressionCap,
+		ActiveFrom:    optionalTime(update.GetActiveFrom(), update.GetClearActiveFrom()),
+		ActiveUntil:   optionalTime(update.GetActiveUntil(), update.GetClearActiveUntil()),
+	}
+	if update.Content != nil {
+		content := models.BannerContent(*update.Content)
+		form.Content = &content
+	}
+	version, err := h.uc.UpdateBanner(ctx, form, req.Id, expectedVersions(req.Version))
+	if err != nil {
+		return nil, bannerError(err)
+	}
+	return &pb.UpdateBannerResponse{Version: version}, nil
+}
+
+// DeleteBanner moves the banner to trash
+// for admins only
+func (h *BannerHandler) DeleteBanner(ctx context.Context, req *pb.DeleteBannerRequest) (*emptypb.Empty, error) {
+	err := h.uc.DeleteBanner(ctx, req.Id, expectedVersions(req.Version))
+	if err != nil {
+		return nil, bannerError(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// expectedVersions turns the version of the request into the list the usecase expects, zero skips the check
+func expectedVersions(version int64) []int64 {
+	if version == 0 {
+		return nil
+	}
+	return []int64{version}
+}
+
+// bannerError maps usecase errors to the status codes closest to the ones of the HTTP handlers
+func bannerError(err error) error {
+	switch {
+	case errors.Is(err, banner.ErrBannerNotFound) || errors.Is(err, pgx.ErrNoRows):
+		return status.Error(codes.NotFound, banner.ErrBannerNotFound.Error())
+	case errors.Is(err, banner.ErrVersionMismatch):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, banner.ErrBannerConflict):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case banner.IsValidationError(err):
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return status.Error(codes.Internal, "internal error")
+}
+
+func toBanner(item models.Banner) *pb.Banner {
+	return &pb.Banner{
+		Id:            item.Id,
+		Content:       string(item.Content),
+		TagIds:        item.TagIds,
+		FeatureId:     item.FeatureId,
+		IsActive:      item.IsActive,
+		ActiveFrom:    toTimestamp(item.ActiveFrom),
+		ActiveUntil:   toTimestamp(item.ActiveUntil),
+		Weight:        item.Weight,
+		ImpressionCap: item.ImpressionCap,
+		Version:       item.Version,
+		Status:        item.Status,
+		CreateTime:    timestamppb.New(item.CreateTime),
+		UpdateTime:    timestamppb.New(item.UpdateTime),
+	}
+}
+
+func toTimestamp(value *time.Time) *timestamppb.Timestamp {
+	if value == nil {
+		return nil
+	}
+	return timestamppb.New(*value)
+}
+
+func fromTimestamp(value *timestamppb.Timestamp) *time.Time {
+	if value == nil {
+		return nil
+	}
+	result := value.AsTime()
+	return &result
+}
+
+// optionalTime keeps the PATCH semantics: an unset field is left as is, clear removes the bound
+func optionalTime(value *timestamppb.Timestamp, clear bool) models.OptionalTime {
+	if clear {
+		return models.OptionalTime{Set: true}
+	}
+	if value == nil {
+		return models.OptionalTime{}
+	}
+	return models.OptionalTime{Set: true, Value: fromTimestamp(value)}
+}
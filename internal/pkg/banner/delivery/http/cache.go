@@ -0,0 +1,26 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/Alladan04/avito_test/internal/pkg/banner"
+	"github.com/Alladan04/avito_test/internal/pkg/utils"
+)
+
+type CacheHandler struct {
+	stats banner.CacheStats
+}
+
+func NewCacheHandler(stats banner.CacheStats) *CacheHandler {
+	return &CacheHandler{
+		stats: stats,
+	}
+}
+
+// GetStats returns the cache counters of the replica that served the request
+func (h *CacheHandler) GetStats(w http.ResponseWriter, r *http.Request) {
+	err := utils.WriteResponseData(w, h.stats.Stats(), http.StatusOK)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,253 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Alladan04/avito_test/internal/models"
+)
+
+const (
+	formatNDJSON = "ndjson"
+	formatCSV    = "csv"
+
+	ndjsonContentType = "application/x-ndjson"
+	csvContentType    = "text/csv"
+
+	csvTagSeparator = ";"
+)
+
+var (
+	errUnknownFormat = errors.New("format must be ndjson or csv")
+
+	// content колонка содержит JSON объект баннера
+	// impression_cap появилась позже остальных колонок, файлы без неё тоже принимаются
+	csvHeader = []string{"content", "feature_id", "tag_ids", "is_active", "active_from", "active_until", "weight", "impression_cap"}
+)
+
+// exchangeFormat picks the import/export format from the format query param,
+// falling back to the content type and then to ndjson
+func exchangeFormat(param string, contentType string) (string, error) {
+	switch {
+	case param == formatNDJSON || param == formatCSV:
+		return param, nil
+	case param != "":
+		return "", errUnknownFormat
+	case strings.HasPrefix(contentType, csvContentType):
+		return formatCSV, nil
+	default:
+		return formatNDJSON, nil
+	}
+}
+
+// readImportRows parses every row of the import file. Rows that cannot be parsed are reported as errors
+func readImportRows(r io.Reader, format string) ([]models.ImportRow, []models.ImportRowError, error) {
+	if format == formatCSV {
+		return readCSV(r)
+	}
+	return readNDJSON(r)
+}
+
+func readNDJSON(r io.Reader) ([]models.ImportRow, []models.ImportRowError, error) {
+	rows := make([]models.ImportRow, 0)
+	rowErrors := make([]models.ImportRowError, 0)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	rowNumber := 0
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		rowNumber++
+		var form models.BannerForm
+		if err := json.Unmarshal(line, &form); err != nil {
+			rowErrors = append(rowErrors, models.ImportRowError{Row: rowNumber, Message: err.Error()})
+			continue
+		}
+		rows = append(rows, models.ImportRow{Row: rowNumber, Form: form})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+	return rows, rowErrors, nil
+}
+
+func readCSV(r io.Reader) ([]models.ImportRow, []models.ImportRowError, error) {
+	rows := make([]models.ImportRow, 0)
+	rowErrors := make([]models.ImportRowError, 0)
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("can`t read csv header: %w", err)
+	}
+	if len(header) != len(csvHeader) && len(header) != len(csvHeader)-1 {
+		return nil, nil, fmt.Errorf("csv header must be %s", strings.Join(csvHeader, ","))
+	}
+	for i, name := range csvHeader[:len(header)] {
+		if strings.TrimSpace(header[i]) != name {
+			return nil, nil, fmt.Errorf("csv header must be %s", strings.Join(csvHeader, ","))
+		}
+	}
+	rowNumber := 0
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		rowNumber++
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, nil, err
+			}
+			rowErrors = append(rowErrors, models.ImportRowError{Row: rowNumber, Message: err.Error()})
+			continue
+		}
+		form, err := parseCSVRecord(record)
+		if err != nil {
+			rowErrors = append(rowErrors, models.ImportRowError{Row: rowNumber, Message: err.Error()})
+			continue
+		}
+		rows = append(rows, models.ImportRow{Row: rowNumber, Form: form})
+	}
+	return rows, rowErrors, nil
+}
+
+func parseCSVRecord(record []string) (models.BannerForm, error) {
+	var err error
+	form := models.BannerForm{}
+	if !json.Valid([]byte(record[0])) {
+		return models.BannerForm{}, errors.New("wrong content")
+	}
+	form.Content = models.BannerContent(record[0])
+	form.FeatureId, err = strconv.ParseInt(record[1], 10, 64)
+	if err != nil {
+		return models.BannerForm{}, errors.New("wrong feature_id")
+	}
+	form.TagIds = make([]int64, 0)
+	if record[2] != "" {
+		for _, tagString := range strings.Split(record[2], csvTagSeparator) {
+			tag, err := strconv.ParseInt(strings.TrimSpace(tagString), 10, 64)
+			if err != nil {
+				return models.BannerForm{}, errors.New("wrong tag_ids")
+			}
+			form.TagIds = append(form.TagIds, tag)
+		}
+	}
+	form.IsActive, err = strconv.ParseBool(record[3])
+	if err != nil {
+		return models.BannerForm{}, errors.New("wrong is_active")
+	}
+	form.ActiveFrom, err = parseCSVTime(record[4])
+	if err != nil {
+		return models.BannerForm{}, errors.New("wrong active_from")
+	}
+	form.ActiveUntil, err = parseCSVTime(record[5])
+	if err != nil {
+		return models.BannerForm{}, errors.New("wrong active_until")
+	}
+	if record[6] != "" {
+		form.Weight, err = strconv.ParseInt(record[6], 10, 64)
+		if err != nil {
+			return models.BannerForm{}, errors.New("wrong weight")
+		}
+	}
+	if len(record) > 7 && record[7] != "" {
+		form.ImpressionCap, err = strconv.ParseInt(record[7], 10, 64)
+		if err != nil {
+			return models.BannerForm{}, errors.New("wrong impression_cap")
+		}
+	}
+	return form, nil
+}
+
+func parseCSVTime(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	result, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+	result = result.UTC()
+	return &result, nil
+}
+
+func formatCSVTime(value *time.Time) string {
+	if value == nil {
+		return ""
+	}
+	return value.UTC().Format(time.RFC3339)
+}
+
+// exportWriter writes banners one by one in the chosen format
+type exportWriter interface {
+	Write(item models.BannerForm) error
+	Flush() error
+}
+
+func newExportWriter(w io.Writer, format string) (exportWriter, error) {
+	if format == formatCSV {
+		writer := csv.NewWriter(w)
+		if err := writer.Write(csvHeader); err != nil {
+			return nil, err
+		}
+		return &csvExportWriter{writer: writer}, nil
+	}
+	return &ndjsonExportWriter{encoder: json.NewEncoder(w)}, nil
+}
+
+type ndjsonExportWriter struct {
+	encoder *json.Encoder
+}
+
+func (e *ndjsonExportWriter) Write(item models.BannerForm) error {
+	return e.encoder.Encode(item)
+}
+
+func (e *ndjsonExportWriter) Flush() error {
+	return nil
+}
+
+type csvExportWriter struct {
+	writer *csv.Writer
+}
+
+func (e *csvExportWriter) Write(item models.BannerForm) error {
+	tags := make([]string, 0, len(item.TagIds))
+	for _, tag := range item.TagIds {
+		tags = append(tags, strconv.FormatInt(tag, 10))
+	}
+	weight := ""
+	if item.Weight != 0 {
+		weight = strconv.FormatInt(item.Weight, 10)
+	}
+	impressionCap := ""
+	if item.ImpressionCap != 0 {
+		impressionCap = strconv.FormatInt(item.ImpressionCap, 10)
+	}
+	return e.writer.Write([]string{
+		string(item.Content),
+		strconv.FormatInt(item.FeatureId, 10),
+		strings.Join(tags, csvTagSeparator),
+		strconv.FormatBool(item.IsActive),
+		formatCSVTime(item.ActiveFrom),
+		formatCSVTime(item.ActiveUntil),
+		weight,
+		impressionCap,
+	})
+}
+
+func (e *csvExportWriter) Flush() error {
+	e.writer.Flush()
+	return e.writer.Error()
+}
@@ -1,8 +1,13 @@
 package http
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/Alladan04/avito_test/internal/models"
 	"github.com/Alladan04/avito_test/internal/pkg/banner"
@@ -10,6 +15,18 @@ import (
 	"github.com/gorilla/mux"
 )
 
+const (
+	// VariantHeader carries id of the experiment variant served by /api/user_banner
+	VariantHeader = "X-Banner-Variant"
+	// InactiveHeader marks an inactive banner shown to an admin by /api/user_banner
+	InactiveHeader = "X-Banner-Inactive"
+	// MatchHeader tells whether /api/user_banner served a banner of the tag or the feature default
+	MatchHeader = "X-Banner-Match"
+
+	matchDirect   = "direct"
+	matchFallback = "fallback"
+)
+
 type BannerHandler struct {
 	uc banner.BannerUsecase
 }
@@ -43,6 +60,14 @@ func (h *BannerHandler) AddItem(w http.ResponseWriter, r *http.Request) {
 
 	res, err := h.uc.AddItem(r.Context(), item)
 	if err != nil {
+		if banner.IsValidationError(err) {
+			utils.WriteErrorMessage(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if errors.Is(err, banner.ErrBannerConflict) {
+			utils.WriteErrorMessage(w, http.StatusConflict, err.Error())
+			return
+		}
 		utils.WriteErrorMessage(w, http.StatusBadRequest, "error Adding data")
 		return
 
@@ -123,18 +148,91 @@ func (h *BannerHandler) GetOne(w http.ResponseWriter, r *http.Request) {
 		useLastRevision = false
 	}
 
-	result, err := h.uc.GetOne(r.Context(), featureId, tagId, useLastRevision)
+	result, err := h.uc.PickOne(r.Context(), featureId, tagId, useLastRevision)
 	if err != nil {
 		utils.WriteErrorMessage(w, http.StatusNotFound, "not found")
 		return
 	}
-	err = utils.WriteResponseData(w, result, http.StatusOK)
+	if result.Weight > 0 {
+		w.Header().Set(VariantHeader, strconv.FormatInt(result.Id, 10))
+	}
+	if !result.IsActive {
+		w.Header().Set(InactiveHeader, "true")
+	}
+	if result.Fallback {
+		w.Header().Set(MatchHeader, matchFallback)
+	} else {
+		w.Header().Set(MatchHeader, matchDirect)
+	}
+	// ответ зависит от пользователя, поэтому кешировать его может только клиент, и только с перепроверкой
+	w.Header().Set("Cache-Control", "private, no-cache")
+	w.Header().Set("ETag", strconv.Quote(result.Hash))
+	if !result.UpdateTime.IsZero() {
+		w.Header().Set("Last-Modified", result.UpdateTime.UTC().Format(http.TimeFormat))
+	}
+	// баннер уже есть у клиента: показа не было, ни статистика, ни ограничение показов его не считают
+	if notModified(r, result) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	h.uc.CountImpression(r.Context(), result)
+	err = utils.WriteResponseData(w, result.Content, http.StatusOK)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 	}
 
 }
 
+// GetMany returns banners for several tags in one call:
+// GET with feature_id and repeated tag_id params, or POST with a list of feature_id and tag_id pairs.
+// The response maps "feature_id:tag_id" to the banner, pairs without a banner have found=false
+func (h *BannerHandler) GetMany(w http.ResponseWriter, r *http.Request) {
+	useLastRevision, err := strconv.ParseBool(r.URL.Query().Get("use_last_revision"))
+	if err != nil {
+		useLastRevision = false
+	}
+	var keys []models.FeatureTag
+	if r.Method == http.MethodPost {
+		err = utils.GetRequestData(r, &keys)
+		if err != nil {
+			utils.WriteErrorMessage(w, http.StatusBadRequest, "error unmarshalling")
+			return
+		}
+	} else {
+		featureId, err := strconv.ParseInt(r.URL.Query().Get("feature_id"), 10, 64)
+		if err != nil {
+			utils.WriteErrorMessage(w, http.StatusBadRequest, "wrong feature_id param")
+			return
+		}
+		for _, tagParam := range r.URL.Query()["tag_id"] {
+			tagId, err := strconv.ParseInt(tagParam, 10, 64)
+			if err != nil {
+				utils.WriteErrorMessage(w, http.StatusBadRequest, "wrong tag_id param")
+				return
+			}
+			keys = append(keys, models.FeatureTag{FeatureId: featureId, TagId: tagId})
+		}
+	}
+
+	result, err := h.uc.GetMany(r.Context(), keys, useLastRevision)
+	if err != nil {
+		if errors.Is(err, banner.ErrEmptyBatch) || errors.Is(err, banner.ErrBatchTooLarge) {
+			utils.WriteErrorMessage(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Cache-Control", "private, no-cache")
+	err = utils.WriteResponseData(w, result, http.StatusOK)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// UpdateBanner changes the given fields of a banner. A change of content, feature_id or tag_ids sends a banner
+// that is not a draft back to draft, is_active, the activation window, weight and impression_cap keep its status
+// for admins only
 func (h *BannerHandler) UpdateBanner(w http.ResponseWriter, r *http.Request) {
 	bannerIdString := mux.Vars(r)["id"]
 	bannerId, err := strconv.ParseInt(bannerIdString, 10, 64)
@@ -148,12 +246,30 @@ func (h *BannerHandler) UpdateBanner(w http.ResponseWriter, r *http.Request) {
 		utils.WriteErrorMessage(w, http.StatusBadRequest, "error unmarshalling")
 		return
 	}
-	err = h.uc.UpdateBanner(r.Context(), item, bannerId)
+	versions, ok := parseIfMatch(r)
+	if !ok {
+		utils.WriteErrorMessage(w, http.StatusPreconditionFailed, banner.ErrVersionMismatch.Error())
+		return
+	}
+	newVersion, err := h.uc.UpdateBanner(r.Context(), item, bannerId, versions)
 	if err != nil {
+		if errors.Is(err, banner.ErrVersionMismatch) {
+			utils.WriteErrorMessage(w, http.StatusPreconditionFailed, err.Error())
+			return
+		}
+		if banner.IsValidationError(err) {
+			utils.WriteErrorMessage(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if errors.Is(err, banner.ErrBannerConflict) {
+			utils.WriteErrorMessage(w, http.StatusConflict, err.Error())
+			return
+		}
 		w.WriteHeader(http.StatusNotFound)
 		return
 	}
 
+	w.Header().Set("ETag", etag(newVersion))
 	w.WriteHeader(http.StatusOK)
 
 }
@@ -164,9 +280,18 @@ func (h *BannerHandler) DeleteBanner(w http.ResponseWriter, r *http.Request) {
 		utils.WriteErrorMessage(w, http.StatusBadRequest, "cant parse id")
 		return
 	}
+	versions, ok := parseIfMatch(r)
+	if !ok {
+		utils.WriteErrorMessage(w, http.StatusPreconditionFailed, banner.ErrVersionMismatch.Error())
+		return
+	}
 
-	err = h.uc.DeleteBanner(r.Context(), bannerId)
+	err = h.uc.DeleteBanner(r.Context(), bannerId, versions)
 	if err != nil {
+		if errors.Is(err, banner.ErrVersionMismatch) {
+			utils.WriteErrorMessage(w, http.StatusPreconditionFailed, err.Error())
+			return
+		}
 		w.WriteHeader(http.StatusNotFound)
 		return
 	}
@@ -174,3 +299,316 @@ func (h *BannerHandler) DeleteBanner(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 
 }
+
+// GetBanner returns a banner by id, its version is sent as ETag to be used in If-Match of PATCH and DELETE
+// for admins only
+func (h *BannerHandler) GetBanner(w http.ResponseWriter, r *http.Request) {
+	bannerIdString := mux.Vars(r)["id"]
+	bannerId, err := strconv.ParseInt(bannerIdString, 10, 64)
+	if err != nil {
+		utils.WriteErrorMessage(w, http.StatusBadRequest, "cant parse id")
+		return
+	}
+
+	result, err := h.uc.GetBanner(r.Context(), bannerId)
+	if err != nil {
+		if errors.Is(err, banner.ErrBannerNotFound) {
+			utils.WriteErrorMessage(w, http.StatusNotFound, err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("ETag", etag(result.Version))
+	err = utils.WriteResponseData(w, result, http.StatusOK)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// GetTrash returns deleted banners which can still be restored, most recently deleted first
+// for admins only
+func (h *BannerHandler) GetTrash(w http.ResponseWriter, r *http.Request) {
+	countParam := r.URL.Query().Get("limit")
+	offsetParam := r.URL.Query().Get("offset")
+	count, err := strconv.ParseInt(countParam, 10, 64)
+	if err != nil && countParam != "" {
+		utils.WriteErrorMessage(w, http.StatusBadRequest, "wrong count param")
+		return
+	}
+	offset, err := strconv.ParseInt(offsetParam, 10, 64)
+	if err != nil && offsetParam != "" {
+		utils.WriteErrorMessage(w, http.StatusBadRequest, "wrong offset param")
+		return
+	}
+
+	result, err := h.uc.GetTrash(r.Context(), count, offset)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	err = utils.WriteResponseData(w, result, http.StatusOK)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// RestoreBanner takes a banner out of trash
+// for admins only
+func (h *BannerHandler) RestoreBanner(w http.ResponseWriter, r *http.Request) {
+	bannerIdString := mux.Vars(r)["id"]
+	bannerId, err := strconv.ParseInt(bannerIdString, 10, 64)
+	if err != nil {
+		utils.WriteErrorMessage(w, http.StatusBadRequest, "cant parse id")
+		return
+	}
+
+	err = h.uc.RestoreBanner(r.Context(), bannerId)
+	if err != nil {
+		if errors.Is(err, banner.ErrBannerNotFound) {
+			utils.WriteErrorMessage(w, http.StatusNotFound, err.Error())
+			return
+		}
+		if errors.Is(err, banner.ErrBannerConflict) {
+			utils.WriteErrorMessage(w, http.StatusConflict, err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// GetRevisions returns the revision history of a banner, newest first
+// for admins only
+func (h *BannerHandler) GetRevisions(w http.ResponseWriter, r *http.Request) {
+	bannerIdString := mux.Vars(r)["id"]
+	bannerId, err := strconv.ParseInt(bannerIdString, 10, 64)
+	if err != nil {
+		utils.WriteErrorMessage(w, http.StatusBadRequest, "cant parse id")
+		return
+	}
+
+	result, err := h.uc.GetRevisions(r.Context(), bannerId)
+	if err != nil {
+		if errors.Is(err, banner.ErrBannerNotFound) {
+			utils.WriteErrorMessage(w, http.StatusNotFound, err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	err = utils.WriteResponseData(w, result, http.StatusOK)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// RestoreRevision rolls a banner back to one of its revisions.
+// A deleted banner has to be restored from trash first
+// for admins only
+func (h *BannerHandler) RestoreRevision(w http.ResponseWriter, r *http.Request) {
+	bannerIdString := mux.Vars(r)["id"]
+	bannerId, err := strconv.ParseInt(bannerIdString, 10, 64)
+	if err != nil {
+		utils.WriteErrorMessage(w, http.StatusBadRequest, "cant parse id")
+		return
+	}
+	revisionString := mux.Vars(r)["revision"]
+	revision, err := strconv.ParseInt(revisionString, 10, 64)
+	if err != nil {
+		utils.WriteErrorMessage(w, http.StatusBadRequest, "cant parse revision")
+		return
+	}
+
+	err = h.uc.RestoreRevision(r.Context(), bannerId, revision)
+	if err != nil {
+		if errors.Is(err, banner.ErrRevisionNotFound) || errors.Is(err, banner.ErrBannerNotFound) {
+			utils.WriteErrorMessage(w, http.StatusNotFound, err.Error())
+			return
+		}
+		if banner.IsValidationError(err) {
+			utils.WriteErrorMessage(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if errors.Is(err, banner.ErrBannerConflict) {
+			utils.WriteErrorMessage(w, http.StatusConflict, err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// DeleteFiltered schedules removal of all banners with given feature_id and/or tag_id.
+// Responds with the job which progress can be read at /api/jobs/{id}
+// for admins only
+func (h *BannerHandler) DeleteFiltered(w http.ResponseWriter, r *http.Request) {
+	featureParam := r.URL.Query().Get("feature_id")
+	tagParam := r.URL.Query().Get("tag_id")
+	featureId, err := strconv.ParseInt(featureParam, 10, 64)
+	if err != nil && featureParam != "" {
+		utils.WriteErrorMessage(w, http.StatusBadRequest, "wrong feature_id param")
+		return
+	}
+	tagId, err := strconv.ParseInt(tagParam, 10, 64)
+	if err != nil && tagParam != "" {
+		utils.WriteErrorMessage(w, http.StatusBadRequest, "wrong tag_id param")
+		return
+	}
+
+	result, err := h.uc.DeleteFiltered(r.Context(), featureId, tagId)
+	if err != nil {
+		if errors.Is(err, banner.ErrEmptyFilter) {
+			utils.WriteErrorMessage(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	err = utils.WriteResponseData(w, result, http.StatusAccepted)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// ImportBanners creates banners from an NDJSON or CSV file of BannerForm records, all or nothing.
+// With dry_run=true rows are only validated. Responds with a per-row error report
+// for admins only
+func (h *BannerHandler) ImportBanners(w http.ResponseWriter, r *http.Request) {
+	format, err := exchangeFormat(r.URL.Query().Get("format"), r.Header.Get("Content-Type"))
+	if err != nil {
+		utils.WriteErrorMessage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	dryRunParam := r.URL.Query().Get("dry_run")
+	dryRun, err := strconv.ParseBool(dryRunParam)
+	if err != nil && dryRunParam != "" {
+		utils.WriteErrorMessage(w, http.StatusBadRequest, "wrong dry_run param")
+		return
+	}
+
+	defer r.Body.Close()
+	rows, parseErrors, err := readImportRows(r.Body, format)
+	if err != nil {
+		utils.WriteErrorMessage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	//строки, которые не удалось разобрать, не дают сохранить остальные
+	report, err := h.uc.ImportBanners(r.Context(), rows, dryRun || len(parseErrors) > 0)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	report.DryRun = dryRun
+	report.Total += len(parseErrors)
+	report.Errors = append(report.Errors, parseErrors...)
+	sort.Slice(report.Errors, func(i, j int) bool {
+		return report.Errors[i].Row < report.Errors[j].Row
+	})
+
+	status := http.StatusOK
+	if len(report.Errors) > 0 {
+		status = http.StatusUnprocessableEntity
+	} else if !dryRun {
+		status = http.StatusCreated
+	}
+	err = utils.WriteResponseData(w, report, status)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// ExportBanners streams banners selected by feature_id and tag_id as NDJSON or CSV,
+// the output can be fed back to ImportBanners
+// for admins only
+func (h *BannerHandler) ExportBanners(w http.ResponseWriter, r *http.Request) {
+	format, err := exchangeFormat(r.URL.Query().Get("format"), "")
+	if err != nil {
+		utils.WriteErrorMessage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	featureParam := r.URL.Query().Get("feature_id")
+	tagParam := r.URL.Query().Get("tag_id")
+	featureId, err := strconv.ParseInt(featureParam, 10, 64)
+	if err != nil && featureParam != "" {
+		utils.WriteErrorMessage(w, http.StatusBadRequest, "wrong feature_id param")
+		return
+	}
+	tagId, err := strconv.ParseInt(tagParam, 10, 64)
+	if err != nil && tagParam != "" {
+		utils.WriteErrorMessage(w, http.StatusBadRequest, "wrong tag_id param")
+		return
+	}
+
+	contentType := ndjsonContentType
+	if format == formatCSV {
+		contentType = csvContentType
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", "attachment; filename=banners."+format)
+	writer, err := newExportWriter(w, format)
+	if err != nil {
+		return
+	}
+	//статус уже отправлен, поэтому ошибку посреди выгрузки можно только залогировать
+	err = h.uc.ExportBanners(r.Context(), featureId, tagId, writer.Write)
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err != nil {
+		fmt.Printf("error while exporting banners:%s\n", err.Error())
+	}
+}
+
+func etag(version int64) string {
+	return strconv.Quote(strconv.FormatInt(version, 10))
+}
+
+// parseIfMatch returns the banner versions listed in If-Match, any of them lets the change through.
+// They are nil when the header is absent or "*" is listed, then only an existing banner is required.
+// ok is false when none of the listed tags can ever match a banner version
+func parseIfMatch(r *http.Request) (versions []int64, ok bool) {
+	values := r.Header.Values("If-Match")
+	if len(values) == 0 {
+		return nil, true
+	}
+	// заголовок может прийти несколькими строками, слабые теги в If-Match никогда не совпадают
+	for _, tag := range strings.Split(strings.Join(values, ","), ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return nil, true
+		}
+		unquoted, err := strconv.Unquote(tag)
+		if err != nil {
+			continue
+		}
+		version, err := strconv.ParseInt(unquoted, 10, 64)
+		if err == nil && version > 0 {
+			versions = append(versions, version)
+		}
+	}
+	return versions, len(versions) > 0
+}
+
+// notModified evaluates If-None-Match and, when it is absent, If-Modified-Since against the served banner
+func notModified(r *http.Request, result models.UserBanner) bool {
+	if header := r.Header.Get("If-None-Match"); header != "" {
+		for _, tag := range strings.Split(header, ",") {
+			tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+			if tag == "*" || tag == strconv.Quote(result.Hash) {
+				return true
+			}
+		}
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil || result.UpdateTime.IsZero() {
+		return false
+	}
+	return !result.UpdateTime.Truncate(time.Second).After(since)
+}
@@ -0,0 +1,153 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Alladan04/avito_test/internal/models"
+	"github.com/Alladan04/avito_test/internal/pkg/banner"
+	"github.com/Alladan04/avito_test/internal/pkg/utils"
+	"github.com/jackc/pgx/v4"
+)
+
+const (
+	// комментарий раз в полминуты не даёт прокси закрыть молчащее соединение
+	streamHeartbeat = 30 * time.Second
+
+	// EventBanner carries the content of the banner served for the pair
+	EventBanner = "banner"
+	// EventNotFound means the pair has no banner any more
+	EventNotFound = "not_found"
+)
+
+type StreamHandler struct {
+	uc  banner.BannerUsecase
+	hub banner.UpdatesHub
+}
+
+func NewStreamHandler(uc banner.BannerUsecase, hub banner.UpdatesHub) *StreamHandler {
+	return &StreamHandler{
+		uc:  uc,
+		hub: hub,
+	}
+}
+
+// Stream keeps a Server-Sent Events connection open and pushes the banner of feature_id and tag_id
+// right away and whenever it is updated, activated or deleted
+func (h *StreamHandler) Stream(w http.ResponseWriter, r *http.Request) {
+	featureId, err := strconv.ParseInt(r.URL.Query().Get("feature_id"), 10, 64)
+	if err != nil {
+		utils.WriteErrorMessage(w, http.StatusBadRequest, "wrong feature_id param")
+		return
+	}
+	tagId, err := strconv.ParseInt(r.URL.Query().Get("tag_id"), 10, 64)
+	if err != nil {
+		utils.WriteErrorMessage(w, http.StatusBadRequest, "wrong tag_id param")
+		return
+	}
+	rc := http.NewResponseController(w)
+	// поток живёт дольше WriteTimeout сервера
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		utils.WriteErrorMessage(w, http.StatusInternalServerError, "streaming is not supported")
+		return
+	}
+	// подписываемся до первого чтения, чтобы не пропустить изменение между ними
+	updates, unsubscribe := h.hub.Subscribe(models.FeatureTag{FeatureId: featureId, TagId: tagId})
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	stream := &bannerStream{w: w, rc: rc}
+	ctx := r.Context()
+	send := func() error {
+		// кеш пропускаем, чтобы не отдать содержимое старше уведомления
+		result, err := h.uc.PickOne(ctx, featureId, tagId, true)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			fmt.Printf("error while reading streamed banner:%s\n", err.Error())
+			return nil
+		}
+		found := err == nil
+		written, err := stream.send(result, found)
+		if err != nil {
+			return err
+		}
+		// показ считаем только когда баннер действительно ушёл клиенту, а не на каждое уведомление
+		if written && found {
+			h.uc.CountImpression(ctx, result)
+		}
+		return nil
+	}
+	if err := send(); err != nil {
+		return
+	}
+	ticker := time.NewTicker(streamHeartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case _, ok := <-updates:
+			if !ok {
+				return
+			}
+			if err := send(); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if err := stream.heartbeat(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// bannerStream writes events to the client, skipping the ones that would not change what it shows
+type bannerStream struct {
+	w      http.ResponseWriter
+	rc     *http.ResponseController
+	sent   bool
+	found  bool
+	hash   string
+	buffer bytes.Buffer
+}
+
+// send writes the event and reports whether it was written or skipped as unchanged
+func (s *bannerStream) send(item models.UserBanner, found bool) (bool, error) {
+	if s.sent && s.found == found && s.hash == item.Hash {
+		return false, nil
+	}
+	s.sent, s.found, s.hash = true, found, item.Hash
+	if !found {
+		_, err := fmt.Fprintf(s.w, "event: %s\ndata: {}\n\n", EventNotFound)
+		if err != nil {
+			return false, err
+		}
+		return true, s.rc.Flush()
+	}
+	// в строке data не должно быть переводов строк
+	s.buffer.Reset()
+	if err := json.Compact(&s.buffer, item.Content); err != nil {
+		return false, err
+	}
+	_, err := fmt.Fprintf(s.w, "event: %s\nid: %s\ndata: %s\n\n", EventBanner, item.Hash, s.buffer.Bytes())
+	if err != nil {
+		return false, err
+	}
+	return true, s.rc.Flush()
+}
+
+func (s *bannerStream) heartbeat() error {
+	if _, err := fmt.Fprint(s.w, ": ping\n\n"); err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
@@ -2,29 +2,117 @@ package banner
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/Alladan04/avito_test/internal/models"
 )
 
+var (
+	ErrBannerNotFound       = errors.New("banner not found")
+	ErrRevisionNotFound     = errors.New("revision not found")
+	ErrEmptyFilter          = errors.New("feature_id or tag_id must be set")
+	ErrInvalidWindow        = errors.New("active_from must be before active_until")
+	ErrInvalidWeight        = errors.New("weight must not be negative")
+	ErrInvalidImpressionCap = errors.New("impression cap must not be negative")
+	ErrBannerConflict       = errors.New("feature and tag are already taken by another banner")
+	ErrInvalidContent       = errors.New("content must be a JSON object")
+	ErrContentMismatch      = errors.New("content does not match the feature schema")
+	ErrVersionMismatch      = errors.New("banner was changed by someone else")
+	ErrEmptyBatch           = errors.New("at least one feature_id and tag_id pair is required")
+	ErrBatchTooLarge        = errors.New("too many feature_id and tag_id pairs")
+	ErrUsedByBanners        = errors.New("used by banners")
+)
+
+// IsValidationError reports whether the banner was rejected because of its own fields
+func IsValidationError(err error) bool {
+	return errors.Is(err, ErrInvalidWindow) ||
+		errors.Is(err, ErrInvalidWeight) ||
+		errors.Is(err, ErrInvalidImpressionCap) ||
+		errors.Is(err, ErrInvalidContent) ||
+		errors.Is(err, ErrContentMismatch)
+}
+
 type BannerRepo interface {
-	AddItem(ctx context.Context, item models.Banner) error
+	AddItem(ctx context.Context, item models.Banner, author string) (int64, error)
 	GetById(ctx context.Context, id int64) (models.BannerForm, error)
-	UpdateBanner(ctx context.Context, banner models.BannerForm, id int64) error
+	GetBanner(ctx context.Context, id int64) (models.Banner, error)
+	UpdateBanner(ctx context.Context, banner models.BannerForm, id int64, versions []int64, author string) (int64, error)
 	//GetAll(ctx context.Context, count int64, offset int64) ([]models.Banner, error)
-	GetOne(ctx context.Context, featureId int64, tagId int64) (models.BannerContent, error)
+	GetOne(ctx context.Context, featureId int64, tagId int64, withInactive bool) ([]models.UserBanner, error)
+	GetMany(ctx context.Context, keys []models.FeatureTag, withInactive bool) (map[models.FeatureTag][]models.UserBanner, error)
+	GetFallback(ctx context.Context, featureId int64, withInactive bool) (models.UserBanner, error)
 	GetAllFiltered(ctx context.Context, count int64, offset int64, featureId int64, tagId int64) ([]models.Banner, error)
-	DeleteBanner(ctx context.Context, id int64) error
+	DeleteBanner(ctx context.Context, id int64, versions []int64, author string) error
+	GetRevisions(ctx context.Context, id int64) ([]models.BannerRevision, error)
+	GetRevision(ctx context.Context, id int64, revision int64) (models.BannerRevision, error)
+	CountFiltered(ctx context.Context, featureId int64, tagId int64) (int64, error)
+	DeleteFiltered(ctx context.Context, featureId int64, tagId int64, limit int64, author string) ([]models.FeatureTag, int64, error)
+	ApplySchedule(ctx context.Context, now time.Time) ([]models.FeatureTag, error)
+	AddItems(ctx context.Context, items []models.Banner, author string, commit bool) ([]error, error)
+	ExportFiltered(ctx context.Context, featureId int64, tagId int64, fn func(models.BannerForm) error) error
+	GetContentSchema(ctx context.Context, featureId int64) ([]byte, error)
+	GetTrash(ctx context.Context, count int64, offset int64) ([]models.Banner, error)
+	RestoreBanner(ctx context.Context, id int64, author string) ([]models.FeatureTag, error)
+	PurgeDeleted(ctx context.Context, before time.Time, limit int64) (int64, error)
 }
 
 type BannerUsecase interface {
 	AddItem(ctx context.Context, data models.BannerForm) (models.Banner, error)
-	GetOne(ctx context.Context, featureId int64, tagId int64, showLastRevision bool) (models.BannerContent, error)
+	GetOne(ctx context.Context, featureId int64, tagId int64, showLastRevision bool) (models.UserBanner, error)
+	// PickOne chooses the banner like GetOne without counting it, CountImpression counts it once it is shown
+	PickOne(ctx context.Context, featureId int64, tagId int64, showLastRevision bool) (models.UserBanner, error)
+	CountImpression(ctx context.Context, item models.UserBanner)
+	GetMany(ctx context.Context, keys []models.FeatureTag, showLastRevision bool) (map[string]models.UserBannerResult, error)
 	GetAll(ctx context.Context, count int64, offset int64, featureId int64, tagId int64) ([]models.Banner, error)
-	UpdateBanner(ctx context.Context, payload models.BannerUpdateForm, id int64) error
-	DeleteBanner(ctx context.Context, id int64) error
+	GetBanner(ctx context.Context, id int64) (models.Banner, error)
+	UpdateBanner(ctx context.Context, payload models.BannerUpdateForm, id int64, versions []int64) (int64, error)
+	DeleteBanner(ctx context.Context, id int64, versions []int64) error
+	GetRevisions(ctx context.Context, id int64) ([]models.BannerRevision, error)
+	RestoreRevision(ctx context.Context, id int64, revision int64) error
+	DeleteFiltered(ctx context.Context, featureId int64, tagId int64) (models.Job, error)
+	ImportBanners(ctx context.Context, rows []models.ImportRow, dryRun bool) (models.ImportReport, error)
+	ExportBanners(ctx context.Context, featureId int64, tagId int64, fn func(models.BannerForm) error) error
+	GetTrash(ctx context.Context, count int64, offset int64) ([]models.Banner, error)
+	RestoreBanner(ctx context.Context, id int64) error
+}
+
+// ImpressionRepo counts daily banner impressions per user for frequency capping
+type ImpressionRepo interface {
+	AddImpression(ctx context.Context, username string, bannerId int64, day time.Time) (int64, error)
+	GetImpressions(ctx context.Context, username string, bannerId int64, day time.Time) (int64, error)
 }
 
 type CacheRepo interface {
-	GetBanner(ctx context.Context, featureId int64, tagId int64) (models.BannerContent, error)
-	AddBanner(ctx context.Context, featureId int64, tagId int64, banner models.BannerContent) error
+	GetBanners(ctx context.Context, featureId int64, tagId int64, admin bool) ([]models.UserBanner, error)
+	AddBanners(ctx context.Context, featureId int64, tagId int64, admin bool, banners []models.UserBanner) error
+	// GetManyBanners reads the pairs and the defaults of the features together
+	GetManyBanners(ctx context.Context, keys []models.FeatureTag, featureIds []int64, admin bool) ([][]models.UserBanner, []*models.UserBanner, error)
+	AddManyBanners(ctx context.Context, admin bool, banners map[models.FeatureTag][]models.UserBanner) error
+	GetFallbacks(ctx context.Context, featureIds []int64, admin bool) ([]*models.UserBanner, error)
+	AddFallbacks(ctx context.Context, admin bool, banners map[int64]models.UserBanner) error
+	DeleteFallback(ctx context.Context, featureId int64) error
+	// DeleteBanners evicts the pairs after their banners changed and notifies streams of the pairs
+	DeleteBanners(ctx context.Context, keys []models.FeatureTag) error
+	// not-found markers answer pairs without banners, DeleteBanners and DeleteFallback clear them too
+	AddNotFound(ctx context.Context, featureId int64, tagId int64, admin bool) error
+	IsNotFound(ctx context.Context, featureId int64, tagId int64, admin bool) (bool, error)
+	DeleteNotFound(ctx context.Context, keys []models.FeatureTag) error
+	SubscribeUpdates(ctx context.Context) <-chan []models.FeatureTag
+}
+
+// LockRepo lets one replica at a time rebuild the cache of a pair after it expired
+type LockRepo interface {
+	LockPair(ctx context.Context, key models.FeatureTag, admin bool, ttl time.Duration) (token string, ok bool, err error)
+	UnlockPair(ctx context.Context, key models.FeatureTag, admin bool, token string) error
+}
+
+// CacheStats reports hits, misses and evictions of the banner cache tiers
+type CacheStats interface {
+	Stats() models.CacheStats
+}
+
+// UpdatesHub notifies streams of the replica that banners of their pair changed
+type UpdatesHub interface {
+	Subscribe(key models.FeatureTag) (<-chan struct{}, func())
 }
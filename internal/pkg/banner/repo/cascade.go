@@ -0,0 +1,162 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/Alladan04/avito_test/internal/models"
+	auditRepo "github.com/Alladan04/avito_test/internal/pkg/audit/repo"
+	"github.com/Alladan04/avito_test/internal/pkg/banner"
+	webhookRepo "github.com/Alladan04/avito_test/internal/pkg/webhook/repo"
+	"github.com/jackc/pgx/v4"
+)
+
+const (
+	// баннеры, в том числе из корзины; новые не появятся, пока фича или тег заблокированы вызывающим
+	lockFeatureBanners = `SELECT id FROM banner WHERE feature_id=$1 ORDER BY id FOR UPDATE;`
+	lockTagBanners     = `SELECT b.id FROM banner b
+					JOIN banner_tag bt ON bt.banner_id = b.id
+					WHERE bt.tag_id=$1
+					ORDER BY b.id
+					FOR UPDATE OF b;`
+	purgeFeatureBannerTags = `DELETE FROM banner_tag WHERE feature_id=$1;`
+	purgeFeatureBanners    = `DELETE FROM banner WHERE feature_id=$1;`
+	unlinkTag              = `DELETE FROM banner_tag WHERE banner_id=$1 AND tag_id=$2;`
+	touchBanner            = `UPDATE banner SET update_time=$1, version=version+1 WHERE id=$2;`
+)
+
+// DeleteFeatureBanners removes the banners of the feature before the feature itself is deleted with tx,
+// which must hold the lock of the feature row. Without cascade a used feature is left as is and
+// banner.ErrUsedByBanners is returned. Live banners go through trash like in DeleteBanner and are removed
+// then together with the ones already there: a banner cannot outlive its feature.
+// It returns the feature:tag pairs that were served by the removed banners
+func DeleteFeatureBanners(ctx context.Context, tx pgx.Tx, featureId int64, cascade bool, author string) ([]models.FeatureTag, error) {
+	ids, err := lockUsers(ctx, tx, lockFeatureBanners, featureId, cascade)
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+	deleteTime := time.Now().UTC()
+	keys := make([]models.FeatureTag, 0, len(ids))
+	for _, id := range ids {
+		before, err := getBannerTx(ctx, tx, id)
+		if err != nil {
+			return nil, err
+		}
+		if before.DeletedAt != nil {
+			continue
+		}
+		err = trashBanner(ctx, tx, before, author, deleteTime)
+		if err != nil {
+			return nil, err
+		}
+		for _, tagId := range before.TagIds {
+			keys = append(keys, models.FeatureTag{FeatureId: featureId, TagId: tagId})
+		}
+	}
+	_, err = tx.Exec(ctx, purgeFeatureBannerTags, featureId)
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.Exec(ctx, purgeFeatureBanners, featureId)
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// UnlinkTagBanners unlinks the banners from the tag before the tag itself is deleted with tx,
+// which must hold the lock of the tag row. Without cascade a used tag is left as is and
+// banner.ErrUsedByBanners is returned. Every unlinked banner gets a new version and an audit entry.
+// It returns the feature:tag pairs that were served by the unlinked banners
+func UnlinkTagBanners(ctx context.Context, tx pgx.Tx, tagId int64, cascade bool, author string) ([]models.FeatureTag, error) {
+	ids, err := lockUsers(ctx, tx, lockTagBanners, tagId, cascade)
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+	updateTime := time.Now().UTC()
+	keys := make([]models.FeatureTag, 0, len(ids))
+	for _, id := range ids {
+		before, err := getBannerTx(ctx, tx, id)
+		if err != nil {
+			return nil, err
+		}
+		_, err = tx.Exec(ctx, unlinkTag, id, tagId)
+		if err != nil {
+			return nil, err
+		}
+		_, err = tx.Exec(ctx, touchBanner, updateTime, id)
+		if err != nil {
+			return nil, err
+		}
+		after, err := getBannerTx(ctx, tx, id)
+		if err != nil {
+			return nil, err
+		}
+		err = auditRepo.AddEntry(ctx, tx, models.AuditEntry{
+			Actor:      author,
+			Action:     models.AuditActionUpdate,
+			Entity:     models.AuditEntityBanner,
+			EntityId:   id,
+			Before:     models.AuditSnapshot(before),
+			After:      models.AuditSnapshot(after),
+			CreateTime: updateTime,
+		})
+		if err != nil {
+			return nil, err
+		}
+		//баннер из корзины подписчикам не показываем, он вернётся уже без тега
+		if before.DeletedAt != nil {
+			continue
+		}
+		err = webhookRepo.AddEvent(ctx, tx, models.WebhookEventBannerUpdated, id, after, updateTime)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, models.FeatureTag{FeatureId: before.FeatureId, TagId: tagId})
+	}
+	return keys, nil
+}
+
+// lockUsers locks the banners query returns for id, used feature or tag without cascade is an error
+func lockUsers(ctx context.Context, tx pgx.Tx, query string, id int64, cascade bool) ([]int64, error) {
+	rows, err := tx.Query(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ids := make([]int64, 0)
+	for rows.Next() {
+		var bannerId int64
+		if err := rows.Scan(&bannerId); err != nil {
+			return nil, err
+		}
+		ids = append(ids, bannerId)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(ids) > 0 && !cascade {
+		return nil, banner.ErrUsedByBanners
+	}
+	return ids, nil
+}
+
+// trashBanner moves the locked banner to trash with an audit entry and a webhook event
+func trashBanner(ctx context.Context, tx pgx.Tx, before models.Banner, author string, deleteTime time.Time) error {
+	_, err := tx.Exec(ctx, softDeleteBanner, deleteTime, before.Id)
+	if err != nil {
+		return err
+	}
+	err = auditRepo.AddEntry(ctx, tx, models.AuditEntry{
+		Actor:      author,
+		Action:     models.AuditActionDelete,
+		Entity:     models.AuditEntityBanner,
+		EntityId:   before.Id,
+		Before:     models.AuditSnapshot(before),
+		CreateTime: deleteTime,
+	})
+	if err != nil {
+		return err
+	}
+	return webhookRepo.AddEvent(ctx, tx, models.WebhookEventBannerDeleted, before.Id, before, deleteTime)
+}
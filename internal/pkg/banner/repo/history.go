@@ -0,0 +1,32 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/Alladan04/avito_test/internal/models"
+	webhookRepo "github.com/Alladan04/avito_test/internal/pkg/webhook/repo"
+	"github.com/jackc/pgtype/pgxtype"
+)
+
+const (
+	addTransition = `INSERT INTO banner_transition (banner_id, action, from_status, to_status, actor, create_time)
+					VALUES ($1, $2, $3, $4, $5, $6);`
+)
+
+// AddTransitionEntry records the transition in the history of the banner without changing its status.
+// Repos call it with their transaction when a change of the banner moves it to another status
+func AddTransitionEntry(ctx context.Context, db pgxtype.Querier, transition models.BannerTransition) error {
+	_, err := db.Exec(ctx, addTransition, transition.BannerId, transition.Action, transition.FromStatus, transition.ToStatus, transition.Actor, transition.CreateTime)
+	return err
+}
+
+// AddBannerEvent queues event with the banner as it is seen by db.
+// Repos call it with their transaction after changing the banner
+func AddBannerEvent(ctx context.Context, db pgxtype.Querier, event string, id int64, at time.Time) error {
+	item, err := getBannerTx(ctx, db, id)
+	if err != nil {
+		return err
+	}
+	return webhookRepo.AddEvent(ctx, db, event, id, item, at)
+}
@@ -0,0 +1,50 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// impressionsTTL outlives the day of the counter, so a counter is never lost before its day ends
+const impressionsTTL = time.Hour * 48
+
+type ImpressionRepo struct {
+	db redis.Client
+}
+
+func NewImpressionRepo(db redis.Client) *ImpressionRepo {
+	return &ImpressionRepo{
+		db: db,
+	}
+}
+
+// счётчик живёт в пределах суток по UTC
+func impressionsKey(username string, bannerId int64, day time.Time) string {
+	return fmt.Sprintf("impressions:%s:%d:%s", day.UTC().Format(time.DateOnly), bannerId, username)
+}
+
+// AddImpression counts one more impression of the banner for the user and returns the number of impressions that day
+func (repo *ImpressionRepo) AddImpression(ctx context.Context, username string, bannerId int64, day time.Time) (int64, error) {
+	key := impressionsKey(username, bannerId, day)
+	pipe := repo.db.TxPipeline()
+	count := pipe.Incr(ctx, key)
+	pipe.Expire(ctx, key, impressionsTTL)
+	_, err := pipe.Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return count.Val(), nil
+}
+
+// GetImpressions returns the number of impressions of the banner for the user that day without counting a new one
+func (repo *ImpressionRepo) GetImpressions(ctx context.Context, username string, bannerId int64, day time.Time) (int64, error) {
+	count, err := repo.db.Get(ctx, impressionsKey(username, bannerId, day)).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	return count, err
+}
@@ -0,0 +1,54 @@
+package repo
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/Alladan04/avito_test/internal/models"
+	"github.com/redis/go-redis/v9"
+)
+
+// unlockScript deletes the lock only if it is still held by the same token,
+// so a replica whose lock expired cannot release the lock taken by another one
+var unlockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// LockRepo keeps short-lived locks in redis that let one replica at a time rebuild the cache of a pair
+type LockRepo struct {
+	db redis.Client
+}
+
+func NewLockRepo(db redis.Client) *LockRepo {
+	return &LockRepo{
+		db: db,
+	}
+}
+
+func lockKey(key models.FeatureTag, admin bool) string {
+	return "lock:" + viewKey(key.FeatureId, key.TagId, admin)
+}
+
+// LockPair takes the lock of the pair for ttl, ok is false when another replica holds it
+func (repo *LockRepo) LockPair(ctx context.Context, key models.FeatureTag, admin bool, ttl time.Duration) (token string, ok bool, err error) {
+	value := make([]byte, 16)
+	_, err = rand.Read(value)
+	if err != nil {
+		return "", false, err
+	}
+	token = hex.EncodeToString(value)
+	ok, err = repo.db.SetNX(ctx, lockKey(key, admin), token, ttl).Result()
+	if err != nil || !ok {
+		return "", false, err
+	}
+	return token, true, nil
+}
+
+func (repo *LockRepo) UnlockPair(ctx context.Context, key models.FeatureTag, admin bool, token string) error {
+	return unlockScript.Run(ctx, &repo.db, []string{lockKey(key, admin)}, token).Err()
+}
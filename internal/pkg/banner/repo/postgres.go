@@ -2,44 +2,158 @@ package repo
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
 	"strconv"
 	"time"
 
 	"github.com/Alladan04/avito_test/internal/models"
+	auditRepo "github.com/Alladan04/avito_test/internal/pkg/audit/repo"
+	"github.com/Alladan04/avito_test/internal/pkg/banner"
+	webhookRepo "github.com/Alladan04/avito_test/internal/pkg/webhook/repo"
 	"github.com/jackc/pgtype/pgxtype"
 	"github.com/jackc/pgx/v4"
 )
 
 const (
-	addItem              = "INSERT INTO banner (title, feature_id, banner_data, url,  create_time, update_time) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id;"
-	getAll               = "SELECT id, title, feature_id, banner_data, url,  create_time, update_time, is_active FROM banner LIMIT $1 OFFSET $2; "
+	addItem              = "INSERT INTO banner (content, feature_id, is_active, active_from, active_until, weight, impression_cap, create_time, update_time) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id;"
+	getAll               = "SELECT id, content, feature_id, create_time, update_time, is_active FROM banner LIMIT $1 OFFSET $2; "
 	getTagsForBanner     = "SELECT tag_id from banner_tag WHERE banner_id=$1;"
-	getBannersWithTagIds = `SELECT b.id, b.title, b.feature_id, b.banner_data, b.url,  b.create_time, b.update_time, b.is_active , ARRAY_AGG(bt.tag_id)
+	getBannersWithTagIds = `SELECT b.id, b.content, b.feature_id, b.create_time, b.update_time, b.is_active, b.active_from, b.active_until, b.weight, b.impression_cap, b.version, b.status, ARRAY_AGG(bt.tag_id)
 						FROM banner b
 						JOIN banner_tag bt ON b.id = bt.banner_id
-						Where b.feature_id=%s AND bt.tag_id=%s
+						Where b.feature_id=%s AND bt.tag_id=%s AND b.deleted_at IS NULL
 						GROUP BY b.id
 						LIMIT $1 OFFSET $2; ;
 					`
 	addBT          = "INSERT INTO banner_tag (banner_id, tag_id, feature_id) VALUES ($1, $2, $3);"
-	getAllFiltered = `SELECT id, title, feature_id, banner_data, url,  create_time, update_time, is_active 
+	getAllFiltered = `SELECT id, content, feature_id, create_time, update_time, is_active 
 					FROM banner 
 					WHERE feature_id=%s 
 					LIMIT $1 OFFSET $2; `
-	getContent = `SELECT b.title, b.banner_data, b.url FROM banner b 
-					JOIN banner_tag bt ON b.id = bt.banner_id 
-					WHERE bt.tag_id = $1 AND bt.feature_id = $2 AND b.is_active='true'; `
-	getById = `SELECT  b.title, b.feature_id, b.banner_data, b.url,  b.is_active,
+	// баннер виден пользователям; %[1]s - момент времени, с %[2]s = true (просмотр админом)
+	// отдаём и выключенные баннеры, вне зависимости от окна активности
+	visibleBanner = `b.status='published' AND b.deleted_at IS NULL
+					AND (%[2]s OR (b.is_active='true'
+						AND (b.active_from IS NULL OR b.active_from <= %[1]s)
+						AND (b.active_until IS NULL OR b.active_until > %[1]s)))`
+	userBannerColumns = `b.id, b.content, b.active_until, b.weight, b.update_time, b.is_active, b.impression_cap`
+	// вариант эксперимента может делить пару фича-тег только с другими вариантами
+	lockFeature        = `SELECT pg_advisory_xact_lock($1);`
+	countConflictingBT = `SELECT count(*) FROM banner_tag bt
+					JOIN banner b ON b.id = bt.banner_id
+					WHERE bt.feature_id = $1 AND bt.tag_id = ANY($2) AND bt.banner_id <> $3 AND b.deleted_at IS NULL
+					AND (b.weight = 0 OR $4 = 0);`
+	getById = `SELECT  b.content, b.feature_id, b.is_active, b.active_from, b.active_until, b.weight, b.impression_cap,
 					coalesce(array_agg(bt.tag_id) filter (where bt.tag_id is not null), '{}')
 					FROM banner b
 					LEFT JOIN banner_tag bt ON b.id = bt.banner_id
+					WHERE b.id=$1 AND b.deleted_at IS NULL
+					GROUP BY b.id;`
+	selectBanner = `SELECT b.id, b.content, b.feature_id, b.create_time, b.update_time, b.is_active, b.active_from, b.active_until, b.weight, b.impression_cap, b.version, b.status, b.deleted_at,
+					coalesce(array_agg(bt.tag_id ORDER BY bt.tag_id) filter (where bt.tag_id is not null), '{}')
+					FROM banner b
+					LEFT JOIN banner_tag bt ON b.id = bt.banner_id`
+	getBanner = selectBanner + `
 					WHERE b.id=$1
 					GROUP BY b.id;`
-	updateBanner = `UPDATE banner SET title=$1, feature_id=$2, banner_data=$3, url=$4, is_active=$5, update_time=$6
-							WHERE id=$7;  `
+	getTrash = selectBanner + `
+					WHERE b.deleted_at IS NOT NULL
+					GROUP BY b.id
+					ORDER BY b.deleted_at DESC, b.id
+					LIMIT $1 OFFSET $2;`
+	// блокируем баннер до конца транзакции, чтобы версию никто не поменял между проверкой и записью
+	lockBannerVersion = `SELECT version FROM banner WHERE id=$1 AND deleted_at IS NULL FOR UPDATE;`
+	// баннер с новым содержимым, фичей или тегами снова проходит ревью: опубликованный уходит из выдачи до нового одобрения.
+	// включение, вес, окно показа и лимит показов меняются без ревью ($10 - поменялись ли теги)
+	updateBanner = `UPDATE banner SET content=$1, feature_id=$2, is_active=$3, active_from=$4, active_until=$5, weight=$6, impression_cap=$7, update_time=$8,
+							version=version+1,
+							status=CASE WHEN content IS DISTINCT FROM $1 OR feature_id <> $2 OR $10 THEN '` + models.BannerStatusDraft + `' ELSE status END
+							WHERE id=$9
+							RETURNING version, status;`
 	deleteTagsByBannerId = `DELETE FROM banner_tag WHERE banner_id=$1;`
-	deleteBannerById     = `DELETE FROM banner WHERE id = $1;`
+	// баннер уходит в корзину вместе со связями с тегами, чтобы его можно было восстановить
+	softDeleteBanner  = `UPDATE banner SET deleted_at=$1, update_time=$1, version=version+1 WHERE id = $2;`
+	lockDeletedBanner = `SELECT feature_id, weight FROM banner WHERE id=$1 AND deleted_at IS NOT NULL FOR UPDATE;`
+	restoreBanner     = `UPDATE banner SET deleted_at=NULL, update_time=$1, version=version+1 WHERE id=$2;`
+	// связи с тегами удаляются в том же запросе, что и сами баннеры
+	purgeDeleted = `WITH purged AS (
+						SELECT id FROM banner WHERE deleted_at < $1 ORDER BY id LIMIT $2 FOR UPDATE SKIP LOCKED
+					), unlinked AS (
+						DELETE FROM banner_tag WHERE banner_id IN (SELECT id FROM purged)
+					)
+					DELETE FROM banner WHERE id IN (SELECT id FROM purged);`
+	addRevision = `INSERT INTO banner_revision (banner_id, revision, content, feature_id, tag_ids, is_active, active_from, active_until, weight, impression_cap, author, create_time)
+					SELECT $1, coalesce(max(revision), 0) + 1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11
+					FROM banner_revision
+					WHERE banner_id=$1;`
+	filterBanners = `FROM banner b
+					WHERE b.deleted_at IS NULL AND ($1 = 0 OR b.feature_id = $1)
+					AND ($2 = 0 OR EXISTS (SELECT 1 FROM banner_tag bt WHERE bt.banner_id = b.id AND bt.tag_id = $2))`
+	countFiltered = `SELECT count(*) ` + filterBanners + `;`
+	lockFiltered  = `SELECT b.id ` + filterBanners + ` ORDER BY b.id LIMIT $3 FOR UPDATE;`
+	// баннер без тегов отдаётся только как баннер фичи по умолчанию, ему достаётся пара с нулевым тегом (см. models.BannerPairs)
+	getTagsByBannerIds = `SELECT b.feature_id, coalesce(bt.tag_id, 0) FROM banner b
+					LEFT JOIN banner_tag bt ON bt.banner_id = b.id
+					WHERE b.id = ANY($1);`
+	// включаем и выключаем баннеры, у которых началось или закончилось окно активности
+	applySchedule = `UPDATE banner b SET is_active = NOT b.is_active, update_time = $1
+					WHERE (b.active_from IS NOT NULL OR b.active_until IS NOT NULL) AND b.deleted_at IS NULL
+					AND b.is_active <> ((b.active_from IS NULL OR b.active_from <= $1) AND (b.active_until IS NULL OR b.active_until > $1))
+					RETURNING b.id;`
+	exportFiltered = `SELECT b.content, b.feature_id, b.is_active, b.active_from, b.active_until, b.weight, b.impression_cap,
+					coalesce(array_agg(bt.tag_id ORDER BY bt.tag_id) filter (where bt.tag_id is not null), '{}')
+					FROM banner b
+					LEFT JOIN banner_tag bt ON b.id = bt.banner_id
+					WHERE b.deleted_at IS NULL AND ($1 = 0 OR b.feature_id = $1)
+					AND ($2 = 0 OR EXISTS (SELECT 1 FROM banner_tag f WHERE f.banner_id = b.id AND f.tag_id = $2))
+					GROUP BY b.id
+					ORDER BY b.id;`
+	getContentSchema = `SELECT content_schema FROM feature WHERE id=$1;`
+	getRevisions     = `SELECT banner_id, revision, content, feature_id, tag_ids, is_active, active_from, active_until, weight, impression_cap, author, create_time
+					FROM banner_revision
+					WHERE banner_id=$1
+					ORDER BY revision DESC;`
+	getRevision = `SELECT banner_id, revision, content, feature_id, tag_ids, is_active, active_from, active_until, weight, impression_cap, author, create_time
+					FROM banner_revision
+					WHERE banner_id=$1 AND revision=$2;`
+)
+
+var (
+	// баннеры пары фича-тег, а если таких нет - баннер фичи по умолчанию (последняя колонка - признак подмены)
+	getContent = `WITH direct AS (
+						SELECT ` + userBannerColumns + `, false AS fallback FROM banner b
+						JOIN banner_tag bt ON b.id = bt.banner_id
+						WHERE bt.tag_id = $1 AND bt.feature_id = $2 AND ` + fmt.Sprintf(visibleBanner, "$3", "$4") + `
+					)
+					SELECT * FROM direct
+					UNION ALL
+					SELECT ` + userBannerColumns + `, true FROM feature f
+					JOIN banner b ON b.id = f.default_banner_id AND b.feature_id = f.id
+					WHERE f.id = $2 AND ` + fmt.Sprintf(visibleBanner, "$3", "$4") + `
+					AND NOT EXISTS (SELECT 1 FROM direct)
+					ORDER BY id;`
+	// то же для нескольких пар, $1 и $2 - параллельные массивы фич и тегов
+	getContentMany = `WITH k AS (
+						SELECT * FROM unnest($1::bigint[], $2::bigint[]) AS k(feature_id, tag_id)
+					), direct AS (
+						SELECT k.feature_id, k.tag_id, ` + userBannerColumns + `, false AS fallback FROM k
+						JOIN banner_tag bt ON bt.feature_id = k.feature_id AND bt.tag_id = k.tag_id
+						JOIN banner b ON b.id = bt.banner_id
+						WHERE ` + fmt.Sprintf(visibleBanner, "$3", "$4") + `
+					)
+					SELECT * FROM direct
+					UNION ALL
+					SELECT k.feature_id, k.tag_id, ` + userBannerColumns + `, true FROM k
+					JOIN feature f ON f.id = k.feature_id
+					JOIN banner b ON b.id = f.default_banner_id AND b.feature_id = f.id
+					WHERE ` + fmt.Sprintf(visibleBanner, "$3", "$4") + `
+					AND NOT EXISTS (SELECT 1 FROM direct d WHERE d.feature_id = k.feature_id AND d.tag_id = k.tag_id)
+					ORDER BY id;`
+	getFallback = `SELECT ` + userBannerColumns + `, true FROM feature f
+					JOIN banner b ON b.id = f.default_banner_id AND b.feature_id = f.id
+					WHERE f.id = $1 AND ` + fmt.Sprintf(visibleBanner, "$2", "$3") + `;`
 )
 
 type BannerRepo struct {
@@ -54,10 +168,10 @@ func NewBannerRepo(db pgxtype.Querier, conn pgx.Conn) *BannerRepo {
 	}
 }
 
-func (repo *BannerRepo) AddItem(ctx context.Context, item models.Banner) error {
+func (repo *BannerRepo) AddItem(ctx context.Context, item models.Banner, author string) (int64, error) {
 	tx, err := repo.conn.Begin(ctx)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	defer func() {
@@ -66,23 +180,95 @@ func (repo *BannerRepo) AddItem(ctx context.Context, item models.Banner) error {
 		}
 	}()
 
-	row := tx.QueryRow(ctx, addItem, item.Content.Title, item.FeatureId, item.Content.Data, item.Content.Url, item.CreateTime, item.UpdateTime)
+	id, err := addItemTx(ctx, tx, item, author)
+	if err != nil {
+		return 0, err
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+
+}
+
+// AddItems inserts the banners in a single transaction, each banner under its own savepoint.
+// Errors are returned per banner, the transaction is committed only when commit is set and every banner was inserted
+func (repo *BannerRepo) AddItems(ctx context.Context, items []models.Banner, author string, commit bool) ([]error, error) {
+	tx, err := repo.conn.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err = tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			fmt.Printf("ERROR: %v", err)
+		}
+	}()
+
+	rowErrors := make([]error, len(items))
+	failed := false
+	for i, item := range items {
+		savepoint, err := tx.Begin(ctx)
+		if err != nil {
+			return nil, err
+		}
+		_, rowErrors[i] = addItemTx(ctx, savepoint, item, author)
+		if rowErrors[i] != nil {
+			failed = true
+			err = savepoint.Rollback(ctx)
+		} else {
+			err = savepoint.Commit(ctx)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if commit && !failed {
+		err = tx.Commit(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return rowErrors, nil
+}
+
+func addItemTx(ctx context.Context, tx pgx.Tx, item models.Banner, author string) (int64, error) {
+	err := checkConflicts(ctx, tx, item.FeatureId, item.TagIds, 0, item.Weight)
+	if err != nil {
+		return 0, err
+	}
+	row := tx.QueryRow(ctx, addItem, []byte(item.Content), item.FeatureId, item.IsActive, item.ActiveFrom, item.ActiveUntil, item.Weight, item.ImpressionCap, item.CreateTime, item.UpdateTime)
 	err = row.Scan(&item.Id)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	for _, tag := range item.TagIds {
 		_, err = tx.Exec(ctx, addBT, item.Id, tag, item.FeatureId)
 		if err != nil {
-			return err
+			return 0, err
 		}
 	}
-	err = tx.Commit(ctx)
+	//сохраняем первую ревизию баннера
+	_, err = tx.Exec(ctx, addRevision, item.Id, []byte(item.Content), item.FeatureId, nonNilTags(item.TagIds), item.IsActive, item.ActiveFrom, item.ActiveUntil, item.Weight, item.ImpressionCap, author, item.CreateTime)
 	if err != nil {
-		return err
+		return 0, err
 	}
-	return nil
-
+	err = auditRepo.AddEntry(ctx, tx, models.AuditEntry{
+		Actor:      author,
+		Action:     models.AuditActionCreate,
+		Entity:     models.AuditEntityBanner,
+		EntityId:   item.Id,
+		After:      models.AuditSnapshot(item),
+		CreateTime: item.CreateTime,
+	})
+	if err != nil {
+		return 0, err
+	}
+	err = webhookRepo.AddEvent(ctx, tx, models.WebhookEventBannerCreated, item.Id, item, item.CreateTime)
+	if err != nil {
+		return 0, err
+	}
+	return item.Id, nil
 }
 
 func (repo *BannerRepo) GetAllFiltered(ctx context.Context, count int64, offset int64, featureId int64, tagId int64) ([]models.Banner, error) {
@@ -104,7 +290,7 @@ func (repo *BannerRepo) GetAllFiltered(ctx context.Context, count int64, offset
 	}
 	for rows.Next() {
 		var item models.Banner
-		if err := rows.Scan(&item.Id, &item.Content.Title, &item.FeatureId, &item.Content.Data, &item.Content.Url, &item.CreateTime, &item.UpdateTime, &item.IsActive, &item.TagIds); err != nil {
+		if err := rows.Scan(&item.Id, (*[]byte)(&item.Content), &item.FeatureId, &item.CreateTime, &item.UpdateTime, &item.IsActive, &item.ActiveFrom, &item.ActiveUntil, &item.Weight, &item.ImpressionCap, &item.Version, &item.Status, &item.TagIds); err != nil {
 			return nil, fmt.Errorf("error occured while scanning items:%w", err)
 		}
 
@@ -114,28 +300,83 @@ func (repo *BannerRepo) GetAllFiltered(ctx context.Context, count int64, offset
 	return result, nil
 }
 
-func (repo *BannerRepo) GetOne(ctx context.Context, featureId int64, tagId int64) (models.BannerContent, error) {
-	var result models.BannerContent
-	err := repo.db.QueryRow(ctx, getContent, tagId, featureId).Scan(
-		&result.Title,
-		&result.Data,
-		&result.Url,
-	)
+// GetOne returns active banners for feature and tag which are inside their activation window,
+// withInactive returns inactive ones as well. When the pair has no such banners,
+// the default banner of the feature is returned with Fallback set.
+// There is more than one banner only when the pair runs an experiment, banners are ordered by id
+func (repo *BannerRepo) GetOne(ctx context.Context, featureId int64, tagId int64, withInactive bool) ([]models.UserBanner, error) {
+	rows, err := repo.db.Query(ctx, getContent, tagId, featureId, time.Now().UTC(), withInactive)
 	if err != nil {
-		return models.BannerContent{}, err
+		return nil, err
+	}
+	defer rows.Close()
+	result := make([]models.UserBanner, 0, 1)
+	for rows.Next() {
+		var item models.UserBanner
+		if err := rows.Scan(&item.Id, (*[]byte)(&item.Content), &item.ActiveUntil, &item.Weight, &item.UpdateTime, &item.IsActive, &item.ImpressionCap, &item.Fallback); err != nil {
+			return nil, fmt.Errorf("error occured while scanning banners:%w", err)
+		}
+		item.Hash = item.Content.Hash()
+		result = append(result, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(result) == 0 {
+		return nil, pgx.ErrNoRows
 	}
 	return result, nil
 }
 
+// GetMany does GetOne for several feature:tag pairs in a single query.
+// Pairs without banners and without a feature default are absent from the result
+func (repo *BannerRepo) GetMany(ctx context.Context, keys []models.FeatureTag, withInactive bool) (map[models.FeatureTag][]models.UserBanner, error) {
+	featureIds := make([]int64, 0, len(keys))
+	tagIds := make([]int64, 0, len(keys))
+	for _, key := range keys {
+		featureIds = append(featureIds, key.FeatureId)
+		tagIds = append(tagIds, key.TagId)
+	}
+	rows, err := repo.db.Query(ctx, getContentMany, featureIds, tagIds, time.Now().UTC(), withInactive)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result := make(map[models.FeatureTag][]models.UserBanner, len(keys))
+	for rows.Next() {
+		var key models.FeatureTag
+		var item models.UserBanner
+		if err := rows.Scan(&key.FeatureId, &key.TagId, &item.Id, (*[]byte)(&item.Content), &item.ActiveUntil, &item.Weight, &item.UpdateTime, &item.IsActive, &item.ImpressionCap, &item.Fallback); err != nil {
+			return nil, fmt.Errorf("error occured while scanning banners:%w", err)
+		}
+		item.Hash = item.Content.Hash()
+		result[key] = append(result[key], item)
+	}
+	return result, rows.Err()
+}
+
+// GetFallback returns the default banner of the feature, pgx.ErrNoRows when there is no visible one
+func (repo *BannerRepo) GetFallback(ctx context.Context, featureId int64, withInactive bool) (models.UserBanner, error) {
+	var item models.UserBanner
+	err := repo.db.QueryRow(ctx, getFallback, featureId, time.Now().UTC(), withInactive).Scan(&item.Id, (*[]byte)(&item.Content), &item.ActiveUntil, &item.Weight, &item.UpdateTime, &item.IsActive, &item.ImpressionCap, &item.Fallback)
+	if err != nil {
+		return models.UserBanner{}, err
+	}
+	item.Hash = item.Content.Hash()
+	return item, nil
+}
+
 func (repo *BannerRepo) GetById(ctx context.Context, id int64) (models.BannerForm, error) {
 	var result models.BannerForm
 
 	err := repo.db.QueryRow(ctx, getById, id).Scan(
-		&result.Content.Title,
+		(*[]byte)(&result.Content),
 		&result.FeatureId,
-		&result.Content.Data,
-		&result.Content.Url,
 		&result.IsActive,
+		&result.ActiveFrom,
+		&result.ActiveUntil,
+		&result.Weight,
+		&result.ImpressionCap,
 		&result.TagIds,
 	)
 	if err != nil {
@@ -145,40 +386,132 @@ func (repo *BannerRepo) GetById(ctx context.Context, id int64) (models.BannerFor
 	return result, nil
 }
 
-func (repo *BannerRepo) UpdateBanner(ctx context.Context, banner models.BannerForm, id int64) error {
+func (repo *BannerRepo) GetBanner(ctx context.Context, id int64) (models.Banner, error) {
+	return getBannerTx(ctx, repo.db, id)
+}
+
+// getBannerTx reads the banner with db, which may be a transaction
+func getBannerTx(ctx context.Context, db pgxtype.Querier, id int64) (models.Banner, error) {
+	var result models.Banner
+
+	err := db.QueryRow(ctx, getBanner, id).Scan(
+		&result.Id,
+		(*[]byte)(&result.Content),
+		&result.FeatureId,
+		&result.CreateTime,
+		&result.UpdateTime,
+		&result.IsActive,
+		&result.ActiveFrom,
+		&result.ActiveUntil,
+		&result.Weight,
+		&result.ImpressionCap,
+		&result.Version,
+		&result.Status,
+		&result.DeletedAt,
+		&result.TagIds,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Banner{}, banner.ErrBannerNotFound
+		}
+		return models.Banner{}, err
+	}
+
+	return result, nil
+}
+
+// UpdateBanner overwrites the banner and returns its new version.
+// The stored version must be one of versions, otherwise banner.ErrVersionMismatch is returned; empty versions skip the check
+func (repo *BannerRepo) UpdateBanner(ctx context.Context, banner models.BannerForm, id int64, versions []int64, author string) (int64, error) {
 	tx, err := repo.conn.Begin(ctx)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	defer func() {
 		if err = tx.Rollback(ctx); err != nil {
 			fmt.Printf("ERROR: %v", err)
 		}
 	}()
+	err = checkVersion(ctx, tx, id, versions)
+	if err != nil {
+		return 0, err
+	}
+	before, err := getBannerTx(ctx, tx, id)
+	if err != nil {
+		return 0, err
+	}
+	updateTime := time.Now().UTC()
 	//обновляем баннер
-	_, err = tx.Exec(ctx, updateBanner, banner.Content.Title, banner.FeatureId, banner.Content.Data, banner.Content.Url, banner.IsActive, time.Now().UTC(), id)
+	var newVersion int64
+	var status string
+	err = tx.QueryRow(ctx, updateBanner, []byte(banner.Content), banner.FeatureId, banner.IsActive, banner.ActiveFrom, banner.ActiveUntil, banner.Weight, banner.ImpressionCap, updateTime, id,
+		!sameTags(before.TagIds, banner.TagIds)).Scan(&newVersion, &status)
 	if err != nil {
-		return err
+		return 0, err
+	}
+	if before.Status != models.BannerStatusDraft && status == models.BannerStatusDraft {
+		err = AddTransitionEntry(ctx, tx, models.BannerTransition{
+			BannerId:   id,
+			Action:     models.WorkflowActionEdit,
+			FromStatus: before.Status,
+			ToStatus:   models.BannerStatusDraft,
+			Actor:      author,
+			CreateTime: updateTime,
+		})
+		if err != nil {
+			return 0, err
+		}
 	}
 	//чистим связанные с баннером теги
 	_, err = tx.Exec(ctx, deleteTagsByBannerId, id)
 	if err != nil {
-		return err
+		return 0, err
+	}
+	err = checkConflicts(ctx, tx, banner.FeatureId, banner.TagIds, id, banner.Weight)
+	if err != nil {
+		return 0, err
 	}
 	//записываем новый список тегов
 	for _, tag := range banner.TagIds {
 		_, err = tx.Exec(ctx, addBT, id, tag, banner.FeatureId)
 		if err != nil {
-			return err
+			return 0, err
 		}
 	}
+	//сохраняем новую ревизию
+	_, err = tx.Exec(ctx, addRevision, id, []byte(banner.Content), banner.FeatureId, nonNilTags(banner.TagIds), banner.IsActive, banner.ActiveFrom, banner.ActiveUntil, banner.Weight, banner.ImpressionCap, author, updateTime)
+	if err != nil {
+		return 0, err
+	}
+	after, err := getBannerTx(ctx, tx, id)
+	if err != nil {
+		return 0, err
+	}
+	err = auditRepo.AddEntry(ctx, tx, models.AuditEntry{
+		Actor:      author,
+		Action:     models.AuditActionUpdate,
+		Entity:     models.AuditEntityBanner,
+		EntityId:   id,
+		Before:     models.AuditSnapshot(before),
+		After:      models.AuditSnapshot(after),
+		CreateTime: updateTime,
+	})
+	if err != nil {
+		return 0, err
+	}
+	err = webhookRepo.AddEvent(ctx, tx, models.WebhookEventBannerUpdated, id, after, updateTime)
+	if err != nil {
+		return 0, err
+	}
 	err = tx.Commit(ctx)
 	if err != nil {
-		return err
+		return 0, err
 	}
-	return nil
+	return newVersion, nil
 }
-func (repo *BannerRepo) DeleteBanner(ctx context.Context, id int64) error {
+
+// DeleteBanner moves the banner to trash, the stored version must be one of versions unless they are empty
+func (repo *BannerRepo) DeleteBanner(ctx context.Context, id int64, versions []int64, author string) error {
 	tx, err := repo.conn.Begin(ctx)
 	if err != nil {
 		return err
@@ -188,13 +521,16 @@ func (repo *BannerRepo) DeleteBanner(ctx context.Context, id int64) error {
 			fmt.Printf("ERROR: %v", err)
 		}
 	}()
-	//чистим связанные с баннером теги
-	_, err = tx.Exec(ctx, deleteTagsByBannerId, id)
+	err = checkVersion(ctx, tx, id, versions)
 	if err != nil {
 		return err
 	}
-	//удаляем баннер
-	_, err = tx.Exec(ctx, deleteBannerById, id)
+	before, err := getBannerTx(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	//переносим баннер в корзину
+	err = trashBanner(ctx, tx, before, author, time.Now().UTC())
 	if err != nil {
 		return err
 	}
@@ -205,3 +541,348 @@ func (repo *BannerRepo) DeleteBanner(ctx context.Context, id int64) error {
 	return nil
 
 }
+
+// GetTrash returns a page of deleted banners, most recently deleted first
+func (repo *BannerRepo) GetTrash(ctx context.Context, count int64, offset int64) ([]models.Banner, error) {
+	result := make([]models.Banner, 0, count)
+	rows, err := repo.db.Query(ctx, getTrash, count, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var item models.Banner
+		if err := rows.Scan(&item.Id, (*[]byte)(&item.Content), &item.FeatureId, &item.CreateTime, &item.UpdateTime, &item.IsActive, &item.ActiveFrom, &item.ActiveUntil, &item.Weight, &item.ImpressionCap, &item.Version, &item.Status, &item.DeletedAt, &item.TagIds); err != nil {
+			return nil, fmt.Errorf("error occured while scanning items:%w", err)
+		}
+		result = append(result, item)
+	}
+
+	return result, rows.Err()
+}
+
+// RestoreBanner takes the banner out of trash unless its feature:tag pairs were taken while it was deleted.
+// It returns the pairs of the restored banner
+func (repo *BannerRepo) RestoreBanner(ctx context.Context, id int64, author string) ([]models.FeatureTag, error) {
+	tx, err := repo.conn.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err = tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			fmt.Printf("ERROR: %v", err)
+		}
+	}()
+	var featureId, weight int64
+	err = tx.QueryRow(ctx, lockDeletedBanner, id).Scan(&featureId, &weight)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, banner.ErrBannerNotFound
+		}
+		return nil, err
+	}
+	before, err := getBannerTx(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	//пока баннер лежал в корзине, его пары фича-тег могли занять
+	err = checkConflicts(ctx, tx, featureId, before.TagIds, id, weight)
+	if err != nil {
+		return nil, err
+	}
+	updateTime := time.Now().UTC()
+	_, err = tx.Exec(ctx, restoreBanner, updateTime, id)
+	if err != nil {
+		return nil, err
+	}
+	after, err := getBannerTx(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	err = auditRepo.AddEntry(ctx, tx, models.AuditEntry{
+		Actor:      author,
+		Action:     models.AuditActionRestore,
+		Entity:     models.AuditEntityBanner,
+		EntityId:   id,
+		Before:     models.AuditSnapshot(before),
+		After:      models.AuditSnapshot(after),
+		CreateTime: updateTime,
+	})
+	if err != nil {
+		return nil, err
+	}
+	//для подписчиков баннер из корзины появляется заново, под прежним id
+	err = webhookRepo.AddEvent(ctx, tx, models.WebhookEventBannerCreated, id, after, updateTime)
+	if err != nil {
+		return nil, err
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return models.BannerPairs(after.FeatureId, after.TagIds), nil
+}
+
+// PurgeDeleted permanently removes at most limit banners deleted before the given moment.
+// It returns the number of removed banners
+func (repo *BannerRepo) PurgeDeleted(ctx context.Context, before time.Time, limit int64) (int64, error) {
+	tag, err := repo.db.Exec(ctx, purgeDeleted, before, limit)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+func (repo *BannerRepo) GetRevisions(ctx context.Context, id int64) ([]models.BannerRevision, error) {
+	result := make([]models.BannerRevision, 0)
+	rows, err := repo.db.Query(ctx, getRevisions, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var item models.BannerRevision
+		if err := rows.Scan(&item.BannerId, &item.Revision, (*[]byte)(&item.Content), &item.FeatureId, &item.TagIds, &item.IsActive, &item.ActiveFrom, &item.ActiveUntil, &item.Weight, &item.ImpressionCap, &item.Author, &item.CreateTime); err != nil {
+			return nil, fmt.Errorf("error occured while scanning revisions:%w", err)
+		}
+		result = append(result, item)
+	}
+
+	return result, rows.Err()
+}
+
+func (repo *BannerRepo) GetRevision(ctx context.Context, id int64, revision int64) (models.BannerRevision, error) {
+	var result models.BannerRevision
+	err := repo.db.QueryRow(ctx, getRevision, id, revision).Scan(
+		&result.BannerId,
+		&result.Revision,
+		(*[]byte)(&result.Content),
+		&result.FeatureId,
+		&result.TagIds,
+		&result.IsActive,
+		&result.ActiveFrom,
+		&result.ActiveUntil,
+		&result.Weight,
+		&result.ImpressionCap,
+		&result.Author,
+		&result.CreateTime,
+	)
+	if err != nil {
+		return models.BannerRevision{}, err
+	}
+	return result, nil
+}
+
+// checkVersion locks the banner row and makes sure its version is one of the expected ones, empty versions skip the comparison
+func checkVersion(ctx context.Context, tx pgx.Tx, id int64, versions []int64) error {
+	var current int64
+	err := tx.QueryRow(ctx, lockBannerVersion, id).Scan(&current)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return banner.ErrBannerNotFound
+		}
+		return err
+	}
+	if len(versions) == 0 {
+		return nil
+	}
+	for _, version := range versions {
+		if version == current {
+			return nil
+		}
+	}
+	return banner.ErrVersionMismatch
+}
+
+// sameTags reports whether both lists hold the same set of tags
+func sameTags(before []int64, after []int64) bool {
+	a, b := slices.Clone(before), slices.Clone(after)
+	slices.Sort(a)
+	slices.Sort(b)
+	return slices.Equal(slices.Compact(a), slices.Compact(b))
+}
+
+// checkConflicts makes sure the banner can take the feature:tag pairs: a pair is served either
+// by a single banner or by several experiment variants (banners with a non-zero weight)
+func checkConflicts(ctx context.Context, tx pgx.Tx, featureId int64, tagIds []int64, bannerId int64, weight int64) error {
+	//сериализуем проверку с параллельными изменениями баннеров той же фичи
+	_, err := tx.Exec(ctx, lockFeature, featureId)
+	if err != nil {
+		return err
+	}
+	var conflicts int64
+	err = tx.QueryRow(ctx, countConflictingBT, featureId, nonNilTags(tagIds), bannerId, weight).Scan(&conflicts)
+	if err != nil {
+		return err
+	}
+	if conflicts > 0 {
+		return banner.ErrBannerConflict
+	}
+	return nil
+}
+
+// nonNilTags keeps pgx from encoding an empty tag list as NULL
+func nonNilTags(tags []int64) []int64 {
+	if tags == nil {
+		return []int64{}
+	}
+	return tags
+}
+
+func (repo *BannerRepo) CountFiltered(ctx context.Context, featureId int64, tagId int64) (int64, error) {
+	var result int64
+	err := repo.db.QueryRow(ctx, countFiltered, featureId, tagId).Scan(&result)
+	if err != nil {
+		return 0, err
+	}
+	return result, nil
+}
+
+// DeleteFiltered moves to trash at most limit banners matching feature and tag (0 matches any),
+// every banner goes there like in DeleteBanner, on behalf of author.
+// It returns the feature:tag pairs the removed banners were served by and the number of removed banners
+func (repo *BannerRepo) DeleteFiltered(ctx context.Context, featureId int64, tagId int64, limit int64, author string) ([]models.FeatureTag, int64, error) {
+	tx, err := repo.conn.Begin(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() {
+		if err = tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			fmt.Printf("ERROR: %v", err)
+		}
+	}()
+	//выбираем очередную пачку баннеров
+	rows, err := tx.Query(ctx, lockFiltered, featureId, tagId, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	ids := make([]int64, 0, limit)
+	for rows.Next() {
+		var id int64
+		if err = rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if len(ids) == 0 {
+		return nil, 0, nil
+	}
+	deleteTime := time.Now().UTC()
+	keys := make([]models.FeatureTag, 0, len(ids))
+	for _, id := range ids {
+		before, err := getBannerTx(ctx, tx, id)
+		if err != nil {
+			return nil, 0, err
+		}
+		//переносим баннер в корзину
+		err = trashBanner(ctx, tx, before, author, deleteTime)
+		if err != nil {
+			return nil, 0, err
+		}
+		keys = append(keys, models.BannerPairs(before.FeatureId, before.TagIds)...)
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	return keys, int64(len(ids)), nil
+}
+
+// ApplySchedule syncs is_active of banners having an activation window with the given moment.
+// Every switched banner is queued as banner.updated. It returns the feature:tag pairs of switched banners
+func (repo *BannerRepo) ApplySchedule(ctx context.Context, now time.Time) ([]models.FeatureTag, error) {
+	tx, err := repo.conn.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err = tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			fmt.Printf("ERROR: %v", err)
+		}
+	}()
+	//переключаем баннеры
+	rows, err := tx.Query(ctx, applySchedule, now)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int64, 0)
+	for rows.Next() {
+		var id int64
+		if err = rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	for _, id := range ids {
+		err = AddBannerEvent(ctx, tx, models.WebhookEventBannerUpdated, id, now)
+		if err != nil {
+			return nil, err
+		}
+	}
+	//собираем пары фича-тег переключённых баннеров
+	rows, err = tx.Query(ctx, getTagsByBannerIds, ids)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]models.FeatureTag, 0, len(ids))
+	for rows.Next() {
+		var key models.FeatureTag
+		if err = rows.Scan(&key.FeatureId, &key.TagId); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		result = append(result, key)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ExportFiltered streams banners matching feature and tag (0 matches any) to fn in id order.
+// Every banner comes with all of its tags
+func (repo *BannerRepo) ExportFiltered(ctx context.Context, featureId int64, tagId int64, fn func(models.BannerForm) error) error {
+	rows, err := repo.db.Query(ctx, exportFiltered, featureId, tagId)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var item models.BannerForm
+		if err := rows.Scan((*[]byte)(&item.Content), &item.FeatureId, &item.IsActive, &item.ActiveFrom, &item.ActiveUntil, &item.Weight, &item.ImpressionCap, &item.TagIds); err != nil {
+			return fmt.Errorf("error occured while scanning items:%w", err)
+		}
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// GetContentSchema returns JSON Schema for banner content of the feature, nil when the feature has none
+func (repo *BannerRepo) GetContentSchema(ctx context.Context, featureId int64) ([]byte, error) {
+	var result []byte
+	err := repo.db.QueryRow(ctx, getContentSchema, featureId).Scan(&result)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return result, nil
+}
@@ -3,7 +3,9 @@ package repo
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/Alladan04/avito_test/internal/models"
@@ -16,32 +18,420 @@ type CacheRepo struct {
 
 const DefaultExpTime = time.Minute * 10
 
+// NotFoundExpTime is how long a pair without banners is answered from the cache.
+// Markers are cleared when banners of the pair change, the short TTL only covers changes that bypass the cache
+const NotFoundExpTime = time.Second * 10
+
+// updatesChannel carries JSON lists of changed feature:tag pairs to every replica
+const updatesChannel = "banner_updates"
+
 func NewCacheRepo(db redis.Client) *CacheRepo {
 	return &CacheRepo{
 		db: db,
 	}
 }
 
-func (repo *CacheRepo) GetBanner(ctx context.Context, featureId int64, tagId int64) (models.BannerContent, error) {
-	var result models.BannerContent
-	key := fmt.Sprintf("%d:%d", featureId, tagId)
-	banner, err := repo.db.Get(ctx, key).Result()
+func bannerKey(featureId int64, tagId int64) string {
+	return fmt.Sprintf("%d:%d", featureId, tagId)
+}
+
+// adminBannerKey keeps the admin view, which includes inactive banners, apart from the one served to users
+func adminBannerKey(featureId int64, tagId int64) string {
+	return "admin:" + bannerKey(featureId, tagId)
+}
+
+func viewKey(featureId int64, tagId int64, admin bool) string {
+	if admin {
+		return adminBannerKey(featureId, tagId)
+	}
+	return bannerKey(featureId, tagId)
+}
+
+// fallbackKey holds the default banner of the feature, pairs served by it are cached as empty lists
+func fallbackKey(featureId int64, admin bool) string {
+	key := fmt.Sprintf("%d:default", featureId)
+	if admin {
+		return "admin:" + key
+	}
+	return key
+}
+
+// notFoundGenerationKey counts DeleteFallback calls of the feature and evictions of its banners without tags. Markers of its pairs include
+// the generation, so bumping it drops all of them at once, while the old ones simply expire
+func notFoundGenerationKey(featureId int64) string {
+	return fmt.Sprintf("missing:%d:generation", featureId)
+}
+
+// notFoundKey is the marker of a pair without banners in the given generation of its feature
+func notFoundKey(featureId int64, tagId int64, admin bool, generation int64) string {
+	key := fmt.Sprintf("missing:%d:%d:%d", featureId, generation, tagId)
+	if admin {
+		return "admin:" + key
+	}
+	return key
+}
+
+// GetBanners returns the banners cached for feature and tag: a single banner or all variants of an experiment.
+// An empty list means the pair has no banners of its own and is served by the feature default
+func (repo *CacheRepo) GetBanners(ctx context.Context, featureId int64, tagId int64, admin bool) ([]models.UserBanner, error) {
+	var result []models.UserBanner
+	key := viewKey(featureId, tagId, admin)
+	banners, err := repo.db.Get(ctx, key).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal([]byte(banners), &result)
+	if err != nil {
+		return nil, err
+	}
+	if !fresh(result, time.Now().UTC()) {
+		return nil, redis.Nil
+	}
+	return result, nil
+}
+
+// GetManyBanners reads cached banners of several feature:tag pairs and default banners of the features with one MGET.
+// The results are aligned with keys and featureIds, missing or outdated entries are nil,
+// pairs served by the feature default are empty
+func (repo *CacheRepo) GetManyBanners(ctx context.Context, keys []models.FeatureTag, featureIds []int64, admin bool) ([][]models.UserBanner, []*models.UserBanner, error) {
+	redisKeys := make([]string, 0, len(keys)+len(featureIds))
+	for _, key := range keys {
+		redisKeys = append(redisKeys, viewKey(key.FeatureId, key.TagId, admin))
+	}
+	for _, featureId := range featureIds {
+		redisKeys = append(redisKeys, fallbackKey(featureId, admin))
+	}
+	if len(redisKeys) == 0 {
+		return nil, nil, nil
+	}
+	values, err := repo.db.MGet(ctx, redisKeys...).Result()
 	if err != nil {
-		return result, err
+		return nil, nil, err
+	}
+	now := time.Now().UTC()
+	banners := make([][]models.UserBanner, len(keys))
+	for i := range keys {
+		banners[i] = decodeBanners(values[i], now)
+	}
+	fallbacks := make([]*models.UserBanner, len(featureIds))
+	for i := range featureIds {
+		fallbacks[i] = decodeFallback(values[len(keys)+i], now)
+	}
+	return banners, fallbacks, nil
+}
+
+// decodeBanners parses cached banners of a pair, nil when they are missing or outdated
+func decodeBanners(value any, now time.Time) []models.UserBanner {
+	data, ok := value.(string)
+	if !ok {
+		return nil
+	}
+	var banners []models.UserBanner
+	if err := json.Unmarshal([]byte(data), &banners); err != nil {
+		return nil
+	}
+	if !fresh(banners, now) {
+		return nil
+	}
+	return banners
+}
+
+// decodeFallback parses a cached default banner, nil when it is missing or outdated
+func decodeFallback(value any, now time.Time) *models.UserBanner {
+	data, ok := value.(string)
+	if !ok {
+		return nil
+	}
+	var item models.UserBanner
+	if err := json.Unmarshal([]byte(data), &item); err != nil {
+		return nil
+	}
+	if !fresh([]models.UserBanner{item}, now) {
+		return nil
+	}
+	return &item
+}
+
+// fresh reports whether cached banners can still be served
+func fresh(banners []models.UserBanner, now time.Time) bool {
+	for _, item := range banners {
+		if item.ActiveUntil != nil && !now.Before(*item.ActiveUntil) {
+			return false
+		}
+		// записи, сохранённые до появления хеша, считаем промахом
+		if item.Hash == "" {
+			return false
+		}
 	}
+	return true
+}
 
-	err = json.Unmarshal([]byte(banner), &result)
+// GetFallbacks reads cached default banners of the features with one MGET.
+// The result is aligned with featureIds, missing or outdated defaults are nil
+func (repo *CacheRepo) GetFallbacks(ctx context.Context, featureIds []int64, admin bool) ([]*models.UserBanner, error) {
+	redisKeys := make([]string, 0, len(featureIds))
+	for _, featureId := range featureIds {
+		redisKeys = append(redisKeys, fallbackKey(featureId, admin))
+	}
+	values, err := repo.db.MGet(ctx, redisKeys...).Result()
 	if err != nil {
-		return result, err
+		return nil, err
+	}
+	result := make([]*models.UserBanner, len(featureIds))
+	now := time.Now().UTC()
+	for i, value := range values {
+		result[i] = decodeFallback(value, now)
 	}
 	return result, nil
 }
 
-func (repo *CacheRepo) AddBanner(ctx context.Context, featureId int64, tagId int64, banner models.BannerContent) error {
-	key := fmt.Sprintf("%d:%d", featureId, tagId)
-	err := repo.db.Set(ctx, key, banner, DefaultExpTime).Err()
+// AddFallbacks caches default banners of the features in one pipeline
+func (repo *CacheRepo) AddFallbacks(ctx context.Context, admin bool, banners map[int64]models.UserBanner) error {
+	if len(banners) == 0 {
+		return nil
+	}
+	now := time.Now().UTC()
+	pipe := repo.db.Pipeline()
+	for featureId, item := range banners {
+		expTime, ok := expiration([]models.UserBanner{item}, now)
+		if !ok {
+			continue
+		}
+		data, err := json.Marshal(item)
+		if err != nil {
+			return err
+		}
+		pipe.Set(ctx, fallbackKey(featureId, admin), data, expTime)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// DeleteFallback evicts the default banner of the feature from both views and the not-found markers of its pairs
+func (repo *CacheRepo) DeleteFallback(ctx context.Context, featureId int64) error {
+	pipe := repo.db.TxPipeline()
+	pipe.Del(ctx, fallbackKey(featureId, false), fallbackKey(featureId, true))
+	pipe.Incr(ctx, notFoundGenerationKey(featureId))
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// AddBanners caches the banners for DefaultExpTime, but not past the end of any of their activation windows
+func (repo *CacheRepo) AddBanners(ctx context.Context, featureId int64, tagId int64, admin bool, banners []models.UserBanner) error {
+	expTime, ok := expiration(banners, time.Now().UTC())
+	if !ok {
+		return nil
+	}
+	data, err := json.Marshal(banners)
+	if err != nil {
+		return err
+	}
+	err = repo.db.Set(ctx, viewKey(featureId, tagId, admin), data, expTime).Err()
 	if err != nil {
 		return err
 	}
 	return nil
 }
+
+// AddManyBanners caches banners of several feature:tag pairs in one pipeline
+func (repo *CacheRepo) AddManyBanners(ctx context.Context, admin bool, banners map[models.FeatureTag][]models.UserBanner) error {
+	if len(banners) == 0 {
+		return nil
+	}
+	now := time.Now().UTC()
+	pipe := repo.db.Pipeline()
+	for key, items := range banners {
+		expTime, ok := expiration(items, now)
+		if !ok {
+			continue
+		}
+		data, err := json.Marshal(items)
+		if err != nil {
+			return err
+		}
+		pipe.Set(ctx, viewKey(key.FeatureId, key.TagId, admin), data, expTime)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// expiration caps DefaultExpTime by the earliest end of the banners activation windows,
+// ok is false when one of the windows is already over
+func expiration(banners []models.UserBanner, now time.Time) (expTime time.Duration, ok bool) {
+	expTime = DefaultExpTime
+	for _, item := range banners {
+		if item.ActiveUntil == nil {
+			continue
+		}
+		left := item.ActiveUntil.Sub(now)
+		if left <= 0 {
+			return 0, false
+		}
+		if left < expTime {
+			expTime = left
+		}
+	}
+	return expTime, true
+}
+
+// DeleteBanners evicts the pairs from both views together with the defaults of their features,
+// since the evicted banner may be the one the feature falls back to, and clears the not-found markers of the pairs.
+// A pair with zero TagId stands for a banner without tags: it evicts the default of its feature together with
+// the not-found markers of all its pairs, like DeleteFallback. Streams of the pairs are notified
+func (repo *CacheRepo) DeleteBanners(ctx context.Context, keys []models.FeatureTag) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	redisKeys := make([]string, 0, 4*len(keys))
+	pairs := make([]models.FeatureTag, 0, len(keys))
+	features := make(map[int64]bool)
+	var defaults []int64
+	for _, key := range keys {
+		if key.TagId == 0 {
+			defaults = append(defaults, key.FeatureId)
+		} else {
+			pairs = append(pairs, key)
+			redisKeys = append(redisKeys, bannerKey(key.FeatureId, key.TagId), adminBannerKey(key.FeatureId, key.TagId))
+		}
+		if !features[key.FeatureId] {
+			features[key.FeatureId] = true
+			redisKeys = append(redisKeys, fallbackKey(key.FeatureId, false), fallbackKey(key.FeatureId, true))
+		}
+	}
+	markers, err := repo.notFoundKeys(ctx, pairs)
+	if err != nil {
+		return err
+	}
+	pipe := repo.db.TxPipeline()
+	pipe.Del(ctx, append(redisKeys, markers...)...)
+	// такой баннер может стать баннером по умолчанию для пар, уже отмеченных как пустые
+	for _, featureId := range defaults {
+		pipe.Incr(ctx, notFoundGenerationKey(featureId))
+	}
+	_, err = pipe.Exec(ctx)
+	if err != nil {
+		return err
+	}
+	return repo.publishUpdates(ctx, keys)
+}
+
+// AddNotFound marks the pair as having no banners for NotFoundExpTime
+func (repo *CacheRepo) AddNotFound(ctx context.Context, featureId int64, tagId int64, admin bool) error {
+	generation, err := repo.notFoundGeneration(ctx, featureId)
+	if err != nil {
+		return err
+	}
+	// если поколение сменилось после чтения, маркер останется в старом и просто истечёт
+	return repo.db.Set(ctx, notFoundKey(featureId, tagId, admin, generation), 1, NotFoundExpTime).Err()
+}
+
+// IsNotFound reports whether the pair is marked as having no banners
+func (repo *CacheRepo) IsNotFound(ctx context.Context, featureId int64, tagId int64, admin bool) (bool, error) {
+	generation, err := repo.notFoundGeneration(ctx, featureId)
+	if err != nil {
+		return false, err
+	}
+	exists, err := repo.db.Exists(ctx, notFoundKey(featureId, tagId, admin, generation)).Result()
+	if err != nil {
+		return false, err
+	}
+	return exists > 0, nil
+}
+
+// DeleteNotFound clears the markers of the pairs in both views
+func (repo *CacheRepo) DeleteNotFound(ctx context.Context, keys []models.FeatureTag) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	markers, err := repo.notFoundKeys(ctx, keys)
+	if err != nil {
+		return err
+	}
+	return repo.db.Del(ctx, markers...).Err()
+}
+
+func (repo *CacheRepo) notFoundGeneration(ctx context.Context, featureId int64) (int64, error) {
+	generation, err := repo.db.Get(ctx, notFoundGenerationKey(featureId)).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	return generation, err
+}
+
+// notFoundKeys lists the markers of the pairs in both views, reading generations of their features with one MGET
+func (repo *CacheRepo) notFoundKeys(ctx context.Context, keys []models.FeatureTag) ([]string, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	featureIds := make([]int64, 0, len(keys))
+	generationKeys := make([]string, 0, len(keys))
+	seen := make(map[int64]bool)
+	for _, key := range keys {
+		if !seen[key.FeatureId] {
+			seen[key.FeatureId] = true
+			featureIds = append(featureIds, key.FeatureId)
+			generationKeys = append(generationKeys, notFoundGenerationKey(key.FeatureId))
+		}
+	}
+	values, err := repo.db.MGet(ctx, generationKeys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	generations := make(map[int64]int64, len(featureIds))
+	for i, value := range values {
+		if data, ok := value.(string); ok {
+			generations[featureIds[i]], _ = strconv.ParseInt(data, 10, 64)
+		}
+	}
+	result := make([]string, 0, 2*len(keys))
+	for _, key := range keys {
+		generation := generations[key.FeatureId]
+		result = append(result, notFoundKey(key.FeatureId, key.TagId, false, generation), notFoundKey(key.FeatureId, key.TagId, true, generation))
+	}
+	return result, nil
+}
+
+// publishUpdates tells streams on every replica that banners of the pairs changed
+func (repo *CacheRepo) publishUpdates(ctx context.Context, keys []models.FeatureTag) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(keys)
+	if err != nil {
+		return err
+	}
+	return repo.db.Publish(ctx, updatesChannel, data).Err()
+}
+
+// SubscribeUpdates delivers lists of changed pairs until ctx is cancelled.
+// The subscription reconnects by itself, updates published while it is down are lost
+func (repo *CacheRepo) SubscribeUpdates(ctx context.Context) <-chan []models.FeatureTag {
+	pubsub := repo.db.Subscribe(ctx, updatesChannel)
+	result := make(chan []models.FeatureTag)
+	go func() {
+		defer close(result)
+		defer pubsub.Close()
+		messages := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case message, ok := <-messages:
+				if !ok {
+					return
+				}
+				var keys []models.FeatureTag
+				if err := json.Unmarshal([]byte(message.Payload), &keys); err != nil {
+					continue
+				}
+				select {
+				case result <- keys:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return result
+}
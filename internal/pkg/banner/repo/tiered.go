@@ -0,0 +1,382 @@
+package repo
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Alladan04/avito_test/internal/models"
+	"github.com/Alladan04/avito_test/internal/pkg/banner"
+)
+
+const (
+	DefaultLocalCacheSize = 10000
+	// короткий TTL ограничивает расхождение реплик, если уведомление об изменении потерялось
+	DefaultLocalCacheTTL = 5 * time.Second
+)
+
+type localKey struct {
+	featureId int64
+	tagId     int64
+	admin     bool
+	// the default banner of the feature, tagId is zero
+	fallback bool
+}
+
+type localEntry struct {
+	key     localKey
+	banners []models.UserBanner
+	expires time.Time
+}
+
+// localCache is a bounded LRU of banners that expire after ttl, it is safe for concurrent use
+type localCache struct {
+	mu    sync.Mutex
+	size  int
+	ttl   time.Duration
+	items map[localKey]*list.Element
+	// front is the most recently used entry
+	order *list.List
+
+	hits      int64
+	misses    int64
+	evictions int64
+}
+
+func newLocalCache(size int, ttl time.Duration) *localCache {
+	return &localCache{
+		size:  size,
+		ttl:   ttl,
+		items: make(map[localKey]*list.Element),
+		order: list.New(),
+	}
+}
+
+// get returns a copy of the cached banners, so callers never share a slice with other requests
+func (c *localCache) get(key localKey, now time.Time) ([]models.UserBanner, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.items[key]
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+	entry := element.Value.(*localEntry)
+	if !now.Before(entry.expires) {
+		c.order.Remove(element)
+		delete(c.items, key)
+		c.misses++
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	c.hits++
+	return slices.Clone(entry.banners), true
+}
+
+// add keeps the banners for ttl, but not past the end of any of their activation windows
+func (c *localCache) add(key localKey, banners []models.UserBanner, now time.Time) {
+	if c.size <= 0 {
+		return
+	}
+	expires := now.Add(c.ttl)
+	for _, item := range banners {
+		if item.ActiveUntil != nil && item.ActiveUntil.Before(expires) {
+			expires = *item.ActiveUntil
+		}
+	}
+	if !now.Before(expires) {
+		return
+	}
+	entry := &localEntry{key: key, banners: slices.Clone(banners), expires: expires}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.items[key]; ok {
+		element.Value = entry
+		c.order.MoveToFront(element)
+		return
+	}
+	c.items[key] = c.order.PushFront(entry)
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*localEntry).key)
+		c.evictions++
+	}
+}
+
+func (c *localCache) remove(keys []localKey) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range keys {
+		if element, ok := c.items[key]; ok {
+			c.order.Remove(element)
+			delete(c.items, key)
+			c.evictions++
+		}
+	}
+}
+
+func (c *localCache) stats() models.CacheTierStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return models.CacheTierStats{
+		Hits:      c.hits,
+		Misses:    c.misses,
+		Evictions: c.evictions,
+		Size:      int64(c.order.Len()),
+	}
+}
+
+// TieredCacheRepo serves banners from an in-process LRU and falls back to the shared cache behind it.
+// Entries are dropped from the LRU when this replica invalidates them and when other replicas publish
+// their changes, which reach it through SubscribeUpdates, so UpdatesHub must be running
+type TieredCacheRepo struct {
+	next  banner.CacheRepo
+	local *localCache
+
+	nextHits      atomic.Int64
+	nextMisses    atomic.Int64
+	nextEvictions atomic.Int64
+}
+
+// NewTieredCacheRepo puts an LRU of size entries kept for ttl in front of next, size 0 turns the LRU off
+func NewTieredCacheRepo(next banner.CacheRepo, size int, ttl time.Duration) *TieredCacheRepo {
+	return &TieredCacheRepo{
+		next:  next,
+		local: newLocalCache(size, ttl),
+	}
+}
+
+func (repo *TieredCacheRepo) GetBanners(ctx context.Context, featureId int64, tagId int64, admin bool) ([]models.UserBanner, error) {
+	key := localKey{featureId: featureId, tagId: tagId, admin: admin}
+	now := time.Now().UTC()
+	if result, ok := repo.local.get(key, now); ok {
+		return result, nil
+	}
+	result, err := repo.next.GetBanners(ctx, featureId, tagId, admin)
+	if err != nil {
+		repo.nextMisses.Add(1)
+		return nil, err
+	}
+	repo.nextHits.Add(1)
+	repo.local.add(key, result, now)
+	return result, nil
+}
+
+func (repo *TieredCacheRepo) AddBanners(ctx context.Context, featureId int64, tagId int64, admin bool, banners []models.UserBanner) error {
+	repo.local.add(localKey{featureId: featureId, tagId: tagId, admin: admin}, banners, time.Now().UTC())
+	return repo.next.AddBanners(ctx, featureId, tagId, admin, banners)
+}
+
+// GetManyBanners asks the next tier, in one call, only for the pairs and defaults missing locally.
+// When it fails, the entries found locally are still returned and the rest are reported as missing
+func (repo *TieredCacheRepo) GetManyBanners(ctx context.Context, keys []models.FeatureTag, featureIds []int64, admin bool) ([][]models.UserBanner, []*models.UserBanner, error) {
+	now := time.Now().UTC()
+	banners := make([][]models.UserBanner, len(keys))
+	var missedKeys []int
+	for i, key := range keys {
+		if items, ok := repo.local.get(localKey{featureId: key.FeatureId, tagId: key.TagId, admin: admin}, now); ok {
+			banners[i] = items
+			continue
+		}
+		missedKeys = append(missedKeys, i)
+	}
+	fallbacks := make([]*models.UserBanner, len(featureIds))
+	var missedFeatures []int
+	for i, featureId := range featureIds {
+		if items, ok := repo.local.get(localKey{featureId: featureId, admin: admin, fallback: true}, now); ok && len(items) == 1 {
+			fallbacks[i] = &items[0]
+			continue
+		}
+		missedFeatures = append(missedFeatures, i)
+	}
+	if len(missedKeys) == 0 && len(missedFeatures) == 0 {
+		return banners, fallbacks, nil
+	}
+	nextKeys := make([]models.FeatureTag, 0, len(missedKeys))
+	for _, i := range missedKeys {
+		nextKeys = append(nextKeys, keys[i])
+	}
+	nextIds := make([]int64, 0, len(missedFeatures))
+	for _, i := range missedFeatures {
+		nextIds = append(nextIds, featureIds[i])
+	}
+	loaded, loadedFallbacks, err := repo.next.GetManyBanners(ctx, nextKeys, nextIds, admin)
+	if err != nil {
+		repo.nextMisses.Add(int64(len(missedKeys) + len(missedFeatures)))
+		fmt.Printf("error while reading cache:%s\n", err.Error())
+		return banners, fallbacks, nil
+	}
+	for j, i := range missedKeys {
+		if loaded[j] == nil {
+			repo.nextMisses.Add(1)
+			continue
+		}
+		repo.nextHits.Add(1)
+		banners[i] = loaded[j]
+		repo.local.add(localKey{featureId: keys[i].FeatureId, tagId: keys[i].TagId, admin: admin}, loaded[j], now)
+	}
+	for j, i := range missedFeatures {
+		if loadedFallbacks[j] == nil {
+			repo.nextMisses.Add(1)
+			continue
+		}
+		repo.nextHits.Add(1)
+		fallbacks[i] = loadedFallbacks[j]
+		repo.local.add(localKey{featureId: featureIds[i], admin: admin, fallback: true}, []models.UserBanner{*loadedFallbacks[j]}, now)
+	}
+	return banners, fallbacks, nil
+}
+
+func (repo *TieredCacheRepo) AddManyBanners(ctx context.Context, admin bool, banners map[models.FeatureTag][]models.UserBanner) error {
+	now := time.Now().UTC()
+	for key, items := range banners {
+		repo.local.add(localKey{featureId: key.FeatureId, tagId: key.TagId, admin: admin}, items, now)
+	}
+	return repo.next.AddManyBanners(ctx, admin, banners)
+}
+
+func (repo *TieredCacheRepo) GetFallbacks(ctx context.Context, featureIds []int64, admin bool) ([]*models.UserBanner, error) {
+	now := time.Now().UTC()
+	result := make([]*models.UserBanner, len(featureIds))
+	var missed []int
+	for i, featureId := range featureIds {
+		if banners, ok := repo.local.get(localKey{featureId: featureId, admin: admin, fallback: true}, now); ok && len(banners) == 1 {
+			result[i] = &banners[0]
+			continue
+		}
+		missed = append(missed, i)
+	}
+	if len(missed) == 0 {
+		return result, nil
+	}
+	nextIds := make([]int64, 0, len(missed))
+	for _, i := range missed {
+		nextIds = append(nextIds, featureIds[i])
+	}
+	loaded, err := repo.next.GetFallbacks(ctx, nextIds, admin)
+	if err != nil {
+		repo.nextMisses.Add(int64(len(missed)))
+		fmt.Printf("error while reading cache:%s\n", err.Error())
+		return result, nil
+	}
+	for j, i := range missed {
+		if loaded[j] == nil {
+			repo.nextMisses.Add(1)
+			continue
+		}
+		repo.nextHits.Add(1)
+		result[i] = loaded[j]
+		repo.local.add(localKey{featureId: featureIds[i], admin: admin, fallback: true}, []models.UserBanner{*loaded[j]}, now)
+	}
+	return result, nil
+}
+
+func (repo *TieredCacheRepo) AddFallbacks(ctx context.Context, admin bool, banners map[int64]models.UserBanner) error {
+	now := time.Now().UTC()
+	for featureId, item := range banners {
+		repo.local.add(localKey{featureId: featureId, admin: admin, fallback: true}, []models.UserBanner{item}, now)
+	}
+	return repo.next.AddFallbacks(ctx, admin, banners)
+}
+
+// DeleteFallback evicts the default banner of the feature. Other replicas are not notified
+// and keep their copy until the local ttl runs out
+func (repo *TieredCacheRepo) DeleteFallback(ctx context.Context, featureId int64) error {
+	err := repo.next.DeleteFallback(ctx, featureId)
+	if err != nil {
+		return err
+	}
+	repo.nextEvictions.Add(2)
+	repo.local.remove(fallbackKeys(featureId))
+	return nil
+}
+
+// DeleteBanners evicts the pairs from the next tier first, so a concurrent miss cannot bring the old banners back from it
+func (repo *TieredCacheRepo) DeleteBanners(ctx context.Context, keys []models.FeatureTag) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	err := repo.next.DeleteBanners(ctx, keys)
+	evicted := evictedKeys(keys)
+	// локальную копию сбрасываем и при ошибке: пусть лучше запрос сходит в redis, чем отдаст старое
+	repo.local.remove(evicted)
+	if err != nil {
+		return err
+	}
+	repo.nextEvictions.Add(int64(len(evicted)))
+	return nil
+}
+
+// AddNotFound and the other not-found methods go straight to the next tier: DeleteFallback
+// clears markers without notifying other replicas, so a local copy could outlive the new default banner
+func (repo *TieredCacheRepo) AddNotFound(ctx context.Context, featureId int64, tagId int64, admin bool) error {
+	return repo.next.AddNotFound(ctx, featureId, tagId, admin)
+}
+
+func (repo *TieredCacheRepo) IsNotFound(ctx context.Context, featureId int64, tagId int64, admin bool) (bool, error) {
+	return repo.next.IsNotFound(ctx, featureId, tagId, admin)
+}
+
+func (repo *TieredCacheRepo) DeleteNotFound(ctx context.Context, keys []models.FeatureTag) error {
+	return repo.next.DeleteNotFound(ctx, keys)
+}
+
+// SubscribeUpdates passes updates of the next tier on, dropping the changed pairs from the LRU first
+func (repo *TieredCacheRepo) SubscribeUpdates(ctx context.Context) <-chan []models.FeatureTag {
+	updates := repo.next.SubscribeUpdates(ctx)
+	result := make(chan []models.FeatureTag)
+	go func() {
+		defer close(result)
+		for keys := range updates {
+			repo.local.remove(evictedKeys(keys))
+			select {
+			case result <- keys:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return result
+}
+
+// Stats reports lookups of both tiers made by this replica
+func (repo *TieredCacheRepo) Stats() models.CacheStats {
+	return models.CacheStats{
+		Local: repo.local.stats(),
+		Redis: models.CacheTierStats{
+			Hits:      repo.nextHits.Load(),
+			Misses:    repo.nextMisses.Load(),
+			Evictions: repo.nextEvictions.Load(),
+		},
+	}
+}
+
+// evictedKeys lists the entries DeleteBanners drops: both views of the pairs and the defaults of their features
+func evictedKeys(keys []models.FeatureTag) []localKey {
+	result := make([]localKey, 0, 4*len(keys))
+	features := make(map[int64]bool)
+	for _, key := range keys {
+		if key.TagId != 0 {
+			result = append(result,
+				localKey{featureId: key.FeatureId, tagId: key.TagId},
+				localKey{featureId: key.FeatureId, tagId: key.TagId, admin: true})
+		}
+		if !features[key.FeatureId] {
+			features[key.FeatureId] = true
+			result = append(result, fallbackKeys(key.FeatureId)...)
+		}
+	}
+	return result
+}
+
+func fallbackKeys(featureId int64) []localKey {
+	return []localKey{
+		{featureId: featureId, fallback: true},
+		{featureId: featureId, admin: true, fallback: true},
+	}
+}
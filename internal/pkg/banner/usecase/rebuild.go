@@ -0,0 +1,107 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Alladan04/avito_test/internal/models"
+	"github.com/jackc/pgx/v4"
+)
+
+const (
+	// rebuildLockTTL bounds how long replicas wait for the one rebuilding a pair, even if it died holding the lock
+	rebuildLockTTL = 2 * time.Second
+	// rebuildPollInterval is how often a waiting replica looks for the rebuilt pair in the cache
+	rebuildPollInterval = 20 * time.Millisecond
+)
+
+// errCacheMiss means the pair has to be read from the database
+var errCacheMiss = errors.New("banners of the pair are not cached")
+
+// loadPair reads the banners of the pair from the database after a cache miss and caches them.
+// Concurrent misses of the replica share one query, with the lock replicas also wait for each other
+func (uc *BannerUsecase) loadPair(ctx context.Context, key models.FeatureTag, admin bool) ([]models.UserBanner, error) {
+	// запрос общий для всех ждущих, поэтому отмена запроса, который его начал, не должна прерывать остальных
+	loadCtx := context.WithoutCancel(ctx)
+	loaded := uc.loads.DoChan(fmt.Sprintf("%d:%d:%t", key.FeatureId, key.TagId, admin), func() (interface{}, error) {
+		return uc.rebuildPair(loadCtx, key, admin)
+	})
+	select {
+	case result := <-loaded:
+		if result.Err != nil {
+			return nil, result.Err
+		}
+		// результат делят все ждущие, дальше он только читается
+		return result.Val.([]models.UserBanner), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (uc *BannerUsecase) rebuildPair(ctx context.Context, key models.FeatureTag, admin bool) ([]models.UserBanner, error) {
+	if uc.lock != nil {
+		result, token, err := uc.waitRebuild(ctx, key, admin)
+		if !errors.Is(err, errCacheMiss) {
+			return result, err
+		}
+		if token != "" {
+			defer func() {
+				err := uc.lock.UnlockPair(ctx, key, admin, token)
+				if err != nil {
+					fmt.Printf("error while unlocking cache rebuild:%s\n", err.Error())
+				}
+			}()
+		}
+	}
+	result, err := uc.repo.GetOne(ctx, key.FeatureId, key.TagId, admin)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// следующие запросы пары получат 404 из кеша, пока у неё не появится баннер
+		if err := uc.cache.AddNotFound(ctx, key.FeatureId, key.TagId, admin); err != nil {
+			fmt.Printf("error while trying to cache:%s", err.Error())
+		}
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	uc.cacheLoaded(ctx, admin, map[models.FeatureTag][]models.UserBanner{key: result})
+	return result, nil
+}
+
+// waitRebuild takes the lock of the pair or waits until the replica holding it caches the pair.
+// errCacheMiss means the caller has to query the database, holding the lock if the token is set.
+// Without the token it gave up: redis failed or the lock outlived rebuildLockTTL
+func (uc *BannerUsecase) waitRebuild(ctx context.Context, key models.FeatureTag, admin bool) (result []models.UserBanner, token string, err error) {
+	deadline := time.Now().Add(rebuildLockTTL)
+	for waited := false; ; waited = true {
+		token, locked, err := uc.lock.LockPair(ctx, key, admin, rebuildLockTTL)
+		if err != nil {
+			fmt.Printf("error while locking cache rebuild:%s\n", err.Error())
+			return nil, "", errCacheMiss
+		}
+		if locked {
+			if !waited {
+				return nil, token, errCacheMiss
+			}
+			// блокировку отпустили: возможно, пару уже собрали, пока мы не смотрели в кеш
+			result, err := uc.cachedPair(ctx, key.FeatureId, key.TagId, admin)
+			if errors.Is(err, errCacheMiss) {
+				return nil, token, err
+			}
+			if err := uc.lock.UnlockPair(ctx, key, admin, token); err != nil {
+				fmt.Printf("error while unlocking cache rebuild:%s\n", err.Error())
+			}
+			return result, "", err
+		}
+		if !time.Now().Before(deadline) {
+			return nil, "", errCacheMiss
+		}
+		time.Sleep(rebuildPollInterval)
+		result, err := uc.cachedPair(ctx, key.FeatureId, key.TagId, admin)
+		if !errors.Is(err, errCacheMiss) {
+			return result, "", err
+		}
+	}
+}
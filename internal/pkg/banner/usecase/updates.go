@@ -0,0 +1,79 @@
+package usecase
+
+import (
+	"context"
+	"sync"
+
+	"github.com/Alladan04/avito_test/internal/models"
+	"github.com/Alladan04/avito_test/internal/pkg/banner"
+)
+
+// UpdatesHub shares one redis subscription between all streams of the replica
+type UpdatesHub struct {
+	cache     banner.CacheRepo
+	mu        sync.Mutex
+	listeners map[models.FeatureTag]map[chan struct{}]struct{}
+	closed    bool
+}
+
+func NewUpdatesHub(cache banner.CacheRepo) *UpdatesHub {
+	return &UpdatesHub{
+		cache:     cache,
+		listeners: make(map[models.FeatureTag]map[chan struct{}]struct{}),
+	}
+}
+
+// Run passes published updates to subscribers until ctx is cancelled, then closes their channels
+// so that open streams end
+func (h *UpdatesHub) Run(ctx context.Context) {
+	for keys := range h.cache.SubscribeUpdates(ctx) {
+		h.Notify(keys)
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for _, listeners := range h.listeners {
+		for ch := range listeners {
+			close(ch)
+		}
+	}
+	h.listeners = make(map[models.FeatureTag]map[chan struct{}]struct{})
+}
+
+// Subscribe returns a channel signalled when banners of the pair change and a function to unsubscribe.
+// Updates coming faster than they are read are merged into one signal, the channel is closed when the hub stops
+func (h *UpdatesHub) Subscribe(key models.FeatureTag) (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		close(ch)
+		return ch, func() {}
+	}
+	if h.listeners[key] == nil {
+		h.listeners[key] = make(map[chan struct{}]struct{})
+	}
+	h.listeners[key][ch] = struct{}{}
+	h.mu.Unlock()
+	return ch, func() {
+		h.mu.Lock()
+		delete(h.listeners[key], ch)
+		if len(h.listeners[key]) == 0 {
+			delete(h.listeners, key)
+		}
+		h.mu.Unlock()
+	}
+}
+
+func (h *UpdatesHub) Notify(keys []models.FeatureTag) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, key := range keys {
+		for ch := range h.listeners[key] {
+			select {
+			case ch <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
@@ -4,45 +4,63 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"hash/fnv"
+	"sort"
 	"time"
 
 	"github.com/Alladan04/avito_test/internal/models"
 	"github.com/Alladan04/avito_test/internal/pkg/banner"
+	"github.com/Alladan04/avito_test/internal/pkg/job"
+	"github.com/Alladan04/avito_test/internal/pkg/stats"
+	"github.com/Alladan04/avito_test/internal/pkg/utils"
+	"github.com/jackc/pgx/v4"
+	"golang.org/x/sync/singleflight"
 )
 
 const (
 	pageElementsCount = 10
+	// maxBatchSize limits the number of feature:tag pairs in one /api/user_banners request
+	maxBatchSize = 100
 )
 
 type BannerUsecase struct {
-	repo  banner.BannerRepo
-	cache banner.CacheRepo
+	repo        banner.BannerRepo
+	cache       banner.CacheRepo
+	jobs        job.JobRepo
+	impressions banner.ImpressionRepo
+	counters    stats.CounterRepo
+	// lock is optional, without it every replica rebuilds an expired pair on its own
+	lock banner.LockRepo
+	// loads shares one database query between concurrent cache misses of a pair
+	loads singleflight.Group
 }
 
-func NewBannerUsecase(repo banner.BannerRepo, cache banner.CacheRepo) *BannerUsecase {
+func NewBannerUsecase(repo banner.BannerRepo, cache banner.CacheRepo, jobs job.JobRepo, impressions banner.ImpressionRepo, counters stats.CounterRepo, lock banner.LockRepo) *BannerUsecase {
 	return &BannerUsecase{
-		repo:  repo,
-		cache: cache,
+		repo:        repo,
+		cache:       cache,
+		jobs:        jobs,
+		impressions: impressions,
+		counters:    counters,
+		lock:        lock,
 	}
 }
 
 func (uc *BannerUsecase) AddItem(ctx context.Context, data models.BannerForm) (models.Banner, error) {
-
-	item := models.Banner{
-		Content: models.BannerContent{
-			Title: data.Content.Title,
-			Data:  data.Content.Data,
-			Url:   data.Content.Url,
-		},
-		FeatureId:  data.FeatureId,
-		TagIds:     data.TagIds,
-		CreateTime: time.Now().UTC(),
-		UpdateTime: time.Now().UTC(),
+	if err := validateForm(data); err != nil {
+		return models.Banner{}, err
+	}
+	if err := uc.checkContentSchema(ctx, data); err != nil {
+		return models.Banner{}, err
 	}
-	err := uc.repo.AddItem(ctx, item)
+
+	item := newBanner(data)
+	id, err := uc.repo.AddItem(ctx, item, utils.UsernameFromContext(ctx))
 	if err != nil {
 		return item, err
 	}
+	item.Id = id
+	uc.clearNotFound(ctx, models.BannerPairs(item.FeatureId, item.TagIds))
 	return item, nil
 }
 
@@ -62,59 +80,589 @@ func (uc *BannerUsecase) GetAll(ctx context.Context, count int64, offset int64,
 	return data, nil
 
 }
-func (uc *BannerUsecase) GetOne(ctx context.Context, featureId int64, tagId int64, showLastRevision bool) (models.BannerContent, error) {
-	result, err := uc.cache.GetBanner(ctx, featureId, tagId)
-	if err == nil {
+
+// GetOne returns the banner for the given feature and tag.
+// When the pair runs an experiment, the variant is chosen by the user from context.
+// Admins get inactive banners too, users only see active ones.
+// Pairs without banners of their own are served by the feature default, if it is set.
+// Users who reached the impression cap of a banner get the next eligible one: another variant
+// of the experiment, then the feature default. Every served banner is counted in its stats.
+// With showLastRevision the cache is skipped and the banner is read straight from the database
+func (uc *BannerUsecase) GetOne(ctx context.Context, featureId int64, tagId int64, showLastRevision bool) (models.UserBanner, error) {
+	result, err := uc.PickOne(ctx, featureId, tagId, showLastRevision)
+	if err != nil {
+		return models.UserBanner{}, err
+	}
+	uc.CountImpression(ctx, result)
+	return result, nil
+}
+
+// PickOne chooses the banner of the pair for the user from context, nothing is counted until CountImpression.
+// So a client that already has the banner can be answered without spending its impression cap
+func (uc *BannerUsecase) PickOne(ctx context.Context, featureId int64, tagId int64, showLastRevision bool) (models.UserBanner, error) {
+	return uc.chooseBanner(ctx, featureId, tagId, showLastRevision)
+}
+
+// CountImpression counts the shown banner in statistics and, for a capped one, against the cap of the user.
+// Counters are best effort: errors are only logged
+func (uc *BannerUsecase) CountImpression(ctx context.Context, item models.UserBanner) {
+	now := time.Now().UTC()
+	username := utils.UsernameFromContext(ctx)
+	if item.ImpressionCap > 0 && username != "" && !utils.IsAdminFromContext(ctx) {
+		_, err := uc.impressions.AddImpression(ctx, username, item.Id, now)
+		if err != nil {
+			fmt.Printf("error while counting impression:%s\n", err.Error())
+		}
+	}
+	err := uc.counters.AddImpression(ctx, item.Id, now)
+	if err != nil {
+		fmt.Printf("error while counting impression:%s\n", err.Error())
+	}
+}
+
+func (uc *BannerUsecase) chooseBanner(ctx context.Context, featureId int64, tagId int64, showLastRevision bool) (models.UserBanner, error) {
+	banners, err := uc.getPairBanners(ctx, featureId, tagId, utils.IsAdminFromContext(ctx), showLastRevision)
+	if err != nil {
+		return models.UserBanner{}, err
+	}
+	return uc.pickServed(ctx, banners, featureId, tagId, showLastRevision)
+}
+
+// pickServed picks the banner of the pair for the user from context among its banners.
+// Banners whose impression cap the user reached are skipped, the feature default is the last resort
+func (uc *BannerUsecase) pickServed(ctx context.Context, banners []models.UserBanner, featureId int64, tagId int64, showLastRevision bool) (models.UserBanner, error) {
+	username := utils.UsernameFromContext(ctx)
+	admin := utils.IsAdminFromContext(ctx)
+	for len(banners) > 0 {
+		item := pickVariant(banners, username, featureId, tagId)
+		if admin || uc.underCap(ctx, username, item) {
+			return item, nil
+		}
+		banners = withoutBanner(banners, item.Id)
+		// баннер по умолчанию тоже исчерпан, больше показывать нечего
+		if item.Fallback {
+			return models.UserBanner{}, pgx.ErrNoRows
+		}
+	}
+	item, err := uc.getFallback(ctx, featureId, admin, showLastRevision)
+	if err != nil {
+		return models.UserBanner{}, err
+	}
+	if !uc.underCap(ctx, username, item) {
+		return models.UserBanner{}, pgx.ErrNoRows
+	}
+	return item, nil
+}
+
+// getPairBanners returns the banners of the pair, or the feature default when the pair has none
+func (uc *BannerUsecase) getPairBanners(ctx context.Context, featureId int64, tagId int64, admin bool, showLastRevision bool) ([]models.UserBanner, error) {
+	if !showLastRevision {
+		result, err := uc.cachedPair(ctx, featureId, tagId, admin)
+		if !errors.Is(err, errCacheMiss) {
+			return result, err
+		}
+		return uc.loadPair(ctx, models.FeatureTag{FeatureId: featureId, TagId: tagId}, admin)
+	}
+	result, err := uc.repo.GetOne(ctx, featureId, tagId, admin)
+	if err != nil {
+		return nil, err
+	}
+	uc.cacheLoaded(ctx, admin, map[models.FeatureTag][]models.UserBanner{{FeatureId: featureId, TagId: tagId}: result})
+	return result, nil
+}
+
+// cachedPair reads the banners of the pair from the cache. It returns pgx.ErrNoRows for a pair cached
+// as having no banners and errCacheMiss when the database has to be asked
+func (uc *BannerUsecase) cachedPair(ctx context.Context, featureId int64, tagId int64, admin bool) ([]models.UserBanner, error) {
+	result, err := uc.cache.GetBanners(ctx, featureId, tagId, admin)
+	if err == nil && len(result) > 0 {
 		return result, nil
 	}
-	result, err = uc.repo.GetOne(ctx, featureId, tagId)
+	if err == nil {
+		fallbacks, err := uc.cache.GetFallbacks(ctx, []int64{featureId}, admin)
+		if err == nil && fallbacks[0] != nil {
+			return []models.UserBanner{*fallbacks[0]}, nil
+		}
+		return nil, errCacheMiss
+	}
+	// маркер проверяем только после промаха: у пары с баннерами его нет
+	missing, err := uc.cache.IsNotFound(ctx, featureId, tagId, admin)
+	if err == nil && missing {
+		return nil, pgx.ErrNoRows
+	}
+	return nil, errCacheMiss
+}
+
+// getFallback returns the feature default for users who exhausted the banners of the pair
+func (uc *BannerUsecase) getFallback(ctx context.Context, featureId int64, admin bool, showLastRevision bool) (models.UserBanner, error) {
+	if !showLastRevision {
+		fallbacks, err := uc.cache.GetFallbacks(ctx, []int64{featureId}, admin)
+		if err == nil && fallbacks[0] != nil {
+			return *fallbacks[0], nil
+		}
+	}
+	result, err := uc.repo.GetFallback(ctx, featureId, admin)
 	if err != nil {
-		return result, err
+		return models.UserBanner{}, err
 	}
-	err = uc.cache.AddBanner(ctx, featureId, tagId, result)
+	err = uc.cache.AddFallbacks(ctx, admin, map[int64]models.UserBanner{featureId: result})
 	if err != nil {
 		fmt.Printf("error while trying to cache:%s", err.Error())
 	}
 	return result, nil
 }
 
-func (uc *BannerUsecase) UpdateBanner(ctx context.Context, payload models.BannerUpdateForm, id int64) error {
-	banner, err := uc.repo.GetById(ctx, id)
+// underCap reports whether the user may see the capped banner once more, the impression is counted by CountImpression.
+// When the counter is unavailable the banner is shown
+func (uc *BannerUsecase) underCap(ctx context.Context, username string, item models.UserBanner) bool {
+	if item.ImpressionCap == 0 || username == "" {
+		return true
+	}
+	count, err := uc.impressions.GetImpressions(ctx, username, item.Id, time.Now().UTC())
 	if err != nil {
-		return errors.New("not found")
+		fmt.Printf("error while reading impressions:%s\n", err.Error())
+		return true
 	}
-	if payload.Content != nil {
-		if payload.Content.Title != nil {
-			banner.Content.Title = *payload.Content.Title
+	return count < item.ImpressionCap
+}
+
+func withoutBanner(banners []models.UserBanner, id int64) []models.UserBanner {
+	result := make([]models.UserBanner, 0, len(banners))
+	for _, item := range banners {
+		if item.Id != id {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// cacheLoaded caches banners read from the database. Pairs served by the feature default are cached
+// as empty lists, while the default itself is cached once per feature
+func (uc *BannerUsecase) cacheLoaded(ctx context.Context, admin bool, loaded map[models.FeatureTag][]models.UserBanner) {
+	pairs := make(map[models.FeatureTag][]models.UserBanner, len(loaded))
+	fallbacks := make(map[int64]models.UserBanner)
+	for key, banners := range loaded {
+		if len(banners) == 1 && banners[0].Fallback {
+			pairs[key] = []models.UserBanner{}
+			fallbacks[key.FeatureId] = banners[0]
+			continue
+		}
+		pairs[key] = banners
+	}
+	err := uc.cache.AddManyBanners(ctx, admin, pairs)
+	if err == nil {
+		err = uc.cache.AddFallbacks(ctx, admin, fallbacks)
+	}
+	if err != nil {
+		fmt.Printf("error while trying to cache:%s", err.Error())
+	}
+}
+
+// GetMany returns banners for several feature:tag pairs keyed by "feature_id:tag_id".
+// Cached pairs and defaults of their features are read with one cache request, the rest with one database query.
+// Banners are picked for every pair like in GetOne, including impression caps, and counted in their stats
+func (uc *BannerUsecase) GetMany(ctx context.Context, keys []models.FeatureTag, showLastRevision bool) (map[string]models.UserBannerResult, error) {
+	keys = uniqueKeys(keys)
+	if len(keys) == 0 {
+		return nil, banner.ErrEmptyBatch
+	}
+	if len(keys) > maxBatchSize {
+		return nil, banner.ErrBatchTooLarge
+	}
+	admin := utils.IsAdminFromContext(ctx)
+
+	found := make(map[models.FeatureTag][]models.UserBanner, len(keys))
+	missed := keys
+	if !showLastRevision {
+		// баннеры фич по умолчанию читаются тем же запросом, что и пары, на случай если пара отдаёт его
+		featureIds := uniqueFeatures(keys)
+		cached, fallbacks, err := uc.cache.GetManyBanners(ctx, keys, featureIds, admin)
+		if err != nil {
+			fmt.Printf("error while reading cache:%s", err.Error())
+		} else {
+			defaults := make(map[int64]models.UserBanner, len(featureIds))
+			for i, featureId := range featureIds {
+				if fallbacks[i] != nil {
+					defaults[featureId] = *fallbacks[i]
+				}
+			}
+			missed = make([]models.FeatureTag, 0, len(keys))
+			for i, key := range keys {
+				switch {
+				case cached[i] == nil:
+					missed = append(missed, key)
+				case len(cached[i]) == 0:
+					// пара без своих баннеров ждёт баннер фичи по умолчанию
+					item, ok := defaults[key.FeatureId]
+					if !ok {
+						missed = append(missed, key)
+						continue
+					}
+					found[key] = []models.UserBanner{item}
+				default:
+					found[key] = cached[i]
+				}
+			}
+		}
+	}
+	if len(missed) > 0 {
+		loaded, err := uc.repo.GetMany(ctx, missed, admin)
+		if err != nil {
+			return nil, err
+		}
+		uc.cacheLoaded(ctx, admin, loaded)
+		for key, banners := range loaded {
+			found[key] = banners
 		}
-		if payload.Content.Data != nil {
-			banner.Content.Url = *payload.Content.Url
+	}
+
+	result := make(map[string]models.UserBannerResult, len(keys))
+	for _, key := range keys {
+		banners, ok := found[key]
+		if !ok {
+			result[key.String()] = models.UserBannerResult{}
+			continue
+		}
+		item, err := uc.pickServed(ctx, banners, key.FeatureId, key.TagId, showLastRevision)
+		if errors.Is(err, pgx.ErrNoRows) {
+			// пользователь исчерпал все баннеры пары
+			result[key.String()] = models.UserBannerResult{}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		entry := models.UserBannerResult{
+			Found:    true,
+			Content:  item.Content,
+			Inactive: !item.IsActive,
+			Fallback: item.Fallback,
+		}
+		if item.Weight > 0 {
+			entry.VariantId = item.Id
 		}
-		if payload.Content.Url != nil {
-			banner.Content.Url = *payload.Content.Url
+		result[key.String()] = entry
+		uc.CountImpression(ctx, item)
+	}
+	return result, nil
+}
+
+func uniqueFeatures(keys []models.FeatureTag) []int64 {
+	seen := make(map[int64]bool, len(keys))
+	result := make([]int64, 0, len(keys))
+	for _, key := range keys {
+		if !seen[key.FeatureId] {
+			seen[key.FeatureId] = true
+			result = append(result, key.FeatureId)
 		}
 	}
+	return result
+}
+
+func uniqueKeys(keys []models.FeatureTag) []models.FeatureTag {
+	seen := make(map[models.FeatureTag]bool, len(keys))
+	result := make([]models.FeatureTag, 0, len(keys))
+	for _, key := range keys {
+		if !seen[key] {
+			seen[key] = true
+			result = append(result, key)
+		}
+	}
+	return result
+}
+
+// pickVariant deterministically maps the user to one of the banners proportionally to their weights,
+// so a user always lands in the same variant while the weights stay the same
+func pickVariant(banners []models.UserBanner, username string, featureId int64, tagId int64) models.UserBanner {
+	var total int64
+	for _, item := range banners {
+		total += item.Weight
+	}
+	if len(banners) == 1 || total <= 0 {
+		return banners[0]
+	}
+	hasher := fnv.New64a()
+	_, _ = fmt.Fprintf(hasher, "%s:%d:%d", username, featureId, tagId)
+	point := int64(hasher.Sum64() % uint64(total))
+	for _, item := range banners {
+		if point < item.Weight {
+			return item
+		}
+		point -= item.Weight
+	}
+	return banners[len(banners)-1]
+}
+
+func (uc *BannerUsecase) GetBanner(ctx context.Context, id int64) (models.Banner, error) {
+	return uc.repo.GetBanner(ctx, id)
+}
+
+// UpdateBanner merges payload into the banner and returns its new version.
+// The stored version must be one of versions when the banner is written, empty versions skip the check
+func (uc *BannerUsecase) UpdateBanner(ctx context.Context, payload models.BannerUpdateForm, id int64, versions []int64) (int64, error) {
+	item, err := uc.repo.GetById(ctx, id)
+	if err != nil {
+		return 0, banner.ErrBannerNotFound
+	}
+	oldKeys := models.BannerPairs(item.FeatureId, item.TagIds)
+	if payload.Content != nil {
+		item.Content = *payload.Content
+	}
 	if payload.FeatureId != nil {
-		banner.FeatureId = *payload.FeatureId
+		item.FeatureId = *payload.FeatureId
 	}
 	if payload.TagIds != nil {
-		banner.TagIds = payload.TagIds
+		item.TagIds = payload.TagIds
 	}
 	if payload.IsActive != nil {
-		banner.IsActive = *payload.IsActive
+		item.IsActive = *payload.IsActive
+	}
+	if payload.ActiveFrom.Set {
+		item.ActiveFrom = payload.ActiveFrom.Value
+	}
+	if payload.ActiveUntil.Set {
+		item.ActiveUntil = payload.ActiveUntil.Value
+	}
+	if payload.Weight != nil {
+		item.Weight = *payload.Weight
+	}
+	if payload.ImpressionCap != nil {
+		item.ImpressionCap = *payload.ImpressionCap
+	}
+	if err := validateForm(item); err != nil {
+		return 0, err
+	}
+	if err := uc.checkContentSchema(ctx, item); err != nil {
+		return 0, err
+	}
+	newVersion, err := uc.repo.UpdateBanner(ctx, item, id, versions, utils.UsernameFromContext(ctx))
+	if err != nil {
+		if errors.Is(err, banner.ErrBannerConflict) || errors.Is(err, banner.ErrVersionMismatch) || errors.Is(err, banner.ErrBannerNotFound) {
+			return 0, err
+		}
+		return 0, errors.New("internal")
+	}
+	// пары, с которых баннер ушёл, тоже нужно сбросить, иначе там останется старое содержимое
+	uc.evict(ctx, append(oldKeys, models.BannerPairs(item.FeatureId, item.TagIds)...))
+	return newVersion, nil
+
+}
+
+func (uc *BannerUsecase) DeleteBanner(ctx context.Context, id int64, versions []int64) error {
+	var keys []models.FeatureTag
+	if item, err := uc.repo.GetById(ctx, id); err == nil {
+		keys = models.BannerPairs(item.FeatureId, item.TagIds)
+	}
+	err := uc.repo.DeleteBanner(ctx, id, versions, utils.UsernameFromContext(ctx))
+	if err != nil {
+		return err
+	}
+	uc.evict(ctx, keys)
+	return nil
+}
+
+// evict drops cached banners of the pairs after the change is committed, streams of the pairs are notified too.
+// The change is already saved, so a failed eviction is only logged and the pairs expire with DefaultExpTime
+func (uc *BannerUsecase) evict(ctx context.Context, keys []models.FeatureTag) {
+	err := uc.cache.DeleteBanners(ctx, uniqueKeys(keys))
+	if err != nil {
+		fmt.Printf("error while evicting banners:%s\n", err.Error())
+	}
+}
+
+// clearNotFound drops the not-found markers of the pairs of new banners, so the pairs are read from the database again.
+// New banners are drafts, publishing one evicts its pairs once more
+func (uc *BannerUsecase) clearNotFound(ctx context.Context, keys []models.FeatureTag) {
+	err := uc.cache.DeleteNotFound(ctx, uniqueKeys(keys))
+	if err != nil {
+		fmt.Printf("error while clearing not found banners:%s\n", err.Error())
+	}
+}
+
+func (uc *BannerUsecase) GetTrash(ctx context.Context, count int64, offset int64) ([]models.Banner, error) {
+	if count == 0 {
+		count = pageElementsCount
+	}
+	return uc.repo.GetTrash(ctx, count, offset)
+}
+
+// RestoreBanner takes the banner out of trash, it fails with banner.ErrBannerConflict
+// when its feature and tags were given to another banner in the meantime
+func (uc *BannerUsecase) RestoreBanner(ctx context.Context, id int64) error {
+	keys, err := uc.repo.RestoreBanner(ctx, id, utils.UsernameFromContext(ctx))
+	if err != nil {
+		return err
+	}
+	uc.evict(ctx, keys)
+	return nil
+}
+
+func (uc *BannerUsecase) GetRevisions(ctx context.Context, id int64) ([]models.BannerRevision, error) {
+	result, err := uc.repo.GetRevisions(ctx, id)
+	if err != nil {
+		return nil, err
 	}
-	err = uc.repo.UpdateBanner(ctx, banner, id)
+	if len(result) == 0 {
+		return nil, banner.ErrBannerNotFound
+	}
+	return result, nil
+}
+
+// RestoreRevision rolls the banner back to the given revision.
+// Rollback is stored as a new revision, so history is never rewritten, and is validated like any other edit
+func (uc *BannerUsecase) RestoreRevision(ctx context.Context, id int64, revision int64) error {
+	item, err := uc.repo.GetRevision(ctx, id, revision)
 	if err != nil {
-		return errors.New("internal")
+		if errors.Is(err, pgx.ErrNoRows) {
+			return banner.ErrRevisionNotFound
+		}
+		return err
+	}
+	form := models.BannerForm{
+		Content:       item.Content,
+		FeatureId:     item.FeatureId,
+		TagIds:        item.TagIds,
+		IsActive:      item.IsActive,
+		ActiveFrom:    item.ActiveFrom,
+		ActiveUntil:   item.ActiveUntil,
+		Weight:        item.Weight,
+		ImpressionCap: item.ImpressionCap,
+	}
+	// схема фичи могла поменяться после ревизии, старое содержимое проверяем как новое
+	if err := validateForm(form); err != nil {
+		return err
+	}
+	if err := uc.checkContentSchema(ctx, form); err != nil {
+		return err
+	}
+	keys := models.BannerPairs(form.FeatureId, form.TagIds)
+	if current, err := uc.repo.GetById(ctx, id); err == nil {
+		keys = append(keys, models.BannerPairs(current.FeatureId, current.TagIds)...)
 	}
+	_, err = uc.repo.UpdateBanner(ctx, form, id, nil, utils.UsernameFromContext(ctx))
+	if err != nil {
+		return err
+	}
+	uc.evict(ctx, keys)
 	return nil
+}
+
+// DeleteFiltered schedules removal of every banner matching feature and tag.
+// The banners are removed by worker.DeleteWorker, progress is tracked by the returned job
+func (uc *BannerUsecase) DeleteFiltered(ctx context.Context, featureId int64, tagId int64) (models.Job, error) {
+	if featureId == 0 && tagId == 0 {
+		return models.Job{}, banner.ErrEmptyFilter
+	}
+	return uc.jobs.AddJob(ctx, models.Job{
+		Kind:      models.JobKindDeleteBanners,
+		FeatureId: featureId,
+		TagId:     tagId,
+		Actor:     utils.UsernameFromContext(ctx),
+	})
+}
+
+// ImportBanners creates banners from import rows all-or-nothing.
+// Every row is validated and tried against the database, with dryRun nothing is saved
+func (uc *BannerUsecase) ImportBanners(ctx context.Context, rows []models.ImportRow, dryRun bool) (models.ImportReport, error) {
+	report := models.ImportReport{
+		DryRun: dryRun,
+		Total:  len(rows),
+		Errors: make([]models.ImportRowError, 0),
+	}
+	items := make([]models.Banner, 0, len(rows))
+	itemRows := make([]int, 0, len(rows))
+	for _, row := range rows {
+		if err := validateForm(row.Form); err != nil {
+			report.Errors = append(report.Errors, models.ImportRowError{Row: row.Row, Message: err.Error()})
+			continue
+		}
+		if err := uc.checkContentSchema(ctx, row.Form); err != nil {
+			if !errors.Is(err, banner.ErrContentMismatch) {
+				return models.ImportReport{}, err
+			}
+			report.Errors = append(report.Errors, models.ImportRowError{Row: row.Row, Message: err.Error()})
+			continue
+		}
+		items = append(items, newBanner(row.Form))
+		itemRows = append(itemRows, row.Row)
+	}
+
+	commit := !dryRun && len(report.Errors) == 0
+	rowErrors, err := uc.repo.AddItems(ctx, items, utils.UsernameFromContext(ctx), commit)
+	if err != nil {
+		return models.ImportReport{}, err
+	}
+	for i, rowErr := range rowErrors {
+		if rowErr != nil {
+			report.Errors = append(report.Errors, models.ImportRowError{Row: itemRows[i], Message: rowErr.Error()})
+		}
+	}
+	sort.Slice(report.Errors, func(i, j int) bool {
+		return report.Errors[i].Row < report.Errors[j].Row
+	})
+	if commit && len(report.Errors) == 0 {
+		report.Imported = len(items)
+		keys := make([]models.FeatureTag, 0, len(items))
+		for _, item := range items {
+			keys = append(keys, models.BannerPairs(item.FeatureId, item.TagIds)...)
+		}
+		uc.clearNotFound(ctx, keys)
+	}
+	return report, nil
+}
 
+func (uc *BannerUsecase) ExportBanners(ctx context.Context, featureId int64, tagId int64, fn func(models.BannerForm) error) error {
+	return uc.repo.ExportFiltered(ctx, featureId, tagId, fn)
 }
 
-func (uc *BannerUsecase) DeleteBanner(ctx context.Context, id int64) error {
-	err := uc.repo.DeleteBanner(ctx, id)
+func validateForm(data models.BannerForm) error {
+	if !data.Content.IsObject() {
+		return banner.ErrInvalidContent
+	}
+	if !models.ActiveWindowValid(data.ActiveFrom, data.ActiveUntil) {
+		return banner.ErrInvalidWindow
+	}
+	if data.Weight < 0 {
+		return banner.ErrInvalidWeight
+	}
+	if data.ImpressionCap < 0 {
+		return banner.ErrInvalidImpressionCap
+	}
+	return nil
+}
+
+// checkContentSchema validates banner content against the JSON Schema of its feature, if the feature has one
+func (uc *BannerUsecase) checkContentSchema(ctx context.Context, data models.BannerForm) error {
+	schemaData, err := uc.repo.GetContentSchema(ctx, data.FeatureId)
 	if err != nil {
 		return err
 	}
+	if schemaData == nil {
+		return nil
+	}
+	schema, err := utils.CompileSchema(schemaData)
+	if err != nil {
+		return fmt.Errorf("can`t compile content schema of feature %d: %w", data.FeatureId, err)
+	}
+	if err := utils.ValidateJSON(schema, data.Content); err != nil {
+		return fmt.Errorf("%w: %s", banner.ErrContentMismatch, err.Error())
+	}
 	return nil
 }
+
+func newBanner(data models.BannerForm) models.Banner {
+	return models.Banner{
+		Content:       data.Content,
+		FeatureId:     data.FeatureId,
+		TagIds:        data.TagIds,
+		IsActive:      data.IsActive,
+		ActiveFrom:    data.ActiveFrom,
+		ActiveUntil:   data.ActiveUntil,
+		Weight:        data.Weight,
+		ImpressionCap: data.ImpressionCap,
+		Version:       1,
+		Status:        models.BannerStatusDraft,
+		CreateTime:    time.Now().UTC(),
+		UpdateTime:    time.Now().UTC(),
+	}
+}
@@ -0,0 +1,107 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Alladan04/avito_test/internal/models"
+	"github.com/Alladan04/avito_test/internal/pkg/banner"
+	"github.com/Alladan04/avito_test/internal/pkg/job"
+	"github.com/jackc/pgx/v4"
+)
+
+const (
+	deletePollInterval = time.Second
+	deleteBatchSize    = 100
+	// задача, которая не обновлялась дольше этого времени, считается брошенной
+	deleteJobStaleAfter = 5 * time.Minute
+)
+
+// DeleteWorker performs mass deletion jobs created by BannerUsecase.DeleteFiltered
+type DeleteWorker struct {
+	jobs  job.JobRepo
+	repo  banner.BannerRepo
+	cache banner.CacheRepo
+}
+
+func NewDeleteWorker(jobs job.JobRepo, repo banner.BannerRepo, cache banner.CacheRepo) *DeleteWorker {
+	return &DeleteWorker{
+		jobs:  jobs,
+		repo:  repo,
+		cache: cache,
+	}
+}
+
+// Run polls for pending jobs until ctx is cancelled
+func (w *DeleteWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(deletePollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.processJobs(ctx)
+		}
+	}
+}
+
+func (w *DeleteWorker) processJobs(ctx context.Context) {
+	for {
+		item, err := w.jobs.TakeJob(ctx, models.JobKindDeleteBanners, deleteJobStaleAfter)
+		if err != nil {
+			if !errors.Is(err, pgx.ErrNoRows) {
+				fmt.Printf("error while taking delete job:%s\n", err.Error())
+			}
+			return
+		}
+		w.process(ctx, item)
+	}
+}
+
+func (w *DeleteWorker) process(ctx context.Context, item models.Job) {
+	total, err := w.repo.CountFiltered(ctx, item.FeatureId, item.TagId)
+	if err != nil {
+		w.fail(ctx, item, err)
+		return
+	}
+	item.Total = item.Processed + total
+	for {
+		keys, deleted, err := w.repo.DeleteFiltered(ctx, item.FeatureId, item.TagId, deleteBatchSize, item.Actor)
+		if err != nil {
+			w.fail(ctx, item, err)
+			return
+		}
+		if deleted == 0 {
+			break
+		}
+		err = w.cache.DeleteBanners(ctx, keys)
+		if err != nil {
+			fmt.Printf("error while evicting deleted banners:%s\n", err.Error())
+		}
+		item.Processed += deleted
+		if item.Processed > item.Total {
+			item.Total = item.Processed
+		}
+		err = w.jobs.UpdateJob(ctx, item)
+		if err != nil {
+			fmt.Printf("error while updating job %d:%s\n", item.Id, err.Error())
+		}
+	}
+	item.Status = models.JobStatusDone
+	err = w.jobs.UpdateJob(ctx, item)
+	if err != nil {
+		fmt.Printf("error while updating job %d:%s\n", item.Id, err.Error())
+	}
+}
+
+func (w *DeleteWorker) fail(ctx context.Context, item models.Job, cause error) {
+	item.Status = models.JobStatusFailed
+	item.Error = cause.Error()
+	err := w.jobs.UpdateJob(ctx, item)
+	if err != nil {
+		fmt.Printf("error while updating job %d:%s\n", item.Id, err.Error())
+	}
+}
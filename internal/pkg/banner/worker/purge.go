@@ -0,0 +1,58 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Alladan04/avito_test/internal/pkg/banner"
+)
+
+const (
+	purgePollInterval = time.Minute
+	purgeBatchSize    = 100
+
+	// DefaultTrashRetention is how long deleted banners can be restored, unless TRASH_RETENTION is set
+	DefaultTrashRetention = 30 * 24 * time.Hour
+)
+
+// PurgeWorker permanently removes banners which have been in trash longer than retention
+type PurgeWorker struct {
+	repo      banner.BannerRepo
+	retention time.Duration
+}
+
+func NewPurgeWorker(repo banner.BannerRepo, retention time.Duration) *PurgeWorker {
+	return &PurgeWorker{
+		repo:      repo,
+		retention: retention,
+	}
+}
+
+// Run purges the trash until ctx is cancelled
+func (w *PurgeWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(purgePollInterval)
+	defer ticker.Stop()
+	for {
+		w.purge(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *PurgeWorker) purge(ctx context.Context) {
+	before := time.Now().UTC().Add(-w.retention)
+	for ctx.Err() == nil {
+		purged, err := w.repo.PurgeDeleted(ctx, before, purgeBatchSize)
+		if err != nil {
+			fmt.Printf("error while purging deleted banners:%s\n", err.Error())
+			return
+		}
+		if purged < purgeBatchSize {
+			return
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Alladan04/avito_test/internal/pkg/banner"
+)
+
+const schedulePollInterval = 5 * time.Second
+
+// ScheduleWorker switches is_active of banners at the boundaries of their activation window.
+// While a banner has a window, the window owns its is_active flag
+type ScheduleWorker struct {
+	repo  banner.BannerRepo
+	cache banner.CacheRepo
+}
+
+func NewScheduleWorker(repo banner.BannerRepo, cache banner.CacheRepo) *ScheduleWorker {
+	return &ScheduleWorker{
+		repo:  repo,
+		cache: cache,
+	}
+}
+
+// Run applies the schedule until ctx is cancelled
+func (w *ScheduleWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(schedulePollInterval)
+	defer ticker.Stop()
+	for {
+		w.apply(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *ScheduleWorker) apply(ctx context.Context) {
+	keys, err := w.repo.ApplySchedule(ctx, time.Now().UTC())
+	if err != nil {
+		fmt.Printf("error while applying banner schedule:%s\n", err.Error())
+		return
+	}
+	err = w.cache.DeleteBanners(ctx, keys)
+	if err != nil {
+		fmt.Printf("error while evicting scheduled banners:%s\n", err.Error())
+	}
+}
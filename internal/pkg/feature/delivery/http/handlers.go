@@ -0,0 +1,216 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Alladan04/avito_test/internal/models"
+	"github.com/Alladan04/avito_test/internal/pkg/feature"
+	"github.com/Alladan04/avito_test/internal/pkg/utils"
+	"github.com/gorilla/mux"
+)
+
+type FeatureHandler struct {
+	uc feature.FeatureUsecase
+}
+
+func NewFeatureHandler(uc feature.FeatureUsecase) *FeatureHandler {
+	return &FeatureHandler{
+		uc: uc,
+	}
+}
+
+// AddFeature creates a new feature
+// for admins only
+func (h *FeatureHandler) AddFeature(w http.ResponseWriter, r *http.Request) {
+	item := models.FeatureForm{}
+	err := utils.GetRequestData(r, &item)
+	if err != nil {
+		utils.WriteErrorMessage(w, http.StatusBadRequest, "error unmarshalling")
+		return
+	}
+	if err := item.Validate(); err != nil {
+		utils.WriteErrorMessage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	result, err := h.uc.AddFeature(r.Context(), item)
+	if err != nil {
+		utils.WriteErrorMessage(w, http.StatusBadRequest, "error Adding data")
+		return
+	}
+	err = utils.WriteResponseData(w, result, http.StatusCreated)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// GetFeatures returns features, query param search filters them by name
+// for admins only
+func (h *FeatureHandler) GetFeatures(w http.ResponseWriter, r *http.Request) {
+	countParam := r.URL.Query().Get("limit")
+	offsetParam := r.URL.Query().Get("offset")
+	search := r.URL.Query().Get("search")
+
+	count, err := strconv.ParseInt(countParam, 10, 64)
+	if err != nil && countParam != "" {
+		utils.WriteErrorMessage(w, http.StatusBadRequest, "wrong count param")
+		return
+	}
+	offset, err := strconv.ParseInt(offsetParam, 10, 64)
+	if err != nil && offsetParam != "" {
+		utils.WriteErrorMessage(w, http.StatusBadRequest, "wrong offset param")
+		return
+	}
+
+	result, err := h.uc.GetFeatures(r.Context(), count, offset, search)
+	if err != nil {
+		utils.WriteErrorMessage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	err = utils.WriteResponseData(w, result, http.StatusOK)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// UpdateFeature renames a feature
+// for admins only
+func (h *FeatureHandler) UpdateFeature(w http.ResponseWriter, r *http.Request) {
+	featureIdString := mux.Vars(r)["id"]
+	featureId, err := strconv.ParseInt(featureIdString, 10, 64)
+	if err != nil {
+		utils.WriteErrorMessage(w, http.StatusBadRequest, "cant parse id")
+		return
+	}
+	item := models.FeatureForm{}
+	err = utils.GetRequestData(r, &item)
+	if err != nil {
+		utils.WriteErrorMessage(w, http.StatusBadRequest, "error unmarshalling")
+		return
+	}
+	if err := item.Validate(); err != nil {
+		utils.WriteErrorMessage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	result, err := h.uc.UpdateFeature(r.Context(), featureId, item)
+	if err != nil {
+		if errors.Is(err, feature.ErrFeatureNotFound) {
+			utils.WriteErrorMessage(w, http.StatusNotFound, err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	err = utils.WriteResponseData(w, result, http.StatusOK)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// DeleteFeature deletes a feature, with cascade=true its banners are deleted as well
+// for admins only
+func (h *FeatureHandler) DeleteFeature(w http.ResponseWriter, r *http.Request) {
+	featureIdString := mux.Vars(r)["id"]
+	featureId, err := strconv.ParseInt(featureIdString, 10, 64)
+	if err != nil {
+		utils.WriteErrorMessage(w, http.StatusBadRequest, "cant parse id")
+		return
+	}
+	cascadeParam := r.URL.Query().Get("cascade")
+	cascade, err := strconv.ParseBool(cascadeParam)
+	if err != nil && cascadeParam != "" {
+		utils.WriteErrorMessage(w, http.StatusBadRequest, "wrong cascade param")
+		return
+	}
+
+	err = h.uc.DeleteFeature(r.Context(), featureId, cascade)
+	if err != nil {
+		if errors.Is(err, feature.ErrFeatureNotFound) {
+			utils.WriteErrorMessage(w, http.StatusNotFound, err.Error())
+			return
+		}
+		if errors.Is(err, feature.ErrFeatureInUse) {
+			utils.WriteErrorMessage(w, http.StatusConflict, err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SetDefaultBanner sets the banner served for tags of the feature without a banner of their own,
+// null banner_id removes it
+// for admins only
+func (h *FeatureHandler) SetDefaultBanner(w http.ResponseWriter, r *http.Request) {
+	featureIdString := mux.Vars(r)["id"]
+	featureId, err := strconv.ParseInt(featureIdString, 10, 64)
+	if err != nil {
+		utils.WriteErrorMessage(w, http.StatusBadRequest, "cant parse id")
+		return
+	}
+	var form models.DefaultBannerForm
+	err = utils.GetRequestData(r, &form)
+	if err != nil {
+		utils.WriteErrorMessage(w, http.StatusBadRequest, "error unmarshalling")
+		return
+	}
+
+	result, err := h.uc.SetDefaultBanner(r.Context(), featureId, form.BannerId)
+	if err != nil {
+		if errors.Is(err, feature.ErrFeatureNotFound) {
+			utils.WriteErrorMessage(w, http.StatusNotFound, err.Error())
+			return
+		}
+		if errors.Is(err, feature.ErrInvalidDefaultBanner) {
+			utils.WriteErrorMessage(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	err = utils.WriteResponseData(w, result, http.StatusOK)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// SetContentSchema sets JSON Schema that content of the feature banners must match, null body removes it
+// for admins only
+func (h *FeatureHandler) SetContentSchema(w http.ResponseWriter, r *http.Request) {
+	featureIdString := mux.Vars(r)["id"]
+	featureId, err := strconv.ParseInt(featureIdString, 10, 64)
+	if err != nil {
+		utils.WriteErrorMessage(w, http.StatusBadRequest, "cant parse id")
+		return
+	}
+	var schema json.RawMessage
+	err = utils.GetRequestData(r, &schema)
+	if err != nil {
+		utils.WriteErrorMessage(w, http.StatusBadRequest, "error unmarshalling")
+		return
+	}
+
+	result, err := h.uc.SetContentSchema(r.Context(), featureId, schema)
+	if err != nil {
+		if errors.Is(err, feature.ErrFeatureNotFound) {
+			utils.WriteErrorMessage(w, http.StatusNotFound, err.Error())
+			return
+		}
+		if errors.Is(err, feature.ErrInvalidSchema) {
+			utils.WriteErrorMessage(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	err = utils.WriteResponseData(w, result, http.StatusOK)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,33 @@
+package feature
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Alladan04/avito_test/internal/models"
+)
+
+var (
+	ErrFeatureNotFound      = errors.New("feature not found")
+	ErrFeatureInUse         = errors.New("feature is used by banners, pass cascade=true to delete them too")
+	ErrInvalidSchema        = errors.New("invalid content schema")
+	ErrInvalidDefaultBanner = errors.New("default banner must be a banner of the feature")
+)
+
+type FeatureRepo interface {
+	AddFeature(ctx context.Context, name string, actor string) (models.Feature, error)
+	GetFeatures(ctx context.Context, count int64, offset int64, search string) ([]models.Feature, error)
+	UpdateFeature(ctx context.Context, id int64, name string, actor string) (models.Feature, error)
+	DeleteFeature(ctx context.Context, id int64, cascade bool, actor string) ([]models.FeatureTag, error)
+	SetContentSchema(ctx context.Context, id int64, schema []byte, actor string) (models.Feature, error)
+	SetDefaultBanner(ctx context.Context, id int64, bannerId *int64, actor string) (models.Feature, error)
+}
+
+type FeatureUsecase interface {
+	AddFeature(ctx context.Context, data models.FeatureForm) (models.Feature, error)
+	GetFeatures(ctx context.Context, count int64, offset int64, search string) ([]models.Feature, error)
+	UpdateFeature(ctx context.Context, id int64, data models.FeatureForm) (models.Feature, error)
+	DeleteFeature(ctx context.Context, id int64, cascade bool) error
+	SetContentSchema(ctx context.Context, id int64, schema []byte) (models.Feature, error)
+	SetDefaultBanner(ctx context.Context, id int64, bannerId *int64) (models.Feature, error)
+}
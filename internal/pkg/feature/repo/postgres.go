@@ -0,0 +1,223 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Alladan04/avito_test/internal/models"
+	auditRepo "github.com/Alladan04/avito_test/internal/pkg/audit/repo"
+	"github.com/Alladan04/avito_test/internal/pkg/banner"
+	bannerRepo "github.com/Alladan04/avito_test/internal/pkg/banner/repo"
+	"github.com/Alladan04/avito_test/internal/pkg/feature"
+	"github.com/jackc/pgtype/pgxtype"
+	"github.com/jackc/pgx/v4"
+)
+
+const (
+	addFeature  = `INSERT INTO feature (feature_data) VALUES ($1) RETURNING id, feature_data, content_schema, default_banner_id;`
+	getFeatures = `SELECT id, coalesce(feature_data, ''), content_schema, default_banner_id FROM feature
+					WHERE $1 = '' OR feature_data ILIKE '%' || $1 || '%'
+					ORDER BY id
+					LIMIT $2 OFFSET $3;`
+	updateFeature    = `UPDATE feature SET feature_data=$1 WHERE id=$2 RETURNING id, feature_data, content_schema, default_banner_id;`
+	setContentSchema = `UPDATE feature SET content_schema=$1 WHERE id=$2 RETURNING id, coalesce(feature_data, ''), content_schema, default_banner_id;`
+	deleteFeature    = `DELETE FROM feature WHERE id=$1;`
+	// блокируем фичу, чтобы снимок для аудита совпадал с тем, что меняем
+	setDefaultBanner = `UPDATE feature SET default_banner_id=$1 WHERE id=$2 RETURNING id, coalesce(feature_data, ''), content_schema, default_banner_id;`
+	// баннер по умолчанию должен принадлежать фиче и не лежать в корзине
+	isFeatureBanner     = `SELECT EXISTS (SELECT 1 FROM banner WHERE id=$1 AND feature_id=$2 AND deleted_at IS NULL FOR SHARE);`
+	getFeatureForUpdate = `SELECT id, coalesce(feature_data, ''), content_schema, default_banner_id FROM feature WHERE id=$1 FOR UPDATE;`
+)
+
+type FeatureRepo struct {
+	db   pgxtype.Querier
+	conn pgx.Conn
+}
+
+func NewFeatureRepo(db pgxtype.Querier, conn pgx.Conn) *FeatureRepo {
+	return &FeatureRepo{
+		db:   db,
+		conn: conn,
+	}
+}
+
+func (repo *FeatureRepo) AddFeature(ctx context.Context, name string, actor string) (models.Feature, error) {
+	tx, err := repo.conn.Begin(ctx)
+	if err != nil {
+		return models.Feature{}, err
+	}
+	defer func() {
+		if err = tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			fmt.Printf("ERROR: %v", err)
+		}
+	}()
+	var result models.Feature
+	err = tx.QueryRow(ctx, addFeature, name).Scan(&result.Id, &result.Name, &result.ContentSchema, &result.DefaultBannerId)
+	if err != nil {
+		return models.Feature{}, err
+	}
+	err = addAuditEntry(ctx, tx, actor, models.AuditActionCreate, result.Id, nil, result)
+	if err != nil {
+		return models.Feature{}, err
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		return models.Feature{}, err
+	}
+	return result, nil
+}
+
+// GetFeatures returns a page of features, search filters them by a case insensitive substring of the name
+func (repo *FeatureRepo) GetFeatures(ctx context.Context, count int64, offset int64, search string) ([]models.Feature, error) {
+	result := make([]models.Feature, 0, count)
+	rows, err := repo.db.Query(ctx, getFeatures, search, count, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var item models.Feature
+		if err := rows.Scan(&item.Id, &item.Name, &item.ContentSchema, &item.DefaultBannerId); err != nil {
+			return nil, fmt.Errorf("error occured while scanning features:%w", err)
+		}
+		result = append(result, item)
+	}
+
+	return result, rows.Err()
+}
+
+func (repo *FeatureRepo) UpdateFeature(ctx context.Context, id int64, name string, actor string) (models.Feature, error) {
+	return repo.updateFeature(ctx, id, actor, models.AuditActionUpdate, updateFeature, name)
+}
+
+// SetContentSchema replaces JSON Schema of the feature banners content, nil schema removes it
+func (repo *FeatureRepo) SetContentSchema(ctx context.Context, id int64, schema []byte, actor string) (models.Feature, error) {
+	return repo.updateFeature(ctx, id, actor, models.AuditActionSetSchema, setContentSchema, schema)
+}
+
+// SetDefaultBanner makes the banner of the feature its default, nil bannerId removes the default
+func (repo *FeatureRepo) SetDefaultBanner(ctx context.Context, id int64, bannerId *int64, actor string) (models.Feature, error) {
+	tx, err := repo.conn.Begin(ctx)
+	if err != nil {
+		return models.Feature{}, err
+	}
+	defer func() {
+		if err = tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			fmt.Printf("ERROR: %v", err)
+		}
+	}()
+	var before, result models.Feature
+	err = tx.QueryRow(ctx, getFeatureForUpdate, id).Scan(&before.Id, &before.Name, &before.ContentSchema, &before.DefaultBannerId)
+	if err != nil {
+		return models.Feature{}, err
+	}
+	if bannerId != nil {
+		var ok bool
+		err = tx.QueryRow(ctx, isFeatureBanner, *bannerId, id).Scan(&ok)
+		if err != nil {
+			return models.Feature{}, err
+		}
+		if !ok {
+			return models.Feature{}, feature.ErrInvalidDefaultBanner
+		}
+	}
+	err = tx.QueryRow(ctx, setDefaultBanner, bannerId, id).Scan(&result.Id, &result.Name, &result.ContentSchema, &result.DefaultBannerId)
+	if err != nil {
+		return models.Feature{}, err
+	}
+	err = addAuditEntry(ctx, tx, actor, models.AuditActionSetDefaultBanner, id, before, result)
+	if err != nil {
+		return models.Feature{}, err
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		return models.Feature{}, err
+	}
+	return result, nil
+}
+
+// updateFeature runs query with value and id as arguments and logs the change as action
+func (repo *FeatureRepo) updateFeature(ctx context.Context, id int64, actor string, action string, query string, value any) (models.Feature, error) {
+	tx, err := repo.conn.Begin(ctx)
+	if err != nil {
+		return models.Feature{}, err
+	}
+	defer func() {
+		if err = tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			fmt.Printf("ERROR: %v", err)
+		}
+	}()
+	var before, result models.Feature
+	err = tx.QueryRow(ctx, getFeatureForUpdate, id).Scan(&before.Id, &before.Name, &before.ContentSchema, &before.DefaultBannerId)
+	if err != nil {
+		return models.Feature{}, err
+	}
+	err = tx.QueryRow(ctx, query, value, id).Scan(&result.Id, &result.Name, &result.ContentSchema, &result.DefaultBannerId)
+	if err != nil {
+		return models.Feature{}, err
+	}
+	err = addAuditEntry(ctx, tx, actor, action, id, before, result)
+	if err != nil {
+		return models.Feature{}, err
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		return models.Feature{}, err
+	}
+	return result, nil
+}
+
+// DeleteFeature removes the feature, with cascade its banners are removed as well,
+// otherwise feature.ErrFeatureInUse is returned for a feature used by banners.
+// It returns the feature:tag pairs that were served by the removed banners
+func (repo *FeatureRepo) DeleteFeature(ctx context.Context, id int64, cascade bool, actor string) ([]models.FeatureTag, error) {
+	tx, err := repo.conn.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err = tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			fmt.Printf("ERROR: %v", err)
+		}
+	}()
+	var before models.Feature
+	err = tx.QueryRow(ctx, getFeatureForUpdate, id).Scan(&before.Id, &before.Name, &before.ContentSchema, &before.DefaultBannerId)
+	if err != nil {
+		return nil, err
+	}
+	//пока фича заблокирована, новых баннеров у неё не появится
+	keys, err := bannerRepo.DeleteFeatureBanners(ctx, tx, id, cascade, actor)
+	if err != nil {
+		if errors.Is(err, banner.ErrUsedByBanners) {
+			return nil, feature.ErrFeatureInUse
+		}
+		return nil, err
+	}
+	_, err = tx.Exec(ctx, deleteFeature, id)
+	if err != nil {
+		return nil, err
+	}
+	err = addAuditEntry(ctx, tx, actor, models.AuditActionDelete, id, before, nil)
+	if err != nil {
+		return nil, err
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func addAuditEntry(ctx context.Context, tx pgx.Tx, actor string, action string, id int64, before any, after any) error {
+	return auditRepo.AddEntry(ctx, tx, models.AuditEntry{
+		Actor:      actor,
+		Action:     action,
+		Entity:     models.AuditEntityFeature,
+		EntityId:   id,
+		Before:     models.AuditSnapshot(before),
+		After:      models.AuditSnapshot(after),
+		CreateTime: time.Now().UTC(),
+	})
+}
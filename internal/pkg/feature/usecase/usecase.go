@@ -0,0 +1,105 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Alladan04/avito_test/internal/models"
+	"github.com/Alladan04/avito_test/internal/pkg/banner"
+	"github.com/Alladan04/avito_test/internal/pkg/feature"
+	"github.com/Alladan04/avito_test/internal/pkg/utils"
+	"github.com/jackc/pgx/v4"
+)
+
+const (
+	pageElementsCount = 10
+)
+
+type FeatureUsecase struct {
+	repo  feature.FeatureRepo
+	cache banner.CacheRepo
+}
+
+func NewFeatureUsecase(repo feature.FeatureRepo, cache banner.CacheRepo) *FeatureUsecase {
+	return &FeatureUsecase{
+		repo:  repo,
+		cache: cache,
+	}
+}
+
+func (uc *FeatureUsecase) AddFeature(ctx context.Context, data models.FeatureForm) (models.Feature, error) {
+	return uc.repo.AddFeature(ctx, data.Name, utils.UsernameFromContext(ctx))
+}
+
+func (uc *FeatureUsecase) GetFeatures(ctx context.Context, count int64, offset int64, search string) ([]models.Feature, error) {
+	if count == 0 {
+		count = pageElementsCount
+	}
+	return uc.repo.GetFeatures(ctx, count, offset, search)
+}
+
+func (uc *FeatureUsecase) UpdateFeature(ctx context.Context, id int64, data models.FeatureForm) (models.Feature, error) {
+	result, err := uc.repo.UpdateFeature(ctx, id, data.Name, utils.UsernameFromContext(ctx))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Feature{}, feature.ErrFeatureNotFound
+		}
+		return models.Feature{}, err
+	}
+	return result, nil
+}
+
+// SetContentSchema makes banners of the feature validate their content against schema.
+// Empty or null schema turns validation off
+func (uc *FeatureUsecase) SetContentSchema(ctx context.Context, id int64, schema []byte) (models.Feature, error) {
+	schema = bytes.TrimSpace(schema)
+	if len(schema) == 0 || bytes.Equal(schema, []byte("null")) {
+		schema = nil
+	} else if _, err := utils.CompileSchema(schema); err != nil {
+		return models.Feature{}, fmt.Errorf("%w: %s", feature.ErrInvalidSchema, err.Error())
+	}
+	result, err := uc.repo.SetContentSchema(ctx, id, schema, utils.UsernameFromContext(ctx))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Feature{}, feature.ErrFeatureNotFound
+		}
+		return models.Feature{}, err
+	}
+	return result, nil
+}
+
+// SetDefaultBanner sets the banner served for tags of the feature without a banner of their own
+func (uc *FeatureUsecase) SetDefaultBanner(ctx context.Context, id int64, bannerId *int64) (models.Feature, error) {
+	result, err := uc.repo.SetDefaultBanner(ctx, id, bannerId, utils.UsernameFromContext(ctx))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Feature{}, feature.ErrFeatureNotFound
+		}
+		return models.Feature{}, err
+	}
+	// пары, закешированные как ожидающие баннер по умолчанию, перечитают его из базы
+	err = uc.cache.DeleteFallback(ctx, id)
+	if err != nil {
+		fmt.Printf("error while evicting default banner:%s\n", err.Error())
+	}
+	return result, nil
+}
+
+// DeleteFeature refuses to delete a feature used by banners unless cascade is set,
+// in which case the banners are deleted as well. The check and the deletion share one transaction
+func (uc *FeatureUsecase) DeleteFeature(ctx context.Context, id int64, cascade bool) error {
+	keys, err := uc.repo.DeleteFeature(ctx, id, cascade, utils.UsernameFromContext(ctx))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return feature.ErrFeatureNotFound
+		}
+		return err
+	}
+	err = uc.cache.DeleteBanners(ctx, keys)
+	if err != nil {
+		fmt.Printf("error while evicting deleted banners:%s\n", err.Error())
+	}
+	return nil
+}
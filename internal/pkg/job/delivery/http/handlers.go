@@ -0,0 +1,46 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Alladan04/avito_test/internal/pkg/job"
+	"github.com/Alladan04/avito_test/internal/pkg/utils"
+	"github.com/gorilla/mux"
+)
+
+type JobHandler struct {
+	uc job.JobUsecase
+}
+
+func NewJobHandler(uc job.JobUsecase) *JobHandler {
+	return &JobHandler{
+		uc: uc,
+	}
+}
+
+// GetJob returns progress of a background job
+// for admins only
+func (h *JobHandler) GetJob(w http.ResponseWriter, r *http.Request) {
+	jobIdString := mux.Vars(r)["id"]
+	jobId, err := strconv.ParseInt(jobIdString, 10, 64)
+	if err != nil {
+		utils.WriteErrorMessage(w, http.StatusBadRequest, "cant parse id")
+		return
+	}
+
+	result, err := h.uc.GetJob(r.Context(), jobId)
+	if err != nil {
+		if errors.Is(err, job.ErrJobNotFound) {
+			utils.WriteErrorMessage(w, http.StatusNotFound, err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	err = utils.WriteResponseData(w, result, http.StatusOK)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
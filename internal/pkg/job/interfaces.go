@@ -0,0 +1,24 @@
+package job
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Alladan04/avito_test/internal/models"
+)
+
+var (
+	ErrJobNotFound = errors.New("job not found")
+)
+
+type JobRepo interface {
+	AddJob(ctx context.Context, job models.Job) (models.Job, error)
+	GetJob(ctx context.Context, id int64) (models.Job, error)
+	TakeJob(ctx context.Context, kind string, staleAfter time.Duration) (models.Job, error)
+	UpdateJob(ctx context.Context, job models.Job) error
+}
+
+type JobUsecase interface {
+	GetJob(ctx context.Context, id int64) (models.Job, error)
+}
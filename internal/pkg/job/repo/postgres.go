@@ -0,0 +1,113 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/Alladan04/avito_test/internal/models"
+	"github.com/jackc/pgtype/pgxtype"
+)
+
+const (
+	addJob = `INSERT INTO job (kind, status, feature_id, tag_id, actor, create_time, update_time)
+					VALUES ($1, $2, $3, $4, $5, $6, $6)
+					RETURNING id, kind, status, feature_id, tag_id, actor, total, processed, error, create_time, update_time;`
+	getJob = `SELECT id, kind, status, feature_id, tag_id, actor, total, processed, error, create_time, update_time
+					FROM job
+					WHERE id=$1;`
+	// забираем ожидающую задачу, либо зависшую после падения другой реплики
+	takeJob = `UPDATE job SET status='running', update_time=$2
+					WHERE id = (
+						SELECT id FROM job
+						WHERE kind=$1 AND (status='pending' OR (status='running' AND update_time < $3))
+						ORDER BY id
+						LIMIT 1
+						FOR UPDATE SKIP LOCKED
+					)
+					RETURNING id, kind, status, feature_id, tag_id, actor, total, processed, error, create_time, update_time;`
+	updateJob = `UPDATE job SET status=$1, total=$2, processed=$3, error=$4, update_time=$5 WHERE id=$6;`
+)
+
+type JobRepo struct {
+	db pgxtype.Querier
+}
+
+func NewJobRepo(db pgxtype.Querier) *JobRepo {
+	return &JobRepo{
+		db: db,
+	}
+}
+
+func (repo *JobRepo) AddJob(ctx context.Context, job models.Job) (models.Job, error) {
+	var result models.Job
+	err := repo.db.QueryRow(ctx, addJob, job.Kind, models.JobStatusPending, job.FeatureId, job.TagId, job.Actor, time.Now().UTC()).Scan(
+		&result.Id,
+		&result.Kind,
+		&result.Status,
+		&result.FeatureId,
+		&result.TagId,
+		&result.Actor,
+		&result.Total,
+		&result.Processed,
+		&result.Error,
+		&result.CreateTime,
+		&result.UpdateTime,
+	)
+	if err != nil {
+		return models.Job{}, err
+	}
+	return result, nil
+}
+
+func (repo *JobRepo) GetJob(ctx context.Context, id int64) (models.Job, error) {
+	var result models.Job
+	err := repo.db.QueryRow(ctx, getJob, id).Scan(
+		&result.Id,
+		&result.Kind,
+		&result.Status,
+		&result.FeatureId,
+		&result.TagId,
+		&result.Actor,
+		&result.Total,
+		&result.Processed,
+		&result.Error,
+		&result.CreateTime,
+		&result.UpdateTime,
+	)
+	if err != nil {
+		return models.Job{}, err
+	}
+	return result, nil
+}
+
+// TakeJob marks the oldest pending job of the given kind as running and returns it.
+// Jobs left running for longer than staleAfter are considered abandoned and taken again
+func (repo *JobRepo) TakeJob(ctx context.Context, kind string, staleAfter time.Duration) (models.Job, error) {
+	var result models.Job
+	now := time.Now().UTC()
+	err := repo.db.QueryRow(ctx, takeJob, kind, now, now.Add(-staleAfter)).Scan(
+		&result.Id,
+		&result.Kind,
+		&result.Status,
+		&result.FeatureId,
+		&result.TagId,
+		&result.Actor,
+		&result.Total,
+		&result.Processed,
+		&result.Error,
+		&result.CreateTime,
+		&result.UpdateTime,
+	)
+	if err != nil {
+		return models.Job{}, err
+	}
+	return result, nil
+}
+
+func (repo *JobRepo) UpdateJob(ctx context.Context, job models.Job) error {
+	_, err := repo.db.Exec(ctx, updateJob, job.Status, job.Total, job.Processed, job.Error, time.Now().UTC(), job.Id)
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Alladan04/avito_test/internal/models"
+	"github.com/Alladan04/avito_test/internal/pkg/job"
+	"github.com/jackc/pgx/v4"
+)
+
+type JobUsecase struct {
+	repo job.JobRepo
+}
+
+func NewJobUsecase(repo job.JobRepo) *JobUsecase {
+	return &JobUsecase{
+		repo: repo,
+	}
+}
+
+func (uc *JobUsecase) GetJob(ctx context.Context, id int64) (models.Job, error) {
+	result, err := uc.repo.GetJob(ctx, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Job{}, job.ErrJobNotFound
+		}
+		return models.Job{}, err
+	}
+	return result, nil
+}
@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"context"
+
+	"github.com/Alladan04/avito_test/internal/models"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Access is the permission a gRPC method requires
+type Access int
+
+const (
+	// AccessUser needs any valid token, methods missing from the access map get it
+	AccessUser Access = iota
+	AccessAdmin
+	AccessPublic
+)
+
+// JwtInterceptor does for gRPC what JwtMiddleware and CheckAdminPermissionMiddleware do for HTTP:
+// it reads "Bearer <token>" from the token metadata and puts the payload into the context.
+// access maps full method names to their permission
+func JwtInterceptor(access map[string]Access) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		required := access[info.FullMethod]
+		if required == AccessPublic {
+			return handler(ctx, req)
+		}
+		md, _ := metadata.FromIncomingContext(ctx)
+		values := md.Get("token")
+		if len(values) != 1 {
+			return nil, status.Error(codes.Unauthenticated, "token is required")
+		}
+		payload, err := parseTokenHeader(values[0])
+		if err != nil {
+			return nil, status.Error(codes.Unauthenticated, "invalid token")
+		}
+		if required == AccessAdmin && !payload.IsAdmin {
+			return nil, status.Error(codes.PermissionDenied, "for admins only")
+		}
+		return handler(context.WithValue(ctx, models.PayloadContextKey, payload), req)
+	}
+}
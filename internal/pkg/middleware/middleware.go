@@ -57,22 +57,19 @@ func ParseTokenPayload(token string) (models.JwtPayload, error) {
 
 }
 
+// parseTokenHeader checks the "Bearer <token>" value of the token header or gRPC metadata
+func parseTokenHeader(header string) (models.JwtPayload, error) {
+	headerParts := strings.Split(header, " ")
+	if len(headerParts) != 2 || headerParts[0] != "Bearer" {
+		return models.JwtPayload{}, errors.New("invalid format (token header)")
+	}
+	return ParseTokenPayload(headerParts[1])
+}
+
 func JwtMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
-		header := r.Header.Get("token")
-		if header == "" {
-			w.WriteHeader(http.StatusUnauthorized)
-			return
-		}
-		headerParts := strings.Split(header, " ")
-		if len(headerParts) != 2 || headerParts[0] != "Bearer" {
-			w.WriteHeader(http.StatusUnauthorized)
-			return
-		}
-		token := headerParts[1]
-
-		payload, err := ParseTokenPayload(token)
+		payload, err := parseTokenHeader(r.Header.Get("token"))
 		if err != nil {
 			w.WriteHeader(http.StatusUnauthorized)
 			return
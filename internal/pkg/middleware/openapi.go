@@ -0,0 +1,382 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+const (
+	openAPIResource    = "openapi.json"
+	jsonContentType    = "application/json"
+	validationMessage  = "request does not match the API specification"
+	parameterRefPrefix = "#/components/parameters/"
+)
+
+var routeVariable = regexp.MustCompile(`\{([^{}:]+):[^{}]*\}`)
+
+// SpecPath turns a mux path template into the OpenAPI path, "/banner/{id:[0-9]+}" becomes "/banner/{id}"
+func SpecPath(template string) string {
+	return routeVariable.ReplaceAllString(template, "{$1}")
+}
+
+// FieldError is one mismatch between the request and the specification.
+// Name is the parameter name or the JSON pointer of the wrong body value
+type FieldError struct {
+	In      string `json:"in"`
+	Name    string `json:"name,omitempty"`
+	Message string `json:"message"`
+}
+
+type ValidationErrorResponse struct {
+	Message string       `json:"message"`
+	Errors  []FieldError `json:"errors"`
+}
+
+type specParameter struct {
+	Ref      string          `json:"$ref"`
+	Name     string          `json:"name"`
+	In       string          `json:"in"`
+	Required bool            `json:"required"`
+	Schema   json.RawMessage `json:"schema"`
+}
+
+type specDocument struct {
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components struct {
+		Parameters map[string]specParameter `json:"parameters"`
+	} `json:"components"`
+}
+
+type specOperation struct {
+	Parameters  []specParameter `json:"parameters"`
+	RequestBody *struct {
+		Required bool                       `json:"required"`
+		Content  map[string]json.RawMessage `json:"content"`
+	} `json:"requestBody"`
+}
+
+type parameter struct {
+	name     string
+	in       string
+	required bool
+	// type of the value, or of the items for array parameters
+	valueType string
+	array     bool
+	schema    *jsonschema.Schema
+}
+
+type operation struct {
+	parameters   []parameter
+	body         *jsonschema.Schema
+	bodyRequired bool
+}
+
+// OpenAPIValidator rejects requests whose query, path parameters or JSON body do not match the OpenAPI document.
+// Headers, including the token, are left to the other middlewares
+type OpenAPIValidator struct {
+	spec       []byte
+	operations map[string]operation
+}
+
+func NewOpenAPIValidator(spec []byte) (*OpenAPIValidator, error) {
+	var doc specDocument
+	if err := json.Unmarshal(spec, &doc); err != nil {
+		return nil, fmt.Errorf("error while parsing OpenAPI document:%w", err)
+	}
+	compiler := jsonschema.NewCompiler()
+	compiler.Draft = jsonschema.Draft2020
+	if err := compiler.AddResource(openAPIResource, bytes.NewReader(spec)); err != nil {
+		return nil, err
+	}
+	v := &OpenAPIValidator{
+		spec:       spec,
+		operations: make(map[string]operation),
+	}
+	for path, item := range doc.Paths {
+		var shared []specParameter
+		if raw, ok := item["parameters"]; ok {
+			if err := json.Unmarshal(raw, &shared); err != nil {
+				return nil, fmt.Errorf("error while parsing parameters of %s:%w", path, err)
+			}
+		}
+		pathPointer := "#/paths/" + escapePointer(path)
+		for method, raw := range item {
+			if method == "parameters" {
+				continue
+			}
+			var opSpec specOperation
+			if err := json.Unmarshal(raw, &opSpec); err != nil {
+				return nil, fmt.Errorf("error while parsing %s %s:%w", method, path, err)
+			}
+			pointer := pathPointer + "/" + method
+			op := operation{}
+			// параметры операции переопределяют общие параметры пути с тем же именем
+			params := make([]parameter, 0, len(shared)+len(opSpec.Parameters))
+			for i, sharedParam := range shared {
+				param, err := compileParameter(compiler, doc, sharedParam, fmt.Sprintf("%s/parameters/%d", pathPointer, i))
+				if err != nil {
+					return nil, fmt.Errorf("error in parameters of %s:%w", path, err)
+				}
+				params = append(params, param)
+			}
+			for i, opParam := range opSpec.Parameters {
+				param, err := compileParameter(compiler, doc, opParam, fmt.Sprintf("%s/parameters/%d", pointer, i))
+				if err != nil {
+					return nil, fmt.Errorf("error in parameters of %s %s:%w", method, path, err)
+				}
+				params = overrideParameter(params, param)
+			}
+			op.parameters = params
+			if opSpec.RequestBody != nil {
+				if _, ok := opSpec.RequestBody.Content[jsonContentType]; ok {
+					schema, err := compiler.Compile(openAPIResource + pointer + "/requestBody/content/" + escapePointer(jsonContentType) + "/schema")
+					if err != nil {
+						return nil, fmt.Errorf("error in body of %s %s:%w", method, path, err)
+					}
+					op.body = schema
+					op.bodyRequired = opSpec.RequestBody.Required
+				}
+			}
+			v.operations[strings.ToUpper(method)+" "+path] = op
+		}
+	}
+	return v, nil
+}
+
+func compileParameter(compiler *jsonschema.Compiler, doc specDocument, item specParameter, pointer string) (parameter, error) {
+	if item.Ref != "" {
+		name := strings.TrimPrefix(item.Ref, parameterRefPrefix)
+		resolved, ok := doc.Components.Parameters[name]
+		if !ok || name == item.Ref {
+			return parameter{}, fmt.Errorf("unknown parameter %s", item.Ref)
+		}
+		item = resolved
+		pointer = "#/components/parameters/" + escapePointer(name)
+	}
+	var schemaType struct {
+		Type  string `json:"type"`
+		Items struct {
+			Type string `json:"type"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(item.Schema, &schemaType); err != nil {
+		return parameter{}, fmt.Errorf("parameter %s:%w", item.Name, err)
+	}
+	schema, err := compiler.Compile(openAPIResource + pointer + "/schema")
+	if err != nil {
+		return parameter{}, err
+	}
+	param := parameter{
+		name:      item.Name,
+		in:        item.In,
+		required:  item.Required,
+		valueType: schemaType.Type,
+		schema:    schema,
+	}
+	if schemaType.Type == "array" {
+		param.array = true
+		param.valueType = schemaType.Items.Type
+	}
+	return param, nil
+}
+
+func overrideParameter(params []parameter, param parameter) []parameter {
+	for i := range params {
+		if params[i].name == param.name && params[i].in == param.in {
+			params[i] = param
+			return params
+		}
+	}
+	return append(params, param)
+}
+
+// escapePointer escapes a JSON pointer token and the characters that are not allowed in the url fragment
+func escapePointer(token string) string {
+	token = strings.ReplaceAll(token, "~", "~0")
+	token = strings.ReplaceAll(token, "/", "~1")
+	token = strings.ReplaceAll(token, "{", "%7B")
+	return strings.ReplaceAll(token, "}", "%7D")
+}
+
+// ServeSpec gives the OpenAPI document
+func (v *OpenAPIValidator) ServeSpec(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", jsonContentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(v.spec)))
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(v.spec)
+}
+
+// Middleware validates requests to the routes described by the document, it is meant for mux.Router.Use.
+// Routes missing from the document are passed through
+func (v *OpenAPIValidator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := mux.CurrentRoute(r)
+		if route == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		template, err := route.GetPathTemplate()
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		op, ok := v.operations[r.Method+" "+SpecPath(template)]
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		fieldErrors := op.validateParameters(r)
+		if op.body != nil {
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				writeValidationErrors(w, []FieldError{{In: "body", Message: "can`t read request body"}})
+				return
+			}
+			r.Body.Close()
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			fieldErrors = append(fieldErrors, op.validateBody(body)...)
+		}
+		if len(fieldErrors) > 0 {
+			writeValidationErrors(w, fieldErrors)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (op operation) validateParameters(r *http.Request) []FieldError {
+	var fieldErrors []FieldError
+	query := r.URL.Query()
+	vars := mux.Vars(r)
+	for _, param := range op.parameters {
+		var values []string
+		switch param.in {
+		case "query":
+			values = query[param.name]
+		case "path":
+			if value, ok := vars[param.name]; ok {
+				values = []string{value}
+			}
+		default:
+			continue
+		}
+		if len(values) == 0 {
+			if param.required {
+				fieldErrors = append(fieldErrors, FieldError{In: param.in, Name: param.name, Message: "parameter is required"})
+			}
+			continue
+		}
+		if !param.array {
+			values = values[:1]
+		}
+		items := make([]interface{}, 0, len(values))
+		for _, value := range values {
+			item, err := parseParameter(value, param.valueType)
+			if err != nil {
+				fieldErrors = append(fieldErrors, FieldError{In: param.in, Name: param.name, Message: err.Error()})
+				items = nil
+				break
+			}
+			items = append(items, item)
+		}
+		if items == nil {
+			continue
+		}
+		var instance interface{} = items[0]
+		if param.array {
+			instance = items
+		}
+		if err := param.schema.Validate(instance); err != nil {
+			for _, cause := range schemaErrors(err) {
+				fieldErrors = append(fieldErrors, FieldError{In: param.in, Name: param.name, Message: cause.Message})
+			}
+		}
+	}
+	return fieldErrors
+}
+
+// parseParameter converts the text value of a parameter to the JSON type its schema expects
+func parseParameter(value string, valueType string) (interface{}, error) {
+	switch valueType {
+	case "integer":
+		if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+			return nil, errors.New("must be an integer")
+		}
+		return json.Number(value), nil
+	case "number":
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return nil, errors.New("must be a number")
+		}
+		return json.Number(value), nil
+	case "boolean":
+		result, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, errors.New("must be a boolean")
+		}
+		return result, nil
+	}
+	return value, nil
+}
+
+func (op operation) validateBody(body []byte) []FieldError {
+	if len(bytes.TrimSpace(body)) == 0 {
+		if op.bodyRequired {
+			return []FieldError{{In: "body", Message: "request body is required"}}
+		}
+		return nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var instance interface{}
+	if err := decoder.Decode(&instance); err != nil {
+		return []FieldError{{In: "body", Message: "request body is not valid JSON"}}
+	}
+	err := op.body.Validate(instance)
+	if err == nil {
+		return nil
+	}
+	causes := schemaErrors(err)
+	fieldErrors := make([]FieldError, 0, len(causes))
+	for _, cause := range causes {
+		fieldErrors = append(fieldErrors, FieldError{In: "body", Name: cause.InstanceLocation, Message: cause.Message})
+	}
+	return fieldErrors
+}
+
+// schemaErrors returns the innermost causes of the validation error, they name the exact wrong values
+func schemaErrors(err error) []*jsonschema.ValidationError {
+	var validationError *jsonschema.ValidationError
+	if !errors.As(err, &validationError) {
+		return []*jsonschema.ValidationError{{Message: err.Error()}}
+	}
+	var result []*jsonschema.ValidationError
+	var walk func(*jsonschema.ValidationError)
+	walk = func(item *jsonschema.ValidationError) {
+		if len(item.Causes) == 0 {
+			result = append(result, item)
+			return
+		}
+		for _, cause := range item.Causes {
+			walk(cause)
+		}
+	}
+	walk(validationError)
+	return result
+}
+
+func writeValidationErrors(w http.ResponseWriter, fieldErrors []FieldError) {
+	body, _ := json.Marshal(ValidationErrorResponse{Message: validationMessage, Errors: fieldErrors})
+	w.Header().Set("Content-Type", jsonContentType)
+	w.WriteHeader(http.StatusBadRequest)
+	_, _ = w.Write(body)
+}
@@ -0,0 +1,94 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Alladan04/avito_test/internal/pkg/banner"
+	"github.com/Alladan04/avito_test/internal/pkg/stats"
+	"github.com/Alladan04/avito_test/internal/pkg/utils"
+	"github.com/gorilla/mux"
+)
+
+type StatsHandler struct {
+	uc stats.StatsUsecase
+}
+
+func NewStatsHandler(uc stats.StatsUsecase) *StatsHandler {
+	return &StatsHandler{
+		uc: uc,
+	}
+}
+
+// Redirect counts a click on the banner and redirects to the url from its content
+func (h *StatsHandler) Redirect(w http.ResponseWriter, r *http.Request) {
+	bannerIdString := mux.Vars(r)["banner_id"]
+	bannerId, err := strconv.ParseInt(bannerIdString, 10, 64)
+	if err != nil {
+		utils.WriteErrorMessage(w, http.StatusBadRequest, "cant parse id")
+		return
+	}
+
+	target, err := h.uc.Click(r.Context(), bannerId)
+	if err != nil {
+		if errors.Is(err, banner.ErrBannerNotFound) || errors.Is(err, stats.ErrNoUrl) {
+			utils.WriteErrorMessage(w, http.StatusNotFound, err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+// GetStats returns daily impressions, clicks and CTR of the banner, from and to are dates like 2024-04-30
+// for admins only
+func (h *StatsHandler) GetStats(w http.ResponseWriter, r *http.Request) {
+	bannerIdString := mux.Vars(r)["id"]
+	bannerId, err := strconv.ParseInt(bannerIdString, 10, 64)
+	if err != nil {
+		utils.WriteErrorMessage(w, http.StatusBadRequest, "cant parse id")
+		return
+	}
+	from, err := parseDateParam(r.URL.Query().Get("from"))
+	if err != nil {
+		utils.WriteErrorMessage(w, http.StatusBadRequest, "wrong from param")
+		return
+	}
+	to, err := parseDateParam(r.URL.Query().Get("to"))
+	if err != nil {
+		utils.WriteErrorMessage(w, http.StatusBadRequest, "wrong to param")
+		return
+	}
+
+	result, err := h.uc.GetStats(r.Context(), bannerId, from, to)
+	if err != nil {
+		if errors.Is(err, banner.ErrBannerNotFound) {
+			utils.WriteErrorMessage(w, http.StatusNotFound, err.Error())
+			return
+		}
+		if errors.Is(err, stats.ErrInvalidPeriod) {
+			utils.WriteErrorMessage(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	err = utils.WriteResponseData(w, result, http.StatusOK)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func parseDateParam(param string) (*time.Time, error) {
+	if param == "" {
+		return nil, nil
+	}
+	value, err := time.Parse(time.DateOnly, param)
+	if err != nil {
+		return nil, err
+	}
+	return &value, nil
+}
@@ -0,0 +1,38 @@
+package stats
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Alladan04/avito_test/internal/models"
+)
+
+var (
+	ErrNoUrl         = errors.New("banner has no url")
+	ErrInvalidPeriod = errors.New("invalid stats period")
+)
+
+// CounterRepo buffers impressions and clicks until the flush worker moves them to StatsRepo
+type CounterRepo interface {
+	AddImpression(ctx context.Context, bannerId int64, at time.Time) error
+	AddClick(ctx context.Context, bannerId int64, at time.Time) error
+	// TakeCounters moves buffered counters into a batch, which must then be acked or restored
+	TakeCounters(ctx context.Context) (string, []models.BannerStats, error)
+	AckCounters(ctx context.Context, batch string) error
+	RestoreCounters(ctx context.Context, batch string, counters []models.BannerStats) error
+	// RecoverCounters returns batches abandoned for longer than olderThan to the buffer
+	RecoverCounters(ctx context.Context, olderThan time.Duration) (int, error)
+}
+
+type StatsRepo interface {
+	AddStats(ctx context.Context, counters []models.BannerStats) error
+	GetStats(ctx context.Context, bannerId int64, from time.Time, to time.Time) ([]models.BannerStats, error)
+	GetBannerContent(ctx context.Context, bannerId int64) (models.BannerContent, error)
+	BannerExists(ctx context.Context, bannerId int64) (bool, error)
+}
+
+type StatsUsecase interface {
+	Click(ctx context.Context, bannerId int64) (string, error)
+	GetStats(ctx context.Context, bannerId int64, from *time.Time, to *time.Time) (models.BannerStatsReport, error)
+}
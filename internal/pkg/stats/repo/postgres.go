@@ -0,0 +1,91 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Alladan04/avito_test/internal/models"
+	"github.com/jackc/pgtype/pgxtype"
+)
+
+const (
+	// счётчики окончательно удалённых баннеров отбрасываем
+	addStats = `INSERT INTO banner_stats (banner_id, day, impressions, clicks)
+					SELECT s.banner_id, s.day, s.impressions, s.clicks
+					FROM unnest($1::bigint[], $2::date[], $3::bigint[], $4::bigint[]) AS s(banner_id, day, impressions, clicks)
+					WHERE EXISTS (SELECT 1 FROM banner b WHERE b.id = s.banner_id)
+					ON CONFLICT (banner_id, day) DO UPDATE
+					SET impressions = banner_stats.impressions + EXCLUDED.impressions,
+						clicks = banner_stats.clicks + EXCLUDED.clicks;`
+	getStats = `SELECT banner_id, day, impressions, clicks FROM banner_stats
+					WHERE banner_id=$1 AND day BETWEEN $2 AND $3
+					ORDER BY day;`
+	getBannerContent = `SELECT content FROM banner WHERE id=$1 AND deleted_at IS NULL;`
+	bannerExists     = `SELECT EXISTS (SELECT 1 FROM banner WHERE id=$1);`
+)
+
+type StatsRepo struct {
+	db pgxtype.Querier
+}
+
+func NewStatsRepo(db pgxtype.Querier) *StatsRepo {
+	return &StatsRepo{
+		db: db,
+	}
+}
+
+// AddStats adds the counters to the daily stats with a single query
+func (repo *StatsRepo) AddStats(ctx context.Context, counters []models.BannerStats) error {
+	if len(counters) == 0 {
+		return nil
+	}
+	bannerIds := make([]int64, 0, len(counters))
+	days := make([]time.Time, 0, len(counters))
+	impressions := make([]int64, 0, len(counters))
+	clicks := make([]int64, 0, len(counters))
+	for _, item := range counters {
+		bannerIds = append(bannerIds, item.BannerId)
+		days = append(days, item.Day)
+		impressions = append(impressions, item.Impressions)
+		clicks = append(clicks, item.Clicks)
+	}
+	_, err := repo.db.Exec(ctx, addStats, bannerIds, days, impressions, clicks)
+	return err
+}
+
+// GetStats returns daily stats of the banner between from and to inclusive, days without any counters are skipped
+func (repo *StatsRepo) GetStats(ctx context.Context, bannerId int64, from time.Time, to time.Time) ([]models.BannerStats, error) {
+	result := make([]models.BannerStats, 0)
+	rows, err := repo.db.Query(ctx, getStats, bannerId, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var item models.BannerStats
+		if err := rows.Scan(&item.BannerId, &item.Day, &item.Impressions, &item.Clicks); err != nil {
+			return nil, fmt.Errorf("error occured while scanning stats:%w", err)
+		}
+		result = append(result, item)
+	}
+	return result, rows.Err()
+}
+
+func (repo *StatsRepo) GetBannerContent(ctx context.Context, bannerId int64) (models.BannerContent, error) {
+	var result models.BannerContent
+	err := repo.db.QueryRow(ctx, getBannerContent, bannerId).Scan((*[]byte)(&result))
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (repo *StatsRepo) BannerExists(ctx context.Context, bannerId int64) (bool, error) {
+	var result bool
+	err := repo.db.QueryRow(ctx, bannerExists, bannerId).Scan(&result)
+	if err != nil {
+		return false, err
+	}
+	return result, nil
+}
@@ -0,0 +1,152 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Alladan04/avito_test/internal/models"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// pendingKey is a hash of counters waiting for the flush worker
+	pendingKey  = "stats:pending"
+	batchPrefix = "stats:flushing:"
+
+	impressionsField = "i"
+	clicksField      = "c"
+)
+
+// mergeScript adds counters of the batch to the pending hash and drops the batch in one step,
+// so a batch is never counted twice, even if recovery runs on several replicas at once
+var mergeScript = redis.NewScript(`
+local values = redis.call("HGETALL", KEYS[1])
+for i = 1, #values, 2 do
+	redis.call("HINCRBY", KEYS[2], values[i], values[i + 1])
+end
+return redis.call("DEL", KEYS[1])
+`)
+
+type CounterRepo struct {
+	db redis.Client
+}
+
+func NewCounterRepo(db redis.Client) *CounterRepo {
+	return &CounterRepo{
+		db: db,
+	}
+}
+
+// поле хеша - banner_id:дата:вид счётчика
+func counterField(bannerId int64, day time.Time, kind string) string {
+	return fmt.Sprintf("%d:%s:%s", bannerId, day.UTC().Format(time.DateOnly), kind)
+}
+
+func (repo *CounterRepo) AddImpression(ctx context.Context, bannerId int64, at time.Time) error {
+	return repo.db.HIncrBy(ctx, pendingKey, counterField(bannerId, at, impressionsField), 1).Err()
+}
+
+func (repo *CounterRepo) AddClick(ctx context.Context, bannerId int64, at time.Time) error {
+	return repo.db.HIncrBy(ctx, pendingKey, counterField(bannerId, at, clicksField), 1).Err()
+}
+
+// TakeCounters renames the pending hash into a batch, so new counters keep coming to an empty hash
+// while the batch is written. It returns an empty batch when there is nothing to flush
+func (repo *CounterRepo) TakeCounters(ctx context.Context) (string, []models.BannerStats, error) {
+	exists, err := repo.db.Exists(ctx, pendingKey).Result()
+	if err != nil || exists == 0 {
+		return "", nil, err
+	}
+	batch := batchPrefix + strconv.FormatInt(time.Now().UnixNano(), 10)
+	err = repo.db.Rename(ctx, pendingKey, batch).Err()
+	if err != nil {
+		return "", nil, err
+	}
+	values, err := repo.db.HGetAll(ctx, batch).Result()
+	if err != nil {
+		return "", nil, err
+	}
+	counters := make(map[string]*models.BannerStats, len(values))
+	for field, value := range values {
+		parts := strings.Split(field, ":")
+		if len(parts) != 3 {
+			continue
+		}
+		bannerId, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		day, err := time.Parse(time.DateOnly, parts[1])
+		if err != nil {
+			continue
+		}
+		count, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			continue
+		}
+		key := parts[0] + ":" + parts[1]
+		item, ok := counters[key]
+		if !ok {
+			item = &models.BannerStats{BannerId: bannerId, Day: day}
+			counters[key] = item
+		}
+		switch parts[2] {
+		case impressionsField:
+			item.Impressions += count
+		case clicksField:
+			item.Clicks += count
+		}
+	}
+	result := make([]models.BannerStats, 0, len(counters))
+	for _, item := range counters {
+		result = append(result, *item)
+	}
+	return batch, result, nil
+}
+
+// AckCounters drops the batch once it is saved
+func (repo *CounterRepo) AckCounters(ctx context.Context, batch string) error {
+	return repo.db.Del(ctx, batch).Err()
+}
+
+// RestoreCounters adds counters of the batch back to the pending hash, so they are flushed next time
+func (repo *CounterRepo) RestoreCounters(ctx context.Context, batch string, counters []models.BannerStats) error {
+	pipe := repo.db.TxPipeline()
+	for _, item := range counters {
+		if item.Impressions != 0 {
+			pipe.HIncrBy(ctx, pendingKey, counterField(item.BannerId, item.Day, impressionsField), item.Impressions)
+		}
+		if item.Clicks != 0 {
+			pipe.HIncrBy(ctx, pendingKey, counterField(item.BannerId, item.Day, clicksField), item.Clicks)
+		}
+	}
+	pipe.Del(ctx, batch)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// RecoverCounters merges batches left behind by a worker that stopped between taking and acking them
+// back into the pending hash. Batches taken less than olderThan ago may still be flushed and are kept.
+// It returns the number of merged batches
+func (repo *CounterRepo) RecoverCounters(ctx context.Context, olderThan time.Duration) (int, error) {
+	deadline := time.Now().Add(-olderThan).UnixNano()
+	var recovered int
+	iter := repo.db.Scan(ctx, 0, batchPrefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		batch := iter.Val()
+		// имя пачки - момент, когда её забрали
+		taken, err := strconv.ParseInt(strings.TrimPrefix(batch, batchPrefix), 10, 64)
+		if err != nil || taken > deadline {
+			continue
+		}
+		merged, err := mergeScript.Run(ctx, &repo.db, []string{batch, pendingKey}).Int()
+		if err != nil {
+			return recovered, err
+		}
+		recovered += merged
+	}
+	return recovered, iter.Err()
+}
@@ -0,0 +1,110 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/Alladan04/avito_test/internal/models"
+	"github.com/Alladan04/avito_test/internal/pkg/banner"
+	"github.com/Alladan04/avito_test/internal/pkg/stats"
+	"github.com/jackc/pgx/v4"
+)
+
+const (
+	// defaultPeriodDays is the stats period when from is not given
+	defaultPeriodDays = 30
+	maxPeriodDays     = 366
+)
+
+type StatsUsecase struct {
+	repo     stats.StatsRepo
+	counters stats.CounterRepo
+}
+
+func NewStatsUsecase(repo stats.StatsRepo, counters stats.CounterRepo) *StatsUsecase {
+	return &StatsUsecase{
+		repo:     repo,
+		counters: counters,
+	}
+}
+
+// Click counts a click on the banner and returns the url from its content
+func (uc *StatsUsecase) Click(ctx context.Context, bannerId int64) (string, error) {
+	content, err := uc.repo.GetBannerContent(ctx, bannerId)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", banner.ErrBannerNotFound
+		}
+		return "", err
+	}
+	target := content.Url()
+	//редиректим только на абсолютные http(s) ссылки
+	parsed, err := url.Parse(target)
+	if target == "" || err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return "", stats.ErrNoUrl
+	}
+	err = uc.counters.AddClick(ctx, bannerId, time.Now().UTC())
+	if err != nil {
+		fmt.Printf("error while counting click:%s\n", err.Error())
+	}
+	return target, nil
+}
+
+// GetStats returns daily impressions, clicks and CTR of the banner between from and to inclusive.
+// The period ends today and lasts 30 days unless given, days without impressions are reported with zeros
+func (uc *StatsUsecase) GetStats(ctx context.Context, bannerId int64, from *time.Time, to *time.Time) (models.BannerStatsReport, error) {
+	end := truncateDay(time.Now().UTC())
+	if to != nil {
+		end = truncateDay(*to)
+	}
+	start := end.AddDate(0, 0, 1-defaultPeriodDays)
+	if from != nil {
+		start = truncateDay(*from)
+	}
+	if end.Before(start) || end.Sub(start) >= maxPeriodDays*24*time.Hour {
+		return models.BannerStatsReport{}, stats.ErrInvalidPeriod
+	}
+	exists, err := uc.repo.BannerExists(ctx, bannerId)
+	if err != nil {
+		return models.BannerStatsReport{}, err
+	}
+	if !exists {
+		return models.BannerStatsReport{}, banner.ErrBannerNotFound
+	}
+	counters, err := uc.repo.GetStats(ctx, bannerId, start, end)
+	if err != nil {
+		return models.BannerStatsReport{}, err
+	}
+	byDay := make(map[string]models.BannerStats, len(counters))
+	for _, item := range counters {
+		byDay[item.Day.Format(time.DateOnly)] = item
+	}
+
+	result := models.BannerStatsReport{
+		BannerId: bannerId,
+		From:     start.Format(time.DateOnly),
+		To:       end.Format(time.DateOnly),
+		Days:     make([]models.BannerDayStats, 0),
+	}
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		key := day.Format(time.DateOnly)
+		item := byDay[key]
+		result.Days = append(result.Days, models.BannerDayStats{
+			Day:         key,
+			Impressions: item.Impressions,
+			Clicks:      item.Clicks,
+			CTR:         models.CTR(item.Impressions, item.Clicks),
+		})
+		result.Impressions += item.Impressions
+		result.Clicks += item.Clicks
+	}
+	result.CTR = models.CTR(result.Impressions, result.Clicks)
+	return result, nil
+}
+
+func truncateDay(value time.Time) time.Time {
+	return time.Date(value.Year(), value.Month(), value.Day(), 0, 0, 0, 0, time.UTC)
+}
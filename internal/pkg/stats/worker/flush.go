@@ -0,0 +1,73 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Alladan04/avito_test/internal/pkg/stats"
+)
+
+const (
+	flushInterval = time.Minute
+	// пачка, которую не сбросили за это время, брошена упавшей репликой
+	staleBatchAfter = 5 * time.Minute
+)
+
+// FlushWorker moves impressions and clicks buffered in redis to the daily stats in postgres
+type FlushWorker struct {
+	counters stats.CounterRepo
+	repo     stats.StatsRepo
+}
+
+func NewFlushWorker(counters stats.CounterRepo, repo stats.StatsRepo) *FlushWorker {
+	return &FlushWorker{
+		counters: counters,
+		repo:     repo,
+	}
+}
+
+// Run flushes the counters until ctx is cancelled. Batches abandoned by workers
+// that crashed in the middle of a flush are returned to the buffer first
+func (w *FlushWorker) Run(ctx context.Context) {
+	recovered, err := w.counters.RecoverCounters(ctx, staleBatchAfter)
+	if err != nil {
+		fmt.Printf("error while recovering stats counters:%s\n", err.Error())
+	} else if recovered > 0 {
+		fmt.Printf("recovered %d abandoned stats batches\n", recovered)
+	}
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.flush(ctx)
+		}
+	}
+}
+
+func (w *FlushWorker) flush(ctx context.Context) {
+	batch, counters, err := w.counters.TakeCounters(ctx)
+	if err != nil {
+		fmt.Printf("error while taking stats counters:%s\n", err.Error())
+		return
+	}
+	if batch == "" {
+		return
+	}
+	err = w.repo.AddStats(ctx, counters)
+	if err != nil {
+		fmt.Printf("error while saving stats:%s\n", err.Error())
+		//возвращаем счётчики в буфер, чтобы не потерять их
+		if err := w.counters.RestoreCounters(context.Background(), batch, counters); err != nil {
+			fmt.Printf("error while restoring stats counters:%s\n", err.Error())
+		}
+		return
+	}
+	err = w.counters.AckCounters(ctx, batch)
+	if err != nil {
+		fmt.Printf("error while dropping flushed stats counters:%s\n", err.Error())
+	}
+}
@@ -0,0 +1,144 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Alladan04/avito_test/internal/models"
+	"github.com/Alladan04/avito_test/internal/pkg/tag"
+	"github.com/Alladan04/avito_test/internal/pkg/utils"
+	"github.com/gorilla/mux"
+)
+
+type TagHandler struct {
+	uc tag.TagUsecase
+}
+
+func NewTagHandler(uc tag.TagUsecase) *TagHandler {
+	return &TagHandler{
+		uc: uc,
+	}
+}
+
+// AddTag creates a new tag
+// for admins only
+func (h *TagHandler) AddTag(w http.ResponseWriter, r *http.Request) {
+	item := models.TagForm{}
+	err := utils.GetRequestData(r, &item)
+	if err != nil {
+		utils.WriteErrorMessage(w, http.StatusBadRequest, "error unmarshalling")
+		return
+	}
+	if err := item.Validate(); err != nil {
+		utils.WriteErrorMessage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	result, err := h.uc.AddTag(r.Context(), item)
+	if err != nil {
+		utils.WriteErrorMessage(w, http.StatusBadRequest, "error Adding data")
+		return
+	}
+	err = utils.WriteResponseData(w, result, http.StatusCreated)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// GetTags returns tags, query param search filters them by name
+// for admins only
+func (h *TagHandler) GetTags(w http.ResponseWriter, r *http.Request) {
+	countParam := r.URL.Query().Get("limit")
+	offsetParam := r.URL.Query().Get("offset")
+	search := r.URL.Query().Get("search")
+
+	count, err := strconv.ParseInt(countParam, 10, 64)
+	if err != nil && countParam != "" {
+		utils.WriteErrorMessage(w, http.StatusBadRequest, "wrong count param")
+		return
+	}
+	offset, err := strconv.ParseInt(offsetParam, 10, 64)
+	if err != nil && offsetParam != "" {
+		utils.WriteErrorMessage(w, http.StatusBadRequest, "wrong offset param")
+		return
+	}
+
+	result, err := h.uc.GetTags(r.Context(), count, offset, search)
+	if err != nil {
+		utils.WriteErrorMessage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	err = utils.WriteResponseData(w, result, http.StatusOK)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// UpdateTag renames a tag
+// for admins only
+func (h *TagHandler) UpdateTag(w http.ResponseWriter, r *http.Request) {
+	tagIdString := mux.Vars(r)["id"]
+	tagId, err := strconv.ParseInt(tagIdString, 10, 64)
+	if err != nil {
+		utils.WriteErrorMessage(w, http.StatusBadRequest, "cant parse id")
+		return
+	}
+	item := models.TagForm{}
+	err = utils.GetRequestData(r, &item)
+	if err != nil {
+		utils.WriteErrorMessage(w, http.StatusBadRequest, "error unmarshalling")
+		return
+	}
+	if err := item.Validate(); err != nil {
+		utils.WriteErrorMessage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	result, err := h.uc.UpdateTag(r.Context(), tagId, item)
+	if err != nil {
+		if errors.Is(err, tag.ErrTagNotFound) {
+			utils.WriteErrorMessage(w, http.StatusNotFound, err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	err = utils.WriteResponseData(w, result, http.StatusOK)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// DeleteTag deletes a tag, with cascade=true it is unlinked from its banners as well
+// for admins only
+func (h *TagHandler) DeleteTag(w http.ResponseWriter, r *http.Request) {
+	tagIdString := mux.Vars(r)["id"]
+	tagId, err := strconv.ParseInt(tagIdString, 10, 64)
+	if err != nil {
+		utils.WriteErrorMessage(w, http.StatusBadRequest, "cant parse id")
+		return
+	}
+	cascadeParam := r.URL.Query().Get("cascade")
+	cascade, err := strconv.ParseBool(cascadeParam)
+	if err != nil && cascadeParam != "" {
+		utils.WriteErrorMessage(w, http.StatusBadRequest, "wrong cascade param")
+		return
+	}
+
+	err = h.uc.DeleteTag(r.Context(), tagId, cascade)
+	if err != nil {
+		if errors.Is(err, tag.ErrTagNotFound) {
+			utils.WriteErrorMessage(w, http.StatusNotFound, err.Error())
+			return
+		}
+		if errors.Is(err, tag.ErrTagInUse) {
+			utils.WriteErrorMessage(w, http.StatusConflict, err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,27 @@
+package tag
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Alladan04/avito_test/internal/models"
+)
+
+var (
+	ErrTagNotFound = errors.New("tag not found")
+	ErrTagInUse    = errors.New("tag is used by banners, pass cascade=true to unlink it from them")
+)
+
+type TagRepo interface {
+	AddTag(ctx context.Context, name string, actor string) (models.Tag, error)
+	GetTags(ctx context.Context, count int64, offset int64, search string) ([]models.Tag, error)
+	UpdateTag(ctx context.Context, id int64, name string, actor string) (models.Tag, error)
+	DeleteTag(ctx context.Context, id int64, cascade bool, actor string) ([]models.FeatureTag, error)
+}
+
+type TagUsecase interface {
+	AddTag(ctx context.Context, data models.TagForm) (models.Tag, error)
+	GetTags(ctx context.Context, count int64, offset int64, search string) ([]models.Tag, error)
+	UpdateTag(ctx context.Context, id int64, data models.TagForm) (models.Tag, error)
+	DeleteTag(ctx context.Context, id int64, cascade bool) error
+}
@@ -0,0 +1,168 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Alladan04/avito_test/internal/models"
+	auditRepo "github.com/Alladan04/avito_test/internal/pkg/audit/repo"
+	"github.com/Alladan04/avito_test/internal/pkg/banner"
+	bannerRepo "github.com/Alladan04/avito_test/internal/pkg/banner/repo"
+	"github.com/Alladan04/avito_test/internal/pkg/tag"
+	"github.com/jackc/pgtype/pgxtype"
+	"github.com/jackc/pgx/v4"
+)
+
+const (
+	addTag  = `INSERT INTO tag (tag_data) VALUES ($1) RETURNING id, tag_data;`
+	getTags = `SELECT id, coalesce(tag_data, '') FROM tag
+					WHERE $1 = '' OR tag_data ILIKE '%' || $1 || '%'
+					ORDER BY id
+					LIMIT $2 OFFSET $3;`
+	updateTag = `UPDATE tag SET tag_data=$1 WHERE id=$2 RETURNING id, tag_data;`
+	deleteTag = `DELETE FROM tag WHERE id=$1;`
+	// блокируем тег, чтобы снимок для аудита совпадал с тем, что меняем
+	getTagForUpdate = `SELECT id, coalesce(tag_data, '') FROM tag WHERE id=$1 FOR UPDATE;`
+)
+
+type TagRepo struct {
+	db   pgxtype.Querier
+	conn pgx.Conn
+}
+
+func NewTagRepo(db pgxtype.Querier, conn pgx.Conn) *TagRepo {
+	return &TagRepo{
+		db:   db,
+		conn: conn,
+	}
+}
+
+func (repo *TagRepo) AddTag(ctx context.Context, name string, actor string) (models.Tag, error) {
+	tx, err := repo.conn.Begin(ctx)
+	if err != nil {
+		return models.Tag{}, err
+	}
+	defer func() {
+		if err = tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			fmt.Printf("ERROR: %v", err)
+		}
+	}()
+	var result models.Tag
+	err = tx.QueryRow(ctx, addTag, name).Scan(&result.Id, &result.Name)
+	if err != nil {
+		return models.Tag{}, err
+	}
+	err = addAuditEntry(ctx, tx, actor, models.AuditActionCreate, result.Id, nil, result)
+	if err != nil {
+		return models.Tag{}, err
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		return models.Tag{}, err
+	}
+	return result, nil
+}
+
+// GetTags returns a page of tags, search filters them by a case insensitive substring of the name
+func (repo *TagRepo) GetTags(ctx context.Context, count int64, offset int64, search string) ([]models.Tag, error) {
+	result := make([]models.Tag, 0, count)
+	rows, err := repo.db.Query(ctx, getTags, search, count, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var item models.Tag
+		if err := rows.Scan(&item.Id, &item.Name); err != nil {
+			return nil, fmt.Errorf("error occured while scanning tags:%w", err)
+		}
+		result = append(result, item)
+	}
+
+	return result, rows.Err()
+}
+
+func (repo *TagRepo) UpdateTag(ctx context.Context, id int64, name string, actor string) (models.Tag, error) {
+	tx, err := repo.conn.Begin(ctx)
+	if err != nil {
+		return models.Tag{}, err
+	}
+	defer func() {
+		if err = tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			fmt.Printf("ERROR: %v", err)
+		}
+	}()
+	var before, result models.Tag
+	err = tx.QueryRow(ctx, getTagForUpdate, id).Scan(&before.Id, &before.Name)
+	if err != nil {
+		return models.Tag{}, err
+	}
+	err = tx.QueryRow(ctx, updateTag, name, id).Scan(&result.Id, &result.Name)
+	if err != nil {
+		return models.Tag{}, err
+	}
+	err = addAuditEntry(ctx, tx, actor, models.AuditActionUpdate, id, before, result)
+	if err != nil {
+		return models.Tag{}, err
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		return models.Tag{}, err
+	}
+	return result, nil
+}
+
+// DeleteTag removes the tag, with cascade it is unlinked from banners first,
+// otherwise tag.ErrTagInUse is returned for a tag used by banners.
+// It returns the feature:tag pairs that were served by the unlinked banners
+func (repo *TagRepo) DeleteTag(ctx context.Context, id int64, cascade bool, actor string) ([]models.FeatureTag, error) {
+	tx, err := repo.conn.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err = tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			fmt.Printf("ERROR: %v", err)
+		}
+	}()
+	var before models.Tag
+	err = tx.QueryRow(ctx, getTagForUpdate, id).Scan(&before.Id, &before.Name)
+	if err != nil {
+		return nil, err
+	}
+	//пока тег заблокирован, к баннерам его не привяжут
+	keys, err := bannerRepo.UnlinkTagBanners(ctx, tx, id, cascade, actor)
+	if err != nil {
+		if errors.Is(err, banner.ErrUsedByBanners) {
+			return nil, tag.ErrTagInUse
+		}
+		return nil, err
+	}
+	_, err = tx.Exec(ctx, deleteTag, id)
+	if err != nil {
+		return nil, err
+	}
+	err = addAuditEntry(ctx, tx, actor, models.AuditActionDelete, id, before, nil)
+	if err != nil {
+		return nil, err
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func addAuditEntry(ctx context.Context, tx pgx.Tx, actor string, action string, id int64, before any, after any) error {
+	return auditRepo.AddEntry(ctx, tx, models.AuditEntry{
+		Actor:      actor,
+		Action:     action,
+		Entity:     models.AuditEntityTag,
+		EntityId:   id,
+		Before:     models.AuditSnapshot(before),
+		After:      models.AuditSnapshot(after),
+		CreateTime: time.Now().UTC(),
+	})
+}
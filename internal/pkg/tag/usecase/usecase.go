@@ -0,0 +1,69 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Alladan04/avito_test/internal/models"
+	"github.com/Alladan04/avito_test/internal/pkg/banner"
+	"github.com/Alladan04/avito_test/internal/pkg/tag"
+	"github.com/Alladan04/avito_test/internal/pkg/utils"
+	"github.com/jackc/pgx/v4"
+)
+
+const (
+	pageElementsCount = 10
+)
+
+type TagUsecase struct {
+	repo  tag.TagRepo
+	cache banner.CacheRepo
+}
+
+func NewTagUsecase(repo tag.TagRepo, cache banner.CacheRepo) *TagUsecase {
+	return &TagUsecase{
+		repo:  repo,
+		cache: cache,
+	}
+}
+
+func (uc *TagUsecase) AddTag(ctx context.Context, data models.TagForm) (models.Tag, error) {
+	return uc.repo.AddTag(ctx, data.Name, utils.UsernameFromContext(ctx))
+}
+
+func (uc *TagUsecase) GetTags(ctx context.Context, count int64, offset int64, search string) ([]models.Tag, error) {
+	if count == 0 {
+		count = pageElementsCount
+	}
+	return uc.repo.GetTags(ctx, count, offset, search)
+}
+
+func (uc *TagUsecase) UpdateTag(ctx context.Context, id int64, data models.TagForm) (models.Tag, error) {
+	result, err := uc.repo.UpdateTag(ctx, id, data.Name, utils.UsernameFromContext(ctx))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Tag{}, tag.ErrTagNotFound
+		}
+		return models.Tag{}, err
+	}
+	return result, nil
+}
+
+// DeleteTag refuses to delete a tag used by banners unless cascade is set,
+// in which case the tag is unlinked from the banners, the banners themselves are kept.
+// The check and the unlinking share one transaction
+func (uc *TagUsecase) DeleteTag(ctx context.Context, id int64, cascade bool) error {
+	keys, err := uc.repo.DeleteTag(ctx, id, cascade, utils.UsernameFromContext(ctx))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return tag.ErrTagNotFound
+		}
+		return err
+	}
+	err = uc.cache.DeleteBanners(ctx, keys)
+	if err != nil {
+		fmt.Printf("error while evicting unlinked banners:%s\n", err.Error())
+	}
+	return nil
+}
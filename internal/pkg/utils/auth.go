@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"os"
@@ -27,3 +28,18 @@ func GenToken(user models.User, lifeTime time.Duration) (string, error) {
 	}
 	return token.SignedString([]byte(os.Getenv("JWT_USER_SECRET")))
 }
+
+// IsAdminFromContext reports whether the request was authorized with an admin token
+func IsAdminFromContext(ctx context.Context) bool {
+	payload, ok := ctx.Value(models.PayloadContextKey).(models.JwtPayload)
+	return ok && payload.IsAdmin
+}
+
+// UsernameFromContext returns the name of the user authorized by middleware.JwtMiddleware or middleware.JwtInterceptor
+func UsernameFromContext(ctx context.Context) string {
+	payload, ok := ctx.Value(models.PayloadContextKey).(models.JwtPayload)
+	if !ok {
+		return ""
+	}
+	return payload.Username
+}
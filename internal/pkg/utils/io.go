@@ -48,6 +48,7 @@ func WriteResponseData(w http.ResponseWriter, responseData interface{}, successS
 }
 
 func WriteErrorMessage(w http.ResponseWriter, statusCode int, message string) {
+	body, _ := json.Marshal(ErrorResponse{Message: message})
 	w.WriteHeader(statusCode)
-	_, _ = fmt.Fprintf(w, `{"message":"%s"}`, message)
+	_, _ = w.Write(body)
 }
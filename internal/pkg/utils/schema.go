@@ -0,0 +1,28 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+const schemaResource = "schema.json"
+
+// CompileSchema compiles a JSON Schema document
+func CompileSchema(schema []byte) (*jsonschema.Schema, error) {
+	compiler := jsonschema.NewCompiler()
+	if err := compiler.AddResource(schemaResource, bytes.NewReader(schema)); err != nil {
+		return nil, err
+	}
+	return compiler.Compile(schemaResource)
+}
+
+// ValidateJSON checks the JSON document against the schema
+func ValidateJSON(schema *jsonschema.Schema, data []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	return schema.Validate(value)
+}
@@ -0,0 +1,110 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Alladan04/avito_test/internal/models"
+	"github.com/Alladan04/avito_test/internal/pkg/utils"
+	"github.com/Alladan04/avito_test/internal/pkg/webhook"
+	"github.com/gorilla/mux"
+)
+
+type WebhookHandler struct {
+	uc webhook.WebhookUsecase
+}
+
+func NewWebhookHandler(uc webhook.WebhookUsecase) *WebhookHandler {
+	return &WebhookHandler{
+		uc: uc,
+	}
+}
+
+// AddWebhook subscribes a url to banner events
+// for admins only
+func (h *WebhookHandler) AddWebhook(w http.ResponseWriter, r *http.Request) {
+	var form models.WebhookForm
+	err := utils.GetRequestData(r, &form)
+	if err != nil {
+		utils.WriteErrorMessage(w, http.StatusBadRequest, "error unmarshalling")
+		return
+	}
+
+	result, err := h.uc.AddWebhook(r.Context(), form)
+	if err != nil {
+		if errors.Is(err, webhook.ErrInvalidUrl) || errors.Is(err, webhook.ErrEmptySecret) || errors.Is(err, webhook.ErrInvalidEvents) {
+			utils.WriteErrorMessage(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	err = utils.WriteResponseData(w, result, http.StatusCreated)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// GetWebhooks lists webhooks without their secrets
+// for admins only
+func (h *WebhookHandler) GetWebhooks(w http.ResponseWriter, r *http.Request) {
+	result, err := h.uc.GetWebhooks(r.Context())
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	err = utils.WriteResponseData(w, result, http.StatusOK)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// DeleteWebhook removes the webhook and drops its undelivered events
+// for admins only
+func (h *WebhookHandler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	idString := mux.Vars(r)["id"]
+	id, err := strconv.ParseInt(idString, 10, 64)
+	if err != nil {
+		utils.WriteErrorMessage(w, http.StatusBadRequest, "cant parse id")
+		return
+	}
+
+	err = h.uc.DeleteWebhook(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, webhook.ErrWebhookNotFound) {
+			utils.WriteErrorMessage(w, http.StatusNotFound, err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetDeadLetters returns a page of events that could not be delivered, with the last error of each
+// for admins only
+func (h *WebhookHandler) GetDeadLetters(w http.ResponseWriter, r *http.Request) {
+	countParam := r.URL.Query().Get("limit")
+	offsetParam := r.URL.Query().Get("offset")
+	count, err := strconv.ParseInt(countParam, 10, 64)
+	if (err != nil && countParam != "") || count < 0 {
+		utils.WriteErrorMessage(w, http.StatusBadRequest, "wrong limit param")
+		return
+	}
+	offset, err := strconv.ParseInt(offsetParam, 10, 64)
+	if (err != nil && offsetParam != "") || offset < 0 {
+		utils.WriteErrorMessage(w, http.StatusBadRequest, "wrong offset param")
+		return
+	}
+
+	result, err := h.uc.GetDeadLetters(r.Context(), count, offset)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	err = utils.WriteResponseData(w, result, http.StatusOK)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
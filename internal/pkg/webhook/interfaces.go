@@ -0,0 +1,34 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Alladan04/avito_test/internal/models"
+)
+
+var (
+	ErrWebhookNotFound = errors.New("webhook not found")
+	ErrInvalidUrl      = errors.New("url must be an absolute http or https url")
+	ErrEmptySecret     = errors.New("secret must not be empty")
+	ErrInvalidEvents   = errors.New("events must be a non-empty list of banner.created, banner.updated, banner.deleted")
+)
+
+type WebhookRepo interface {
+	AddWebhook(ctx context.Context, item models.Webhook) (models.Webhook, error)
+	GetWebhooks(ctx context.Context) ([]models.Webhook, error)
+	DeleteWebhook(ctx context.Context, id int64) error
+	GetDeadLetters(ctx context.Context, count int64, offset int64) ([]models.WebhookDelivery, error)
+	// ClaimDeliveries hides due deliveries from other workers until lease ends
+	ClaimDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int64) ([]models.WebhookDelivery, error)
+	MarkDelivered(ctx context.Context, id int64) error
+	MarkFailed(ctx context.Context, id int64, lastError string, nextAttempt time.Time, dead bool) error
+}
+
+type WebhookUsecase interface {
+	AddWebhook(ctx context.Context, form models.WebhookForm) (models.Webhook, error)
+	GetWebhooks(ctx context.Context) ([]models.Webhook, error)
+	DeleteWebhook(ctx context.Context, id int64) error
+	GetDeadLetters(ctx context.Context, count int64, offset int64) ([]models.WebhookDelivery, error)
+}
@@ -0,0 +1,144 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Alladan04/avito_test/internal/models"
+	"github.com/Alladan04/avito_test/internal/pkg/webhook"
+	"github.com/jackc/pgtype/pgxtype"
+	"github.com/jackc/pgx/v4"
+)
+
+const (
+	addWebhook    = `INSERT INTO webhook (url, secret, events, create_time) VALUES ($1, $2, $3, $4) RETURNING id;`
+	getWebhooks   = `SELECT id, url, secret, events, create_time FROM webhook ORDER BY id;`
+	deleteWebhook = `DELETE FROM webhook WHERE id=$1;`
+	// событие ставится в очередь каждому вебхуку, подписанному на него
+	addEvent = `INSERT INTO webhook_outbox (webhook_id, event, payload, next_attempt, create_time)
+					SELECT id, $1, $2, $3, $3 FROM webhook WHERE $1 = ANY(events);`
+	deliveryColumns = `o.id, o.webhook_id, w.url, w.secret, o.event, o.payload, o.status, o.attempts, coalesce(o.last_error, ''), o.next_attempt, o.create_time`
+	// переносим next_attempt на конец аренды, чтобы другой воркер не взял те же события
+	claimDeliveries = `UPDATE webhook_outbox o SET next_attempt = $2
+					FROM webhook w
+					WHERE w.id = o.webhook_id AND o.id IN (
+						SELECT id FROM webhook_outbox
+						WHERE status = 'pending' AND next_attempt <= $1
+						ORDER BY next_attempt, id
+						LIMIT $3
+						FOR UPDATE SKIP LOCKED
+					)
+					RETURNING ` + deliveryColumns + `;`
+	markDelivered  = `DELETE FROM webhook_outbox WHERE id=$1;`
+	markFailed     = `UPDATE webhook_outbox SET attempts = attempts + 1, last_error = $2, next_attempt = $3, status = $4 WHERE id=$1;`
+	getDeadLetters = `SELECT ` + deliveryColumns + ` FROM webhook_outbox o
+					JOIN webhook w ON w.id = o.webhook_id
+					WHERE o.status = 'dead'
+					ORDER BY o.id DESC
+					LIMIT $1 OFFSET $2;`
+)
+
+type WebhookRepo struct {
+	db pgxtype.Querier
+}
+
+func NewWebhookRepo(db pgxtype.Querier) *WebhookRepo {
+	return &WebhookRepo{
+		db: db,
+	}
+}
+
+// AddEvent queues the banner change for every webhook subscribed to event.
+// Repos call it with their transaction, so the event is queued only together with the change it describes
+func AddEvent(ctx context.Context, db pgxtype.Querier, event string, bannerId int64, banner any, at time.Time) error {
+	payload, err := models.MarshalWebhookPayload(event, bannerId, banner, at)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(ctx, addEvent, event, payload, at)
+	return err
+}
+
+func (repo *WebhookRepo) AddWebhook(ctx context.Context, item models.Webhook) (models.Webhook, error) {
+	err := repo.db.QueryRow(ctx, addWebhook, item.Url, item.Secret, item.Events, item.CreateTime).Scan(&item.Id)
+	if err != nil {
+		return models.Webhook{}, err
+	}
+	return item, nil
+}
+
+func (repo *WebhookRepo) GetWebhooks(ctx context.Context) ([]models.Webhook, error) {
+	result := make([]models.Webhook, 0)
+	rows, err := repo.db.Query(ctx, getWebhooks)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var item models.Webhook
+		if err := rows.Scan(&item.Id, &item.Url, &item.Secret, &item.Events, &item.CreateTime); err != nil {
+			return nil, fmt.Errorf("error occured while scanning webhooks:%w", err)
+		}
+		result = append(result, item)
+	}
+	return result, rows.Err()
+}
+
+// DeleteWebhook removes the webhook together with its queued deliveries
+func (repo *WebhookRepo) DeleteWebhook(ctx context.Context, id int64) error {
+	tag, err := repo.db.Exec(ctx, deleteWebhook, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return webhook.ErrWebhookNotFound
+	}
+	return nil
+}
+
+// GetDeadLetters returns a page of deliveries that ran out of retries, newest first
+func (repo *WebhookRepo) GetDeadLetters(ctx context.Context, count int64, offset int64) ([]models.WebhookDelivery, error) {
+	rows, err := repo.db.Query(ctx, getDeadLetters, count, offset)
+	if err != nil {
+		return nil, err
+	}
+	return scanDeliveries(rows)
+}
+
+func (repo *WebhookRepo) ClaimDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int64) ([]models.WebhookDelivery, error) {
+	rows, err := repo.db.Query(ctx, claimDeliveries, now, now.Add(lease), limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanDeliveries(rows)
+}
+
+// MarkDelivered removes the delivered event from the outbox
+func (repo *WebhookRepo) MarkDelivered(ctx context.Context, id int64) error {
+	_, err := repo.db.Exec(ctx, markDelivered, id)
+	return err
+}
+
+// MarkFailed schedules the next attempt, dead deliveries are not retried any more
+func (repo *WebhookRepo) MarkFailed(ctx context.Context, id int64, lastError string, nextAttempt time.Time, dead bool) error {
+	status := models.WebhookDeliveryPending
+	if dead {
+		status = models.WebhookDeliveryDead
+	}
+	_, err := repo.db.Exec(ctx, markFailed, id, lastError, nextAttempt, status)
+	return err
+}
+
+func scanDeliveries(rows pgx.Rows) ([]models.WebhookDelivery, error) {
+	defer rows.Close()
+	result := make([]models.WebhookDelivery, 0)
+	for rows.Next() {
+		var item models.WebhookDelivery
+		if err := rows.Scan(&item.Id, &item.WebhookId, &item.Url, &item.Secret, &item.Event, (*[]byte)(&item.Payload), &item.Status, &item.Attempts, &item.LastError, &item.NextAttempt, &item.CreateTime); err != nil {
+			return nil, fmt.Errorf("error occured while scanning deliveries:%w", err)
+		}
+		result = append(result, item)
+	}
+	return result, rows.Err()
+}
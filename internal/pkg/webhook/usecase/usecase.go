@@ -0,0 +1,69 @@
+package usecase
+
+import (
+	"context"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/Alladan04/avito_test/internal/models"
+	"github.com/Alladan04/avito_test/internal/pkg/webhook"
+)
+
+const (
+	pageElementsCount = 10
+)
+
+type WebhookUsecase struct {
+	repo webhook.WebhookRepo
+}
+
+func NewWebhookUsecase(repo webhook.WebhookRepo) *WebhookUsecase {
+	return &WebhookUsecase{
+		repo: repo,
+	}
+}
+
+// AddWebhook subscribes url to the chosen banner events
+func (uc *WebhookUsecase) AddWebhook(ctx context.Context, form models.WebhookForm) (models.Webhook, error) {
+	parsed, err := url.Parse(form.Url)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return models.Webhook{}, webhook.ErrInvalidUrl
+	}
+	if form.Secret == "" {
+		return models.Webhook{}, webhook.ErrEmptySecret
+	}
+	if len(form.Events) == 0 {
+		return models.Webhook{}, webhook.ErrInvalidEvents
+	}
+	events := make([]string, 0, len(form.Events))
+	for _, event := range form.Events {
+		if !slices.Contains(models.WebhookEvents, event) {
+			return models.Webhook{}, webhook.ErrInvalidEvents
+		}
+		if !slices.Contains(events, event) {
+			events = append(events, event)
+		}
+	}
+	return uc.repo.AddWebhook(ctx, models.Webhook{
+		Url:        form.Url,
+		Secret:     form.Secret,
+		Events:     events,
+		CreateTime: time.Now().UTC(),
+	})
+}
+
+func (uc *WebhookUsecase) GetWebhooks(ctx context.Context) ([]models.Webhook, error) {
+	return uc.repo.GetWebhooks(ctx)
+}
+
+func (uc *WebhookUsecase) DeleteWebhook(ctx context.Context, id int64) error {
+	return uc.repo.DeleteWebhook(ctx, id)
+}
+
+func (uc *WebhookUsecase) GetDeadLetters(ctx context.Context, count int64, offset int64) ([]models.WebhookDelivery, error) {
+	if count == 0 {
+		count = pageElementsCount
+	}
+	return uc.repo.GetDeadLetters(ctx, count, offset)
+}
@@ -0,0 +1,138 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Alladan04/avito_test/internal/models"
+	"github.com/Alladan04/avito_test/internal/pkg/webhook"
+)
+
+const (
+	deliveryPollInterval = 5 * time.Second
+	deliveryBatchSize    = 50
+	deliveryTimeout      = 10 * time.Second
+	// пока идёт доставка, событие скрыто от других воркеров
+	deliveryLease = time.Minute
+
+	// MaxAttempts is the number of failed deliveries after which the event goes to dead letters
+	MaxAttempts = 8
+	baseBackoff = 30 * time.Second
+	maxBackoff  = 6 * time.Hour
+
+	EventHeader     = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery"
+	SignatureHeader = "X-Webhook-Signature"
+)
+
+// DeliveryWorker POSTs events from the outbox to webhooks, retrying failed deliveries with exponential backoff
+type DeliveryWorker struct {
+	repo   webhook.WebhookRepo
+	client *http.Client
+}
+
+func NewDeliveryWorker(repo webhook.WebhookRepo, client *http.Client) *DeliveryWorker {
+	if client == nil {
+		client = &http.Client{Timeout: deliveryTimeout}
+	}
+	return &DeliveryWorker{
+		repo:   repo,
+		client: client,
+	}
+}
+
+// Run delivers events until ctx is cancelled
+func (w *DeliveryWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(deliveryPollInterval)
+	defer ticker.Stop()
+	for {
+		w.Deliver(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Deliver sends every due event once
+func (w *DeliveryWorker) Deliver(ctx context.Context) {
+	for ctx.Err() == nil {
+		items, err := w.repo.ClaimDeliveries(ctx, time.Now().UTC(), deliveryLease, deliveryBatchSize)
+		if err != nil {
+			fmt.Printf("error while claiming webhook deliveries:%s\n", err.Error())
+			return
+		}
+		for _, item := range items {
+			w.deliver(ctx, item)
+		}
+		if len(items) < deliveryBatchSize {
+			return
+		}
+	}
+}
+
+func (w *DeliveryWorker) deliver(ctx context.Context, item models.WebhookDelivery) {
+	err := w.send(ctx, item)
+	if err == nil {
+		err = w.repo.MarkDelivered(ctx, item.Id)
+		if err != nil {
+			fmt.Printf("error while marking webhook delivery %d:%s\n", item.Id, err.Error())
+		}
+		return
+	}
+	attempts := item.Attempts + 1
+	dead := attempts >= MaxAttempts
+	err = w.repo.MarkFailed(ctx, item.Id, err.Error(), time.Now().UTC().Add(Backoff(attempts)), dead)
+	if err != nil {
+		fmt.Printf("error while marking webhook delivery %d:%s\n", item.Id, err.Error())
+	}
+}
+
+func (w *DeliveryWorker) send(ctx context.Context, item models.WebhookDelivery) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, item.Url, bytes.NewReader(item.Payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, item.Event)
+	req.Header.Set(DeliveryHeader, strconv.FormatInt(item.Id, 10))
+	req.Header.Set(SignatureHeader, Signature(item.Secret, item.Payload))
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Signature is the X-Webhook-Signature value: hex encoded HMAC-SHA256 of the body keyed by the webhook secret
+func Signature(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Backoff returns the delay before the next attempt after the given number of failed ones
+func Backoff(attempts int64) time.Duration {
+	delay := baseBackoff
+	for i := int64(1); i < attempts; i++ {
+		delay *= 2
+		if delay >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	return delay
+}
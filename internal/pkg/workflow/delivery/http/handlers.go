@@ -0,0 +1,79 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Alladan04/avito_test/internal/pkg/banner"
+	"github.com/Alladan04/avito_test/internal/pkg/utils"
+	"github.com/Alladan04/avito_test/internal/pkg/workflow"
+	"github.com/gorilla/mux"
+)
+
+type WorkflowHandler struct {
+	uc workflow.WorkflowUsecase
+}
+
+func NewWorkflowHandler(uc workflow.WorkflowUsecase) *WorkflowHandler {
+	return &WorkflowHandler{
+		uc: uc,
+	}
+}
+
+// Apply moves a banner through its lifecycle: submit, approve, reject, publish or archive
+// for admins only
+func (h *WorkflowHandler) Apply(w http.ResponseWriter, r *http.Request) {
+	bannerIdString := mux.Vars(r)["id"]
+	bannerId, err := strconv.ParseInt(bannerIdString, 10, 64)
+	if err != nil {
+		utils.WriteErrorMessage(w, http.StatusBadRequest, "cant parse id")
+		return
+	}
+
+	result, err := h.uc.Apply(r.Context(), bannerId, mux.Vars(r)["action"])
+	if err != nil {
+		switch {
+		case errors.Is(err, banner.ErrBannerNotFound):
+			utils.WriteErrorMessage(w, http.StatusNotFound, err.Error())
+		case errors.Is(err, workflow.ErrUnknownAction):
+			utils.WriteErrorMessage(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, workflow.ErrSelfApproval):
+			utils.WriteErrorMessage(w, http.StatusForbidden, err.Error())
+		case errors.Is(err, workflow.ErrInvalidTransition), errors.Is(err, workflow.ErrStatusChanged):
+			utils.WriteErrorMessage(w, http.StatusConflict, err.Error())
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+	err = utils.WriteResponseData(w, result, http.StatusOK)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// GetTransitions returns the workflow history of a banner, oldest first
+// for admins only
+func (h *WorkflowHandler) GetTransitions(w http.ResponseWriter, r *http.Request) {
+	bannerIdString := mux.Vars(r)["id"]
+	bannerId, err := strconv.ParseInt(bannerIdString, 10, 64)
+	if err != nil {
+		utils.WriteErrorMessage(w, http.StatusBadRequest, "cant parse id")
+		return
+	}
+
+	result, err := h.uc.GetTransitions(r.Context(), bannerId)
+	if err != nil {
+		if errors.Is(err, banner.ErrBannerNotFound) {
+			utils.WriteErrorMessage(w, http.StatusNotFound, err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	err = utils.WriteResponseData(w, result, http.StatusOK)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,26 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Alladan04/avito_test/internal/models"
+)
+
+var (
+	ErrUnknownAction     = errors.New("unknown workflow action")
+	ErrInvalidTransition = errors.New("action is not allowed in the current banner status")
+	ErrSelfApproval      = errors.New("banner must be approved by another admin than the one who submitted it")
+	ErrStatusChanged     = errors.New("banner status was changed by someone else")
+)
+
+type WorkflowRepo interface {
+	GetStatus(ctx context.Context, bannerId int64) (string, error)
+	GetTransitions(ctx context.Context, bannerId int64) ([]models.BannerTransition, error)
+	AddTransition(ctx context.Context, transition models.BannerTransition) ([]models.FeatureTag, error)
+}
+
+type WorkflowUsecase interface {
+	Apply(ctx context.Context, bannerId int64, action string) (models.BannerTransition, error)
+	GetTransitions(ctx context.Context, bannerId int64) ([]models.BannerTransition, error)
+}
@@ -0,0 +1,142 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Alladan04/avito_test/internal/models"
+	auditRepo "github.com/Alladan04/avito_test/internal/pkg/audit/repo"
+	"github.com/Alladan04/avito_test/internal/pkg/banner"
+	bannerRepo "github.com/Alladan04/avito_test/internal/pkg/banner/repo"
+	"github.com/Alladan04/avito_test/internal/pkg/workflow"
+	"github.com/jackc/pgtype/pgxtype"
+	"github.com/jackc/pgx/v4"
+)
+
+const (
+	getStatus      = `SELECT status FROM banner WHERE id=$1 AND deleted_at IS NULL;`
+	getTransitions = `SELECT banner_id, action, from_status, to_status, actor, create_time
+					FROM banner_transition
+					WHERE banner_id=$1
+					ORDER BY id;`
+	// статус меняется, только если его никто не успел поменять после чтения
+	updateStatus = `UPDATE banner SET status=$1, update_time=$2, version=version+1
+					WHERE id=$3 AND status=$4 AND deleted_at IS NULL;`
+	// баннер без тегов отдаётся только как баннер фичи по умолчанию, ему достаётся пара с нулевым тегом (см. models.BannerPairs)
+	getBannerPairs = `SELECT b.feature_id, coalesce(bt.tag_id, 0) FROM banner b
+					LEFT JOIN banner_tag bt ON bt.banner_id = b.id
+					WHERE b.id=$1;`
+)
+
+type WorkflowRepo struct {
+	db   pgxtype.Querier
+	conn pgx.Conn
+}
+
+func NewWorkflowRepo(db pgxtype.Querier, conn pgx.Conn) *WorkflowRepo {
+	return &WorkflowRepo{
+		db:   db,
+		conn: conn,
+	}
+}
+
+func (repo *WorkflowRepo) GetStatus(ctx context.Context, bannerId int64) (string, error) {
+	var status string
+	err := repo.db.QueryRow(ctx, getStatus, bannerId).Scan(&status)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", banner.ErrBannerNotFound
+		}
+		return "", err
+	}
+	return status, nil
+}
+
+func (repo *WorkflowRepo) GetTransitions(ctx context.Context, bannerId int64) ([]models.BannerTransition, error) {
+	result := make([]models.BannerTransition, 0)
+	rows, err := repo.db.Query(ctx, getTransitions, bannerId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var item models.BannerTransition
+		if err := rows.Scan(&item.BannerId, &item.Action, &item.FromStatus, &item.ToStatus, &item.Actor, &item.CreateTime); err != nil {
+			return nil, fmt.Errorf("error occured while scanning transitions:%w", err)
+		}
+		result = append(result, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// AddTransition moves the banner to transition.ToStatus and records who did it.
+// Returns feature:tag pairs of the banner, so their cached banners can be dropped
+func (repo *WorkflowRepo) AddTransition(ctx context.Context, transition models.BannerTransition) ([]models.FeatureTag, error) {
+	tx, err := repo.conn.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err = tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			fmt.Printf("ERROR: %v", err)
+		}
+	}()
+	//меняем статус баннера
+	tag, err := tx.Exec(ctx, updateStatus, transition.ToStatus, transition.CreateTime, transition.BannerId, transition.FromStatus)
+	if err != nil {
+		return nil, err
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, workflow.ErrStatusChanged
+	}
+	//записываем переход в историю
+	err = bannerRepo.AddTransitionEntry(ctx, tx, transition)
+	if err != nil {
+		return nil, err
+	}
+	err = auditRepo.AddEntry(ctx, tx, models.AuditEntry{
+		Actor:      transition.Actor,
+		Action:     transition.Action,
+		Entity:     models.AuditEntityBanner,
+		EntityId:   transition.BannerId,
+		Before:     models.AuditSnapshot(map[string]string{"status": transition.FromStatus}),
+		After:      models.AuditSnapshot(map[string]string{"status": transition.ToStatus}),
+		CreateTime: transition.CreateTime,
+	})
+	if err != nil {
+		return nil, err
+	}
+	//подписчикам важны появление баннера в выдаче и уход из неё
+	if transition.FromStatus == models.BannerStatusPublished || transition.ToStatus == models.BannerStatusPublished {
+		err = bannerRepo.AddBannerEvent(ctx, tx, models.WebhookEventBannerUpdated, transition.BannerId, transition.CreateTime)
+		if err != nil {
+			return nil, err
+		}
+	}
+	rows, err := tx.Query(ctx, getBannerPairs, transition.BannerId)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]models.FeatureTag, 0)
+	for rows.Next() {
+		var key models.FeatureTag
+		if err := rows.Scan(&key.FeatureId, &key.TagId); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
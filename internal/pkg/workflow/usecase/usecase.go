@@ -0,0 +1,102 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Alladan04/avito_test/internal/models"
+	"github.com/Alladan04/avito_test/internal/pkg/banner"
+	"github.com/Alladan04/avito_test/internal/pkg/utils"
+	"github.com/Alladan04/avito_test/internal/pkg/workflow"
+)
+
+type transition struct {
+	from string
+	to   string
+}
+
+// transitions lists every action of the banner lifecycle:
+// draft -> in_review -> approved -> published -> archived, review can send the banner back to draft
+var transitions = map[string]transition{
+	models.WorkflowActionSubmit:  {from: models.BannerStatusDraft, to: models.BannerStatusInReview},
+	models.WorkflowActionApprove: {from: models.BannerStatusInReview, to: models.BannerStatusApproved},
+	models.WorkflowActionReject:  {from: models.BannerStatusInReview, to: models.BannerStatusDraft},
+	models.WorkflowActionPublish: {from: models.BannerStatusApproved, to: models.BannerStatusPublished},
+	models.WorkflowActionArchive: {from: models.BannerStatusPublished, to: models.BannerStatusArchived},
+}
+
+type WorkflowUsecase struct {
+	repo  workflow.WorkflowRepo
+	cache banner.CacheRepo
+}
+
+func NewWorkflowUsecase(repo workflow.WorkflowRepo, cache banner.CacheRepo) *WorkflowUsecase {
+	return &WorkflowUsecase{
+		repo:  repo,
+		cache: cache,
+	}
+}
+
+// Apply performs the workflow action on the banner on behalf of the admin from context.
+// Approval has to come from another admin than the one who submitted the banner for review
+func (uc *WorkflowUsecase) Apply(ctx context.Context, bannerId int64, action string) (models.BannerTransition, error) {
+	rule, ok := transitions[action]
+	if !ok {
+		return models.BannerTransition{}, workflow.ErrUnknownAction
+	}
+	status, err := uc.repo.GetStatus(ctx, bannerId)
+	if err != nil {
+		return models.BannerTransition{}, err
+	}
+	if status != rule.from {
+		return models.BannerTransition{}, fmt.Errorf("%w: cant %s %s banner", workflow.ErrInvalidTransition, action, status)
+	}
+	actor := utils.UsernameFromContext(ctx)
+	if action == models.WorkflowActionApprove {
+		history, err := uc.repo.GetTransitions(ctx, bannerId)
+		if err != nil {
+			return models.BannerTransition{}, err
+		}
+		if submittedBy(history) == actor {
+			return models.BannerTransition{}, workflow.ErrSelfApproval
+		}
+	}
+
+	result := models.BannerTransition{
+		BannerId:   bannerId,
+		Action:     action,
+		FromStatus: rule.from,
+		ToStatus:   rule.to,
+		Actor:      actor,
+		CreateTime: time.Now().UTC(),
+	}
+	keys, err := uc.repo.AddTransition(ctx, result)
+	if err != nil {
+		return models.BannerTransition{}, err
+	}
+	//баннер появился или пропал из выдачи
+	if rule.from == models.BannerStatusPublished || rule.to == models.BannerStatusPublished {
+		if err := uc.cache.DeleteBanners(ctx, keys); err != nil {
+			fmt.Printf("error while trying to evict cache:%s", err.Error())
+		}
+	}
+	return result, nil
+}
+
+func (uc *WorkflowUsecase) GetTransitions(ctx context.Context, bannerId int64) ([]models.BannerTransition, error) {
+	if _, err := uc.repo.GetStatus(ctx, bannerId); err != nil {
+		return nil, err
+	}
+	return uc.repo.GetTransitions(ctx, bannerId)
+}
+
+// submittedBy returns the admin who sent the banner to its latest review
+func submittedBy(history []models.BannerTransition) string {
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].ToStatus == models.BannerStatusInReview {
+			return history[i].Actor
+		}
+	}
+	return ""
+}
@@ -0,0 +1,130 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Alladan04/avito_test/internal/models"
+	"github.com/Alladan04/avito_test/internal/pkg/audit"
+	auditRepo "github.com/Alladan04/avito_test/internal/pkg/audit/repo"
+	auditUsecase "github.com/Alladan04/avito_test/internal/pkg/audit/usecase"
+	"github.com/stretchr/testify/suite"
+)
+
+// auditRepoStub pages through entries with ids 1..n like the database does, newest first
+type auditRepoStub struct {
+	entries []models.AuditEntry
+	limits  []int64
+}
+
+func (r *auditRepoStub) GetEntries(ctx context.Context, filter models.AuditFilter) ([]models.AuditEntry, error) {
+	r.limits = append(r.limits, filter.Limit)
+	result := make([]models.AuditEntry, 0, filter.Limit)
+	for i := len(r.entries) - 1; i >= 0 && int64(len(result)) < filter.Limit; i-- {
+		if filter.Cursor == 0 || r.entries[i].Id < filter.Cursor {
+			result = append(result, r.entries[i])
+		}
+	}
+	return result, nil
+}
+
+type AuditTestSuite struct {
+	suite.Suite
+
+	repo *auditRepoStub
+	uc   *auditUsecase.AuditUsecase
+}
+
+func TestAuditSuite(t *testing.T) {
+	suite.Run(t, new(AuditTestSuite))
+}
+
+func (s *AuditTestSuite) SetupTest() {
+	s.repo = &auditRepoStub{}
+	for id := int64(1); id <= 7; id++ {
+		s.repo.entries = append(s.repo.entries, models.AuditEntry{Id: id, Actor: "admin", Action: models.AuditActionUpdate})
+	}
+	s.uc = auditUsecase.NewAuditUsecase(s.repo)
+}
+
+func (s *AuditTestSuite) TestCursorWalksAllEntries() {
+	r := s.Require()
+	var ids []int64
+	filter := models.AuditFilter{Limit: 3}
+	for pages := 0; pages < 10; pages++ {
+		page, err := s.uc.GetEntries(context.Background(), filter)
+		r.NoError(err)
+		for _, entry := range page.Entries {
+			ids = append(ids, entry.Id)
+		}
+		if page.NextCursor == 0 {
+			break
+		}
+		filter.Cursor = page.NextCursor
+	}
+	r.Equal([]int64{7, 6, 5, 4, 3, 2, 1}, ids)
+}
+
+func (s *AuditTestSuite) TestLastPageHasNoCursor() {
+	r := s.Require()
+	page, err := s.uc.GetEntries(context.Background(), models.AuditFilter{Limit: 5, Cursor: 4})
+	r.NoError(err)
+	r.Len(page.Entries, 3)
+	r.Zero(page.NextCursor)
+}
+
+func (s *AuditTestSuite) TestLimitDefaults() {
+	r := s.Require()
+	_, err := s.uc.GetEntries(context.Background(), models.AuditFilter{})
+	r.NoError(err)
+	_, err = s.uc.GetEntries(context.Background(), models.AuditFilter{Limit: 10000})
+	r.NoError(err)
+	r.Equal([]int64{50, 500}, s.repo.limits)
+}
+
+func (s *AuditTestSuite) TestInvalidFilter() {
+	r := s.Require()
+	_, err := s.uc.GetEntries(context.Background(), models.AuditFilter{Cursor: -1})
+	r.ErrorIs(err, audit.ErrInvalidFilter)
+	now := time.Now()
+	_, err = s.uc.GetEntries(context.Background(), models.AuditFilter{From: &now, To: &now})
+	r.ErrorIs(err, audit.ErrInvalidFilter)
+	r.Empty(s.repo.limits)
+}
+
+func (s *APITestSuite) TestAuditCursorPagination() {
+	r := s.Require()
+	ctx := context.Background()
+	actor := fmt.Sprintf("pager%d", time.Now().UnixNano())
+	for i := int64(1); i <= 5; i++ {
+		r.NoError(auditRepo.AddEntry(ctx, s.db, models.AuditEntry{
+			Actor:      actor,
+			Action:     models.AuditActionCreate,
+			Entity:     models.AuditEntityBanner,
+			EntityId:   i,
+			CreateTime: time.Now().UTC(),
+		}))
+	}
+	uc := auditUsecase.NewAuditUsecase(auditRepo.NewAuditRepo(s.db))
+
+	first, err := uc.GetEntries(ctx, models.AuditFilter{Actor: actor, Limit: 2})
+	r.NoError(err)
+	r.Equal([]int64{5, 4}, auditEntityIds(first))
+	second, err := uc.GetEntries(ctx, models.AuditFilter{Actor: actor, Limit: 2, Cursor: first.NextCursor})
+	r.NoError(err)
+	r.Equal([]int64{3, 2}, auditEntityIds(second))
+	last, err := uc.GetEntries(ctx, models.AuditFilter{Actor: actor, Limit: 2, Cursor: second.NextCursor})
+	r.NoError(err)
+	r.Equal([]int64{1}, auditEntityIds(last))
+	r.Zero(last.NextCursor)
+}
+
+func auditEntityIds(page models.AuditPage) []int64 {
+	result := make([]int64, 0, len(page.Entries))
+	for _, entry := range page.Entries {
+		result = append(result, entry.EntityId)
+	}
+	return result
+}
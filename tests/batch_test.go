@@ -0,0 +1,94 @@
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Alladan04/avito_test/internal/models"
+	"github.com/Alladan04/avito_test/internal/pkg/banner"
+	bannerRepo "github.com/Alladan04/avito_test/internal/pkg/banner/repo"
+	bannerUsecase "github.com/Alladan04/avito_test/internal/pkg/banner/usecase"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/suite"
+)
+
+type BatchTestSuite struct {
+	suite.Suite
+
+	redis *miniredis.Miniredis
+	repo  *pairRepoStub
+	uc    *bannerUsecase.BannerUsecase
+}
+
+func TestBatchSuite(t *testing.T) {
+	suite.Run(t, new(BatchTestSuite))
+}
+
+func (s *BatchTestSuite) SetupTest() {
+	s.redis = miniredis.RunT(s.T())
+	client := redis.NewClient(&redis.Options{Addr: s.redis.Addr()})
+	s.repo = &pairRepoStub{
+		pairs: map[models.FeatureTag][]models.UserBanner{
+			{FeatureId: 1, TagId: 1}: {servedBanner(1, 0, 0)},
+			{FeatureId: 2, TagId: 1}: {servedBanner(3, 0, 0)},
+		},
+		fallbacks: map[int64]models.UserBanner{1: servedBanner(2, 0, 0)},
+	}
+	s.uc = bannerUsecase.NewBannerUsecase(s.repo, bannerRepo.NewCacheRepo(*client), nil, nil, &counterStub{}, nil)
+}
+
+func (s *BatchTestSuite) getMany(keys ...models.FeatureTag) map[string]models.UserBannerResult {
+	result, err := s.uc.GetMany(context.Background(), keys, false)
+	s.Require().NoError(err)
+	return result
+}
+
+func (s *BatchTestSuite) TestMissesAreLoadedInOneQuery() {
+	r := s.Require()
+	result := s.getMany(models.FeatureTag{FeatureId: 1, TagId: 1}, models.FeatureTag{FeatureId: 1, TagId: 2}, models.FeatureTag{FeatureId: 2, TagId: 2})
+
+	r.Equal(1, s.repo.batches)
+	r.True(result["1:1"].Found)
+	r.False(result["1:1"].Fallback)
+	r.True(result["1:2"].Found)
+	r.True(result["1:2"].Fallback)
+	r.False(result["2:2"].Found)
+}
+
+func (s *BatchTestSuite) TestCachedBatchIsOneRead() {
+	r := s.Require()
+	keys := []models.FeatureTag{{FeatureId: 1, TagId: 1}, {FeatureId: 1, TagId: 2}, {FeatureId: 2, TagId: 1}}
+	s.getMany(keys...)
+	batches := s.repo.batches
+	commands := s.redis.CommandCount()
+
+	// пары и баннер фичи по умолчанию приходят одним MGET
+	result := s.getMany(keys...)
+	r.Equal(batches, s.repo.batches)
+	r.Equal(commands+1, s.redis.CommandCount())
+	for _, key := range keys {
+		r.True(result[key.String()].Found)
+	}
+	r.True(result["1:2"].Fallback)
+}
+
+func (s *BatchTestSuite) TestDuplicatePairsAreServedOnce() {
+	r := s.Require()
+	result := s.getMany(models.FeatureTag{FeatureId: 1, TagId: 1}, models.FeatureTag{FeatureId: 1, TagId: 1})
+	r.Len(result, 1)
+	r.True(result["1:1"].Found)
+}
+
+func (s *BatchTestSuite) TestBatchLimits() {
+	r := s.Require()
+	_, err := s.uc.GetMany(context.Background(), nil, false)
+	r.ErrorIs(err, banner.ErrEmptyBatch)
+
+	keys := make([]models.FeatureTag, 0, 101)
+	for tagId := int64(1); tagId <= 101; tagId++ {
+		keys = append(keys, models.FeatureTag{FeatureId: 1, TagId: tagId})
+	}
+	_, err = s.uc.GetMany(context.Background(), keys, false)
+	r.ErrorIs(err, banner.ErrBatchTooLarge)
+}
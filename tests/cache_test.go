@@ -0,0 +1,321 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Alladan04/avito_test/internal/models"
+	"github.com/Alladan04/avito_test/internal/pkg/banner"
+	bannerRepo "github.com/Alladan04/avito_test/internal/pkg/banner/repo"
+	bannerUsecase "github.com/Alladan04/avito_test/internal/pkg/banner/usecase"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/jackc/pgx/v4"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/suite"
+)
+
+// bannerRepoStub keeps banners in memory and counts reads of served banners,
+// methods the cache tests do not need are left to the embedded interface
+type bannerRepoStub struct {
+	banner.BannerRepo
+	banners   map[int64]models.BannerForm
+	schemas   map[int64][]byte
+	revisions map[int64][]models.BannerRevision
+	// баннеры фич по умолчанию: фича -> id баннера
+	defaults map[int64]int64
+	reads    int
+}
+
+func (r *bannerRepoStub) fallback(featureId int64, withInactive bool) (models.UserBanner, bool) {
+	id, ok := r.defaults[featureId]
+	if !ok {
+		return models.UserBanner{}, false
+	}
+	item, ok := r.banners[id]
+	if !ok || !item.IsActive && !withInactive {
+		return models.UserBanner{}, false
+	}
+	return models.UserBanner{Id: id, Content: item.Content, Hash: item.Content.Hash(), IsActive: item.IsActive, Fallback: true}, true
+}
+
+func (r *bannerRepoStub) GetOne(ctx context.Context, featureId int64, tagId int64, withInactive bool) ([]models.UserBanner, error) {
+	r.reads++
+	var result []models.UserBanner
+	for id, item := range r.banners {
+		if item.FeatureId != featureId || !item.IsActive && !withInactive {
+			continue
+		}
+		for _, itemTagId := range item.TagIds {
+			if itemTagId == tagId {
+				result = append(result, models.UserBanner{Id: id, Content: item.Content, Hash: item.Content.Hash(), IsActive: item.IsActive})
+			}
+		}
+	}
+	if len(result) == 0 {
+		if item, ok := r.fallback(featureId, withInactive); ok {
+			return []models.UserBanner{item}, nil
+		}
+		return nil, pgx.ErrNoRows
+	}
+	return result, nil
+}
+
+func (r *bannerRepoStub) GetFallback(ctx context.Context, featureId int64, withInactive bool) (models.UserBanner, error) {
+	if item, ok := r.fallback(featureId, withInactive); ok {
+		return item, nil
+	}
+	return models.UserBanner{}, pgx.ErrNoRows
+}
+
+func (r *bannerRepoStub) GetById(ctx context.Context, id int64) (models.BannerForm, error) {
+	item, ok := r.banners[id]
+	if !ok {
+		return models.BannerForm{}, pgx.ErrNoRows
+	}
+	return item, nil
+}
+
+func (r *bannerRepoStub) GetRevision(ctx context.Context, id int64, revision int64) (models.BannerRevision, error) {
+	for _, item := range r.revisions[id] {
+		if item.Revision == revision {
+			return item, nil
+		}
+	}
+	return models.BannerRevision{}, pgx.ErrNoRows
+}
+
+func (r *bannerRepoStub) GetContentSchema(ctx context.Context, featureId int64) ([]byte, error) {
+	return r.schemas[featureId], nil
+}
+
+func (r *bannerRepoStub) UpdateBanner(ctx context.Context, item models.BannerForm, id int64, versions []int64, author string) (int64, error) {
+	if _, ok := r.banners[id]; !ok {
+		return 0, banner.ErrBannerNotFound
+	}
+	r.banners[id] = item
+	return 2, nil
+}
+
+func (r *bannerRepoStub) AddItem(ctx context.Context, item models.Banner, author string) (int64, error) {
+	id := int64(len(r.banners) + 1)
+	r.banners[id] = models.BannerForm{Content: item.Content, FeatureId: item.FeatureId, TagIds: item.TagIds, IsActive: item.IsActive}
+	return id, nil
+}
+
+// AddItems fails the rows of feature 0, as the database does for a missing feature
+func (r *bannerRepoStub) AddItems(ctx context.Context, items []models.Banner, author string, commit bool) ([]error, error) {
+	rowErrors := make([]error, len(items))
+	for i, item := range items {
+		if item.FeatureId == 0 {
+			rowErrors[i] = errors.New("feature not found")
+		}
+	}
+	if !commit {
+		return rowErrors, nil
+	}
+	for _, item := range items {
+		r.AddItem(ctx, item, author) //nolint:errcheck
+	}
+	return rowErrors, nil
+}
+
+func (r *bannerRepoStub) DeleteBanner(ctx context.Context, id int64, versions []int64, author string) error {
+	if _, ok := r.banners[id]; !ok {
+		return banner.ErrBannerNotFound
+	}
+	delete(r.banners, id)
+	return nil
+}
+
+type CacheTestSuite struct {
+	suite.Suite
+
+	redis *miniredis.Miniredis
+	repo  *bannerRepoStub
+	cache *bannerRepo.CacheRepo
+	uc    *bannerUsecase.BannerUsecase
+}
+
+func TestCacheSuite(t *testing.T) {
+	suite.Run(t, new(CacheTestSuite))
+}
+
+func (s *CacheTestSuite) SetupTest() {
+	s.redis = miniredis.RunT(s.T())
+	client := redis.NewClient(&redis.Options{Addr: s.redis.Addr()})
+	s.repo = &bannerRepoStub{banners: map[int64]models.BannerForm{
+		1: {Content: models.BannerContent(`{"title": "old"}`), FeatureId: 1, TagIds: []int64{1, 2}, IsActive: true},
+	}}
+	s.cache = bannerRepo.NewCacheRepo(*client)
+	s.uc = bannerUsecase.NewBannerUsecase(s.repo, s.cache, nil, nil, &counterStub{}, nil)
+}
+
+// cached reads the pair twice and checks that the second read is served by the cache
+func (s *CacheTestSuite) cached(featureId int64, tagId int64) models.UserBanner {
+	r := s.Require()
+	_, err := s.uc.GetOne(context.Background(), featureId, tagId, false)
+	r.NoError(err)
+	reads := s.repo.reads
+	result, err := s.uc.GetOne(context.Background(), featureId, tagId, false)
+	r.NoError(err)
+	r.Equal(reads, s.repo.reads)
+	return result
+}
+
+func (s *CacheTestSuite) TestUpdateEvictsContent() {
+	r := s.Require()
+	r.JSONEq(`{"title": "old"}`, string(s.cached(1, 1).Content))
+	r.JSONEq(`{"title": "old"}`, string(s.cached(1, 2).Content))
+
+	content := models.BannerContent(`{"title": "new"}`)
+	_, err := s.uc.UpdateBanner(context.Background(), models.BannerUpdateForm{Content: &content}, 1, nil)
+	r.NoError(err)
+
+	for _, tagId := range []int64{1, 2} {
+		result, err := s.uc.GetOne(context.Background(), 1, tagId, false)
+		r.NoError(err)
+		r.JSONEq(`{"title": "new"}`, string(result.Content))
+	}
+}
+
+func (s *CacheTestSuite) TestUpdateEvictsOldPairs() {
+	r := s.Require()
+	s.cached(1, 1)
+
+	featureId := int64(2)
+	_, err := s.uc.UpdateBanner(context.Background(), models.BannerUpdateForm{FeatureId: &featureId, TagIds: []int64{3}}, 1, nil)
+	r.NoError(err)
+
+	_, err = s.uc.GetOne(context.Background(), 1, 1, false)
+	r.ErrorIs(err, pgx.ErrNoRows)
+	result, err := s.uc.GetOne(context.Background(), 2, 3, false)
+	r.NoError(err)
+	r.Equal(int64(1), result.Id)
+}
+
+func (s *CacheTestSuite) TestDeactivationEvictsUserView() {
+	r := s.Require()
+	s.cached(1, 1)
+
+	active := false
+	_, err := s.uc.UpdateBanner(context.Background(), models.BannerUpdateForm{IsActive: &active}, 1, nil)
+	r.NoError(err)
+
+	_, err = s.uc.GetOne(context.Background(), 1, 1, false)
+	r.ErrorIs(err, pgx.ErrNoRows)
+}
+
+func (s *CacheTestSuite) TestDeleteEvictsBanner() {
+	r := s.Require()
+	s.cached(1, 2)
+
+	r.NoError(s.uc.DeleteBanner(context.Background(), 1, nil))
+
+	_, err := s.uc.GetOne(context.Background(), 1, 2, false)
+	r.ErrorIs(err, pgx.ErrNoRows)
+}
+
+func (s *CacheTestSuite) TestFailedUpdateKeepsCache() {
+	r := s.Require()
+	s.cached(1, 1)
+	reads := s.repo.reads
+
+	content := models.BannerContent(`"not an object"`)
+	_, err := s.uc.UpdateBanner(context.Background(), models.BannerUpdateForm{Content: &content}, 1, nil)
+	r.ErrorIs(err, banner.ErrInvalidContent)
+
+	_, err = s.uc.GetOne(context.Background(), 1, 1, false)
+	r.NoError(err)
+	r.Equal(reads, s.repo.reads)
+	r.Less(time.Duration(0), s.redis.TTL("1:1"))
+}
+
+// missing reads a pair without banners twice and checks that the second 404 is served by the cache
+func (s *CacheTestSuite) missing(featureId int64, tagId int64) {
+	r := s.Require()
+	_, err := s.uc.GetOne(context.Background(), featureId, tagId, false)
+	r.ErrorIs(err, pgx.ErrNoRows)
+	reads := s.repo.reads
+	_, err = s.uc.GetOne(context.Background(), featureId, tagId, false)
+	r.ErrorIs(err, pgx.ErrNoRows)
+	r.Equal(reads, s.repo.reads)
+}
+
+func (s *CacheTestSuite) TestNotFoundMarkerExpires() {
+	r := s.Require()
+	s.missing(1, 3)
+	reads := s.repo.reads
+
+	s.redis.FastForward(bannerRepo.NotFoundExpTime)
+
+	_, err := s.uc.GetOne(context.Background(), 1, 3, false)
+	r.ErrorIs(err, pgx.ErrNoRows)
+	r.Equal(reads+1, s.repo.reads)
+}
+
+func (s *CacheTestSuite) TestAddItemClearsNotFound() {
+	r := s.Require()
+	s.missing(2, 1)
+
+	_, err := s.uc.AddItem(context.Background(), models.BannerForm{Content: models.BannerContent(`{"title": "new"}`), FeatureId: 2, TagIds: []int64{1}, IsActive: true})
+	r.NoError(err)
+
+	result, err := s.uc.GetOne(context.Background(), 2, 1, false)
+	r.NoError(err)
+	r.JSONEq(`{"title": "new"}`, string(result.Content))
+}
+
+func (s *CacheTestSuite) TestUpdateClearsNotFound() {
+	r := s.Require()
+	s.missing(1, 3)
+
+	_, err := s.uc.UpdateBanner(context.Background(), models.BannerUpdateForm{TagIds: []int64{1, 3}}, 1, nil)
+	r.NoError(err)
+
+	result, err := s.uc.GetOne(context.Background(), 1, 3, false)
+	r.NoError(err)
+	r.Equal(int64(1), result.Id)
+}
+
+func (s *CacheTestSuite) TestDefaultBannerClearsNotFound() {
+	r := s.Require()
+	s.missing(1, 3)
+	s.missing(1, 4)
+
+	r.NoError(s.cache.DeleteFallback(context.Background(), 1))
+
+	for _, tagId := range []int64{3, 4} {
+		missing, err := s.cache.IsNotFound(context.Background(), 1, tagId, false)
+		r.NoError(err)
+		r.False(missing)
+	}
+}
+
+func (s *CacheTestSuite) TestNotFoundMarkersExpireSeparately() {
+	r := s.Require()
+	s.missing(1, 3)
+	s.redis.FastForward(bannerRepo.NotFoundExpTime / 2)
+	s.missing(1, 4)
+	s.redis.FastForward(bannerRepo.NotFoundExpTime / 2)
+
+	missing, err := s.cache.IsNotFound(context.Background(), 1, 3, false)
+	r.NoError(err)
+	r.False(missing)
+	missing, err = s.cache.IsNotFound(context.Background(), 1, 4, false)
+	r.NoError(err)
+	r.True(missing)
+}
+
+func (s *CacheTestSuite) TestDefaultBannerKeepsOtherFeaturesNotFound() {
+	r := s.Require()
+	s.missing(1, 3)
+	s.missing(2, 3)
+
+	r.NoError(s.cache.DeleteFallback(context.Background(), 1))
+
+	missing, err := s.cache.IsNotFound(context.Background(), 2, 3, false)
+	r.NoError(err)
+	r.True(missing)
+}
@@ -0,0 +1,109 @@
+package tests
+
+import (
+	"context"
+
+	"github.com/Alladan04/avito_test/internal/models"
+	bannerRepo "github.com/Alladan04/avito_test/internal/pkg/banner/repo"
+	"github.com/Alladan04/avito_test/internal/pkg/feature"
+	featureRepo "github.com/Alladan04/avito_test/internal/pkg/feature/repo"
+	featureUsecase "github.com/Alladan04/avito_test/internal/pkg/feature/usecase"
+	"github.com/Alladan04/avito_test/internal/pkg/tag"
+	tagRepo "github.com/Alladan04/avito_test/internal/pkg/tag/repo"
+	tagUsecase "github.com/Alladan04/avito_test/internal/pkg/tag/usecase"
+)
+
+// addFeatureBanner inserts a banner of a new feature on a new tag and returns the ids of all three
+func (s *APITestSuite) addFeatureBanner() (featureId int64, tagId int64, bannerId int64) {
+	r := s.Require()
+	ctx := context.Background()
+	r.NoError(s.db.QueryRow(ctx, `INSERT INTO feature (id) VALUES (DEFAULT) RETURNING id;`).Scan(&featureId))
+	r.NoError(s.db.QueryRow(ctx, `INSERT INTO tag (id) VALUES (DEFAULT) RETURNING id;`).Scan(&tagId))
+	r.NoError(s.db.QueryRow(ctx, `INSERT INTO banner (content, feature_id, create_time, update_time, is_active, status)
+		VALUES ('{"title": "cascade"}', $1, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, true, 'published') RETURNING id;`, featureId).Scan(&bannerId))
+	_, err := s.db.Exec(ctx, `INSERT INTO banner_tag (banner_id, tag_id, feature_id) VALUES ($1, $2, $3);`, bannerId, tagId, featureId)
+	r.NoError(err)
+	return featureId, tagId, bannerId
+}
+
+func (s *APITestSuite) bannerAuditActions(id int64) []string {
+	rows, err := s.db.Query(context.Background(), `SELECT action FROM audit_log WHERE entity=$1 AND entity_id=$2 ORDER BY id;`, models.AuditEntityBanner, id)
+	s.Require().NoError(err)
+	defer rows.Close()
+	result := make([]string, 0)
+	for rows.Next() {
+		var action string
+		s.Require().NoError(rows.Scan(&action))
+		result = append(result, action)
+	}
+	return result
+}
+
+func (s *APITestSuite) newFeatureUsecase() *featureUsecase.FeatureUsecase {
+	return featureUsecase.NewFeatureUsecase(featureRepo.NewFeatureRepo(s.db, *s.conn), bannerRepo.NewCacheRepo(*s.redisdb))
+}
+
+func (s *APITestSuite) newTagUsecase() *tagUsecase.TagUsecase {
+	return tagUsecase.NewTagUsecase(tagRepo.NewTagRepo(s.db, *s.conn), bannerRepo.NewCacheRepo(*s.redisdb))
+}
+
+func (s *APITestSuite) TestDeleteUsedFeatureWithoutCascade() {
+	r := s.Require()
+	featureId, _, bannerId := s.addFeatureBanner()
+
+	r.ErrorIs(s.newFeatureUsecase().DeleteFeature(context.Background(), featureId, false), feature.ErrFeatureInUse)
+
+	_, err := s.repo.GetBanner(context.Background(), bannerId)
+	r.NoError(err)
+}
+
+func (s *APITestSuite) TestDeleteFeatureCascadeGoesThroughTrash() {
+	r := s.Require()
+	featureId, _, bannerId := s.addFeatureBanner()
+
+	r.NoError(s.newFeatureUsecase().DeleteFeature(context.Background(), featureId, true))
+
+	var exists bool
+	r.NoError(s.db.QueryRow(context.Background(), `SELECT EXISTS (SELECT 1 FROM banner WHERE id=$1);`, bannerId).Scan(&exists))
+	r.False(exists)
+	r.Equal([]string{models.AuditActionDelete}, s.bannerAuditActions(bannerId))
+}
+
+func (s *APITestSuite) TestDeleteUsedTagWithoutCascade() {
+	r := s.Require()
+	_, tagId, bannerId := s.addFeatureBanner()
+
+	r.ErrorIs(s.newTagUsecase().DeleteTag(context.Background(), tagId, false), tag.ErrTagInUse)
+
+	item, err := s.repo.GetBanner(context.Background(), bannerId)
+	r.NoError(err)
+	r.Equal([]int64{tagId}, item.TagIds)
+}
+
+func (s *APITestSuite) TestDeleteTagCascadeUnlinksBanners() {
+	r := s.Require()
+	_, tagId, bannerId := s.addFeatureBanner()
+	before, err := s.repo.GetBanner(context.Background(), bannerId)
+	r.NoError(err)
+
+	r.NoError(s.newTagUsecase().DeleteTag(context.Background(), tagId, true))
+
+	after, err := s.repo.GetBanner(context.Background(), bannerId)
+	r.NoError(err)
+	r.Empty(after.TagIds)
+	r.Equal(before.Version+1, after.Version)
+	r.Equal([]string{models.AuditActionUpdate}, s.bannerAuditActions(bannerId))
+}
+
+func (s *APITestSuite) TestDeleteFilteredAuditsBanners() {
+	r := s.Require()
+	featureId, _, bannerId := s.addFeatureBanner()
+
+	_, _, err := s.repo.DeleteFiltered(context.Background(), featureId, 0, 10, "testadmin")
+	r.NoError(err)
+
+	var actor, action string
+	r.NoError(s.db.QueryRow(context.Background(), `SELECT actor, action FROM audit_log WHERE entity=$1 AND entity_id=$2;`, models.AuditEntityBanner, bannerId).Scan(&actor, &action))
+	r.Equal("testadmin", actor)
+	r.Equal(models.AuditActionDelete, action)
+}
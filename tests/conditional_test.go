@@ -0,0 +1,121 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Alladan04/avito_test/internal/models"
+	"github.com/Alladan04/avito_test/internal/pkg/banner"
+	bannerHttp "github.com/Alladan04/avito_test/internal/pkg/banner/delivery/http"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/suite"
+)
+
+// conditionalUsecaseStub keeps a single banner of version 3 and records the versions the handlers pass, the rest panic
+type conditionalUsecaseStub struct {
+	banner.BannerUsecase
+	calls    int
+	versions []int64
+}
+
+func (uc *conditionalUsecaseStub) check(versions []int64) error {
+	uc.calls++
+	uc.versions = versions
+	if len(versions) == 0 {
+		return nil
+	}
+	for _, version := range versions {
+		if version == 3 {
+			return nil
+		}
+	}
+	return banner.ErrVersionMismatch
+}
+
+func (uc *conditionalUsecaseStub) UpdateBanner(ctx context.Context, payload models.BannerUpdateForm, id int64, versions []int64) (int64, error) {
+	if err := uc.check(versions); err != nil {
+		return 0, err
+	}
+	return 4, nil
+}
+
+func (uc *conditionalUsecaseStub) DeleteBanner(ctx context.Context, id int64, versions []int64) error {
+	return uc.check(versions)
+}
+
+type ConditionalTestSuite struct {
+	suite.Suite
+
+	uc     *conditionalUsecaseStub
+	router *mux.Router
+}
+
+func TestConditionalSuite(t *testing.T) {
+	suite.Run(t, new(ConditionalTestSuite))
+}
+
+func (s *ConditionalTestSuite) SetupTest() {
+	s.uc = &conditionalUsecaseStub{}
+	h := bannerHttp.NewBannerHandler(s.uc)
+	s.router = mux.NewRouter()
+	s.router.HandleFunc("/api/banner/{id}", h.UpdateBanner).Methods(http.MethodPatch)
+	s.router.HandleFunc("/api/banner/{id}", h.DeleteBanner).Methods(http.MethodDelete)
+}
+
+func (s *ConditionalTestSuite) serve(method string, ifMatch ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/api/banner/1", strings.NewReader(`{"is_active": false}`))
+	for _, value := range ifMatch {
+		req.Header.Add("If-Match", value)
+	}
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	return w
+}
+
+func (s *ConditionalTestSuite) TestStaleVersionIsRejected() {
+	r := s.Require()
+	w := s.serve(http.MethodPatch, `"2"`)
+	r.Equal(http.StatusPreconditionFailed, w.Code)
+	r.Equal([]int64{2}, s.uc.versions)
+
+	w = s.serve(http.MethodDelete, `"2"`)
+	r.Equal(http.StatusPreconditionFailed, w.Code)
+}
+
+func (s *ConditionalTestSuite) TestAnyListedVersionMatches() {
+	r := s.Require()
+	w := s.serve(http.MethodPatch, `"2", "3"`)
+	r.Equal(http.StatusOK, w.Code)
+	r.Equal(`"4"`, w.Header().Get("ETag"))
+	r.Equal([]int64{2, 3}, s.uc.versions)
+
+	// список может прийти и несколькими заголовками
+	w = s.serve(http.MethodDelete, `"1"`, `W/"3", "3"`)
+	r.Equal(http.StatusNoContent, w.Code)
+	r.Equal([]int64{1, 3}, s.uc.versions)
+}
+
+func (s *ConditionalTestSuite) TestStarMatchesExistingBanner() {
+	r := s.Require()
+	w := s.serve(http.MethodPatch, `"2", *`)
+	r.Equal(http.StatusOK, w.Code)
+	r.Empty(s.uc.versions)
+}
+
+func (s *ConditionalTestSuite) TestWeakTagsNeverMatch() {
+	r := s.Require()
+	w := s.serve(http.MethodDelete, `W/"3"`)
+	r.Equal(http.StatusPreconditionFailed, w.Code)
+	r.Zero(s.uc.calls)
+}
+
+func (s *ConditionalTestSuite) TestNoHeaderSkipsCheck() {
+	r := s.Require()
+	w := s.serve(http.MethodDelete)
+	r.Equal(http.StatusNoContent, w.Code)
+	r.Equal(1, s.uc.calls)
+	r.Empty(s.uc.versions)
+}
@@ -0,0 +1,162 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Alladan04/avito_test/internal/models"
+	"github.com/Alladan04/avito_test/internal/pkg/banner"
+	bannerUsecase "github.com/Alladan04/avito_test/internal/pkg/banner/usecase"
+	bannerWorker "github.com/Alladan04/avito_test/internal/pkg/banner/worker"
+	"github.com/jackc/pgx/v4"
+	"github.com/stretchr/testify/suite"
+)
+
+// jobRepoStub keeps jobs in memory, TakeJob hands out pending ones in order of ids
+type jobRepoStub struct {
+	mu   sync.Mutex
+	jobs []models.Job
+}
+
+func (r *jobRepoStub) AddJob(ctx context.Context, item models.Job) (models.Job, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	item.Id = int64(len(r.jobs) + 1)
+	item.Status = models.JobStatusPending
+	r.jobs = append(r.jobs, item)
+	return item, nil
+}
+
+func (r *jobRepoStub) GetJob(ctx context.Context, id int64) (models.Job, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if id < 1 || id > int64(len(r.jobs)) {
+		return models.Job{}, pgx.ErrNoRows
+	}
+	return r.jobs[id-1], nil
+}
+
+func (r *jobRepoStub) TakeJob(ctx context.Context, kind string, staleAfter time.Duration) (models.Job, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, item := range r.jobs {
+		if item.Kind == kind && item.Status == models.JobStatusPending {
+			r.jobs[i].Status = models.JobStatusRunning
+			return r.jobs[i], nil
+		}
+	}
+	return models.Job{}, pgx.ErrNoRows
+}
+
+func (r *jobRepoStub) UpdateJob(ctx context.Context, item models.Job) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.jobs[item.Id-1] = item
+	return nil
+}
+
+// deleteRepoStub holds remaining banners of every feature, each on its own tag
+type deleteRepoStub struct {
+	banner.BannerRepo
+	remaining map[int64]int64
+	failAfter int64
+	deleted   int64
+	authors   []string
+}
+
+func (r *deleteRepoStub) CountFiltered(ctx context.Context, featureId int64, tagId int64) (int64, error) {
+	return r.remaining[featureId], nil
+}
+
+func (r *deleteRepoStub) DeleteFiltered(ctx context.Context, featureId int64, tagId int64, limit int64, author string) ([]models.FeatureTag, int64, error) {
+	if r.failAfter > 0 && r.deleted >= r.failAfter {
+		return nil, 0, errors.New("connection lost")
+	}
+	count := min(limit, r.remaining[featureId])
+	r.remaining[featureId] -= count
+	keys := make([]models.FeatureTag, 0, count)
+	for i := int64(0); i < count; i++ {
+		r.deleted++
+		keys = append(keys, models.FeatureTag{FeatureId: featureId, TagId: r.deleted})
+	}
+	r.authors = append(r.authors, author)
+	return keys, count, nil
+}
+
+type DeleteWorkerTestSuite struct {
+	suite.Suite
+
+	jobs  *jobRepoStub
+	repo  *deleteRepoStub
+	cache *cacheStub
+}
+
+func TestDeleteWorkerSuite(t *testing.T) {
+	suite.Run(t, new(DeleteWorkerTestSuite))
+}
+
+func (s *DeleteWorkerTestSuite) SetupTest() {
+	s.jobs = &jobRepoStub{}
+	s.repo = &deleteRepoStub{remaining: map[int64]int64{1: 250}}
+	s.cache = &cacheStub{}
+}
+
+// run starts the worker and waits until the job leaves the queue
+func (s *DeleteWorkerTestSuite) run(item models.Job) models.Job {
+	r := s.Require()
+	item, err := s.jobs.AddJob(context.Background(), item)
+	r.NoError(err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go bannerWorker.NewDeleteWorker(s.jobs, s.repo, s.cache).Run(ctx)
+	r.Eventually(func() bool {
+		result, err := s.jobs.GetJob(context.Background(), item.Id)
+		return err == nil && (result.Status == models.JobStatusDone || result.Status == models.JobStatusFailed)
+	}, 3*time.Second, 10*time.Millisecond)
+	result, err := s.jobs.GetJob(context.Background(), item.Id)
+	r.NoError(err)
+	return result
+}
+
+func (s *DeleteWorkerTestSuite) TestDeletesInBatches() {
+	r := s.Require()
+	result := s.run(models.Job{Kind: models.JobKindDeleteBanners, FeatureId: 1, Actor: "admin"})
+
+	r.Equal(models.JobStatusDone, result.Status)
+	r.Equal(int64(250), result.Total)
+	r.Equal(int64(250), result.Processed)
+	r.Zero(s.repo.remaining[1])
+	// три пачки по 100 и последняя пустая проверка
+	r.Equal([]string{"admin", "admin", "admin", "admin"}, s.repo.authors)
+	r.Len(s.cache.deleted, 250)
+}
+
+func (s *DeleteWorkerTestSuite) TestFailureKeepsProgress() {
+	r := s.Require()
+	s.repo.failAfter = 100
+	result := s.run(models.Job{Kind: models.JobKindDeleteBanners, FeatureId: 1, Actor: "admin"})
+
+	r.Equal(models.JobStatusFailed, result.Status)
+	r.Equal("connection lost", result.Error)
+	r.Equal(int64(100), result.Processed)
+	r.Equal(int64(250), result.Total)
+	r.Len(s.cache.deleted, 100)
+}
+
+func (s *DeleteWorkerTestSuite) TestUsecaseQueuesJob() {
+	r := s.Require()
+	uc := bannerUsecase.NewBannerUsecase(s.repo, s.cache, s.jobs, nil, &counterStub{}, nil)
+
+	_, err := uc.DeleteFiltered(asAdmin("admin"), 0, 0)
+	r.ErrorIs(err, banner.ErrEmptyFilter)
+
+	item, err := uc.DeleteFiltered(asAdmin("admin"), 1, 0)
+	r.NoError(err)
+	r.Equal(models.JobStatusPending, item.Status)
+	r.Equal("admin", item.Actor)
+	// удаление идёт в фоне, сам запрос ничего не трогает
+	r.Equal(int64(250), s.repo.remaining[1])
+}
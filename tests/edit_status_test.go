@@ -0,0 +1,128 @@
+package tests
+
+import (
+	"context"
+	"time"
+
+	"github.com/Alladan04/avito_test/internal/models"
+	"github.com/Alladan04/avito_test/internal/pkg/banner"
+	"github.com/jackc/pgx/v4"
+)
+
+// addPublishedBanner inserts a published banner on a new tag of feature 1 and returns its id and tag
+func (s *APITestSuite) addPublishedBanner() (int64, int64) {
+	r := s.Require()
+	var tagId, id int64
+	r.NoError(s.db.QueryRow(context.Background(), `INSERT INTO tag (id) VALUES (DEFAULT) RETURNING id;`).Scan(&tagId))
+	r.NoError(s.db.QueryRow(context.Background(), `INSERT INTO banner (content, feature_id, create_time, update_time, is_active, status)
+		VALUES ('{"title": "published"}', 1, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, true, 'published') RETURNING id;`).Scan(&id))
+	_, err := s.db.Exec(context.Background(), `INSERT INTO banner_tag (banner_id, tag_id, feature_id) VALUES ($1, $2, 1);`, id, tagId)
+	r.NoError(err)
+	return id, tagId
+}
+
+func (s *APITestSuite) bannerStatus(id int64) string {
+	var status string
+	s.Require().NoError(s.db.QueryRow(context.Background(), `SELECT status FROM banner WHERE id=$1;`, id).Scan(&status))
+	return status
+}
+
+func (s *APITestSuite) TestEditSendsPublishedBannerToDraft() {
+	r := s.Require()
+	id, tagId := s.addPublishedBanner()
+	_, err := s.uc.GetOne(context.Background(), 1, tagId, false)
+	r.NoError(err)
+
+	content := models.BannerContent(`{"title": "edited"}`)
+	_, err = s.uc.UpdateBanner(context.Background(), models.BannerUpdateForm{Content: &content}, id, nil)
+	r.NoError(err)
+
+	r.Equal(models.BannerStatusDraft, s.bannerStatus(id))
+	var action, from string
+	r.NoError(s.db.QueryRow(context.Background(), `SELECT action, from_status FROM banner_transition WHERE banner_id=$1 ORDER BY id DESC LIMIT 1;`, id).Scan(&action, &from))
+	r.Equal(models.WorkflowActionEdit, action)
+	r.Equal(models.BannerStatusPublished, from)
+	// правка не попадает к пользователям без нового одобрения
+	_, err = s.uc.GetOne(context.Background(), 1, tagId, false)
+	r.ErrorIs(err, pgx.ErrNoRows)
+}
+
+func (s *APITestSuite) TestServingSettingsKeepBannerPublished() {
+	r := s.Require()
+	id, tagId := s.addPublishedBanner()
+	isActive, weight, impressionCap := true, int64(0), int64(5)
+	until := time.Now().UTC().Add(time.Hour)
+	content := models.BannerContent(`{"title":"published"}`)
+	tagIds := []int64{tagId}
+	// то же содержимое в другом написании и тот же набор тегов - это не правка
+	_, err := s.uc.UpdateBanner(context.Background(), models.BannerUpdateForm{
+		Content:       &content,
+		TagIds:        tagIds,
+		IsActive:      &isActive,
+		Weight:        &weight,
+		ImpressionCap: &impressionCap,
+		ActiveUntil:   models.OptionalTime{Set: true, Value: &until},
+	}, id, nil)
+	r.NoError(err)
+
+	r.Equal(models.BannerStatusPublished, s.bannerStatus(id))
+	var transitions int
+	r.NoError(s.db.QueryRow(context.Background(), `SELECT count(*) FROM banner_transition WHERE banner_id=$1;`, id).Scan(&transitions))
+	r.Zero(transitions)
+	_, err = s.uc.GetOne(context.Background(), 1, tagId, false)
+	r.NoError(err)
+
+	// выключенный баннер тоже остаётся одобренным и возвращается в выдачу без ревью
+	isActive = false
+	_, err = s.uc.UpdateBanner(context.Background(), models.BannerUpdateForm{IsActive: &isActive}, id, nil)
+	r.NoError(err)
+	r.Equal(models.BannerStatusPublished, s.bannerStatus(id))
+	_, err = s.uc.GetOne(context.Background(), 1, tagId, false)
+	r.ErrorIs(err, pgx.ErrNoRows)
+}
+
+func (s *APITestSuite) TestTagChangeSendsPublishedBannerToDraft() {
+	r := s.Require()
+	id, _ := s.addPublishedBanner()
+	var tagId int64
+	r.NoError(s.db.QueryRow(context.Background(), `INSERT INTO tag (id) VALUES (DEFAULT) RETURNING id;`).Scan(&tagId))
+
+	_, err := s.uc.UpdateBanner(context.Background(), models.BannerUpdateForm{TagIds: []int64{tagId}}, id, nil)
+	r.NoError(err)
+	r.Equal(models.BannerStatusDraft, s.bannerStatus(id))
+}
+
+func (s *APITestSuite) TestRestoreRevisionSendsPublishedBannerToDraft() {
+	r := s.Require()
+	id, tagId := s.addPublishedBanner()
+	for _, title := range []string{"first revision", "second revision"} {
+		content := models.BannerContent(`{"title": "` + title + `"}`)
+		_, err := s.uc.UpdateBanner(context.Background(), models.BannerUpdateForm{Content: &content}, id, nil)
+		r.NoError(err)
+	}
+	_, err := s.db.Exec(context.Background(), `UPDATE banner SET status='published' WHERE id=$1;`, id)
+	r.NoError(err)
+	_, err = s.uc.GetOne(context.Background(), 1, tagId, false)
+	r.NoError(err)
+
+	r.NoError(s.uc.RestoreRevision(context.Background(), id, 1))
+
+	r.Equal(models.BannerStatusDraft, s.bannerStatus(id))
+	_, err = s.uc.GetOne(context.Background(), 1, tagId, false)
+	r.ErrorIs(err, pgx.ErrNoRows)
+}
+
+func (s *APITestSuite) TestUpdateAcceptsAnyExpectedVersion() {
+	r := s.Require()
+	id, _ := s.addPublishedBanner()
+	item, err := s.uc.GetBanner(context.Background(), id)
+	r.NoError(err)
+	content := models.BannerContent(`{"title": "edited"}`)
+
+	_, err = s.uc.UpdateBanner(context.Background(), models.BannerUpdateForm{Content: &content}, id, []int64{item.Version + 10})
+	r.ErrorIs(err, banner.ErrVersionMismatch)
+
+	version, err := s.uc.UpdateBanner(context.Background(), models.BannerUpdateForm{Content: &content}, id, []int64{item.Version + 10, item.Version})
+	r.NoError(err)
+	r.Less(item.Version, version)
+}
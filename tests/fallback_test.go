@@ -0,0 +1,86 @@
+package tests
+
+import (
+	"context"
+
+	"github.com/Alladan04/avito_test/internal/models"
+	"github.com/Alladan04/avito_test/internal/pkg/feature"
+	"github.com/jackc/pgx/v4"
+)
+
+// addDefaultBanner adds a banner without tags and makes it the default of feature 3
+func (s *CacheTestSuite) addDefaultBanner(isActive bool) {
+	s.repo.banners[5] = models.BannerForm{Content: models.BannerContent(`{"title": "default"}`), FeatureId: 3, IsActive: isActive}
+	s.repo.defaults = map[int64]int64{3: 5}
+}
+
+func (s *CacheTestSuite) TestUpdateOfBannerWithoutTagsEvictsDefault() {
+	r := s.Require()
+	s.addDefaultBanner(true)
+	result := s.cached(3, 7)
+	r.True(result.Fallback)
+	r.JSONEq(`{"title": "default"}`, string(result.Content))
+
+	content := models.BannerContent(`{"title": "changed"}`)
+	_, err := s.uc.UpdateBanner(context.Background(), models.BannerUpdateForm{Content: &content}, 5, nil)
+	r.NoError(err)
+
+	result, err = s.uc.GetOne(context.Background(), 3, 7, false)
+	r.NoError(err)
+	r.JSONEq(`{"title": "changed"}`, string(result.Content))
+
+	r.NoError(s.uc.DeleteBanner(context.Background(), 5, nil))
+	_, err = s.uc.GetOne(context.Background(), 3, 7, false)
+	r.ErrorIs(err, pgx.ErrNoRows)
+}
+
+func (s *CacheTestSuite) TestActivatedBannerWithoutTagsClearsNotFound() {
+	r := s.Require()
+	s.addDefaultBanner(false)
+	// пара запомнилась пустой, пока баннер по умолчанию был выключен
+	_, err := s.uc.GetOne(context.Background(), 3, 7, false)
+	r.ErrorIs(err, pgx.ErrNoRows)
+	reads := s.repo.reads
+	_, err = s.uc.GetOne(context.Background(), 3, 7, false)
+	r.ErrorIs(err, pgx.ErrNoRows)
+	r.Equal(reads, s.repo.reads)
+
+	isActive := true
+	_, err = s.uc.UpdateBanner(context.Background(), models.BannerUpdateForm{IsActive: &isActive}, 5, nil)
+	r.NoError(err)
+
+	result, err := s.uc.GetOne(context.Background(), 3, 7, false)
+	r.NoError(err)
+	r.True(result.Fallback)
+	r.JSONEq(`{"title": "default"}`, string(result.Content))
+}
+
+func (s *APITestSuite) TestDefaultBannerServesOtherTags() {
+	r := s.Require()
+	ctx := context.Background()
+	featureId, _, bannerId := s.addFeatureBanner()
+	var tagId int64
+	r.NoError(s.db.QueryRow(ctx, `INSERT INTO tag (id) VALUES (DEFAULT) RETURNING id;`).Scan(&tagId))
+
+	_, err := s.newFeatureUsecase().SetDefaultBanner(ctx, featureId, &bannerId)
+	r.NoError(err)
+
+	result, err := s.uc.GetOne(ctx, featureId, tagId, false)
+	r.NoError(err)
+	r.Equal(bannerId, result.Id)
+	r.True(result.Fallback)
+
+	_, err = s.newFeatureUsecase().SetDefaultBanner(ctx, featureId, nil)
+	r.NoError(err)
+	_, err = s.uc.GetOne(ctx, featureId, tagId, false)
+	r.Error(err)
+}
+
+func (s *APITestSuite) TestDefaultBannerMustBelongToFeature() {
+	r := s.Require()
+	featureId, _, _ := s.addFeatureBanner()
+	_, _, otherBannerId := s.addFeatureBanner()
+
+	_, err := s.newFeatureUsecase().SetDefaultBanner(context.Background(), featureId, &otherBannerId)
+	r.ErrorIs(err, feature.ErrInvalidDefaultBanner)
+}
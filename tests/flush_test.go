@@ -0,0 +1,89 @@
+package tests
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	statsRepo "github.com/Alladan04/avito_test/internal/pkg/stats/repo"
+	statsWorker "github.com/Alladan04/avito_test/internal/pkg/stats/worker"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/suite"
+)
+
+type FlushTestSuite struct {
+	suite.Suite
+
+	redis    *miniredis.Miniredis
+	client   *redis.Client
+	counters *statsRepo.CounterRepo
+}
+
+func TestFlushSuite(t *testing.T) {
+	suite.Run(t, new(FlushTestSuite))
+}
+
+func (s *FlushTestSuite) SetupTest() {
+	s.redis = miniredis.RunT(s.T())
+	s.client = redis.NewClient(&redis.Options{Addr: s.redis.Addr()})
+	s.counters = statsRepo.NewCounterRepo(*s.client)
+}
+
+// crash takes a batch of the buffered counters and leaves it behind as a worker dying before the ack would.
+// The batch is named as if it was taken age ago
+func (s *FlushTestSuite) crash(age time.Duration) string {
+	r := s.Require()
+	batch, _, err := s.counters.TakeCounters(context.Background())
+	r.NoError(err)
+	r.NotEmpty(batch)
+	abandoned := "stats:flushing:" + strconv.FormatInt(time.Now().Add(-age).UnixNano(), 10)
+	r.NoError(s.client.Rename(context.Background(), batch, abandoned).Err())
+	return abandoned
+}
+
+func (s *FlushTestSuite) TestWorkerRecoversAbandonedBatch() {
+	r := s.Require()
+	now := time.Now().UTC()
+	r.NoError(s.counters.AddImpression(context.Background(), 1, now))
+	r.NoError(s.counters.AddImpression(context.Background(), 1, now))
+	abandoned := s.crash(time.Hour)
+	r.NoError(s.counters.AddClick(context.Background(), 2, now))
+	// эту пачку сейчас сбрасывает живая реплика
+	inFlight := s.crash(0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go statsWorker.NewFlushWorker(s.counters, &statsRepoStub{}).Run(ctx)
+	r.Eventually(func() bool { return !s.redis.Exists(abandoned) }, time.Second, time.Millisecond)
+	cancel()
+
+	r.True(s.redis.Exists(inFlight))
+	_, counters, err := s.counters.TakeCounters(context.Background())
+	r.NoError(err)
+	r.Len(counters, 1)
+	r.Equal(int64(1), counters[0].BannerId)
+	r.Equal(int64(2), counters[0].Impressions)
+}
+
+func (s *FlushTestSuite) TestRecoveryMergesIntoPending() {
+	r := s.Require()
+	now := time.Now().UTC()
+	r.NoError(s.counters.AddImpression(context.Background(), 1, now))
+	s.crash(time.Hour)
+	// пока пачка лежала брошенной, пришли новые показы
+	r.NoError(s.counters.AddImpression(context.Background(), 1, now))
+
+	recovered, err := s.counters.RecoverCounters(context.Background(), time.Minute)
+	r.NoError(err)
+	r.Equal(1, recovered)
+	recovered, err = s.counters.RecoverCounters(context.Background(), time.Minute)
+	r.NoError(err)
+	r.Zero(recovered)
+
+	_, counters, err := s.counters.TakeCounters(context.Background())
+	r.NoError(err)
+	r.Len(counters, 1)
+	r.Equal(int64(2), counters[0].Impressions)
+}
@@ -0,0 +1,122 @@
+package tests
+
+import (
+	"context"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Alladan04/avito_test/internal/models"
+	"github.com/Alladan04/avito_test/internal/pb"
+	"github.com/Alladan04/avito_test/internal/pkg/banner"
+	bannerGrpc "github.com/Alladan04/avito_test/internal/pkg/banner/delivery/grpc"
+	"github.com/Alladan04/avito_test/internal/pkg/middleware"
+	"github.com/Alladan04/avito_test/internal/pkg/utils"
+	"github.com/jackc/pgx/v4"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// bannerUsecaseStub implements the methods used over gRPC, the rest panic
+type bannerUsecaseStub struct {
+	banner.BannerUsecase
+	deletedBy string
+}
+
+func (uc *bannerUsecaseStub) GetOne(ctx context.Context, featureId int64, tagId int64, showLastRevision bool) (models.UserBanner, error) {
+	if featureId != 1 {
+		return models.UserBanner{}, pgx.ErrNoRows
+	}
+	content := models.BannerContent(`{"title":"sale"}`)
+	return models.UserBanner{Id: 7, Content: content, Hash: content.Hash(), IsActive: true}, nil
+}
+
+func (uc *bannerUsecaseStub) DeleteBanner(ctx context.Context, id int64, versions []int64) error {
+	if len(versions) > 0 && versions[0] != 3 {
+		return banner.ErrVersionMismatch
+	}
+	uc.deletedBy = utils.UsernameFromContext(ctx)
+	return nil
+}
+
+type GrpcTestSuite struct {
+	suite.Suite
+
+	uc     *bannerUsecaseStub
+	server *grpc.Server
+	conn   *grpc.ClientConn
+	client pb.BannerServiceClient
+}
+
+func TestGrpcSuite(t *testing.T) {
+	suite.Run(t, new(GrpcTestSuite))
+}
+
+func (s *GrpcTestSuite) SetupTest() {
+	s.Require().NoError(os.Setenv("JWT_USER_SECRET", "user-secret"))
+	s.Require().NoError(os.Setenv("JWT_ADMIN_SECRET", "admin-secret"))
+	s.uc = &bannerUsecaseStub{}
+	s.server = grpc.NewServer(grpc.UnaryInterceptor(middleware.JwtInterceptor(map[string]middleware.Access{
+		pb.BannerService_GetOne_FullMethodName:       middleware.AccessUser,
+		pb.BannerService_DeleteBanner_FullMethodName: middleware.AccessAdmin,
+	})))
+	pb.RegisterBannerServiceServer(s.server, bannerGrpc.NewBannerHandler(s.uc))
+	listener := bufconn.Listen(1 << 20)
+	go func() {
+		_ = s.server.Serve(listener)
+	}()
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	s.Require().NoError(err)
+	s.conn = conn
+	s.client = pb.NewBannerServiceClient(conn)
+}
+
+func (s *GrpcTestSuite) TearDownTest() {
+	s.conn.Close()
+	s.server.Stop()
+}
+
+func (s *GrpcTestSuite) withToken(username string, admin bool) context.Context {
+	token, err := utils.GenToken(models.User{Username: username, IsAdmin: admin}, time.Hour)
+	s.Require().NoError(err)
+	return metadata.AppendToOutgoingContext(context.Background(), "token", "Bearer "+token)
+}
+
+func (s *GrpcTestSuite) TestGetOneNeedsToken() {
+	_, err := s.client.GetOne(context.Background(), &pb.GetOneRequest{FeatureId: 1, TagId: 1})
+	s.Equal(codes.Unauthenticated, status.Code(err))
+}
+
+func (s *GrpcTestSuite) TestGetOne() {
+	r := s.Require()
+	result, err := s.client.GetOne(s.withToken("user01", false), &pb.GetOneRequest{FeatureId: 1, TagId: 1})
+	r.NoError(err)
+	r.Equal(`{"title":"sale"}`, result.Content)
+	r.False(result.Inactive)
+
+	_, err = s.client.GetOne(s.withToken("user01", false), &pb.GetOneRequest{FeatureId: 2, TagId: 1})
+	r.Equal(codes.NotFound, status.Code(err))
+}
+
+func (s *GrpcTestSuite) TestDeleteForAdminsOnly() {
+	r := s.Require()
+	_, err := s.client.DeleteBanner(s.withToken("user01", false), &pb.DeleteBannerRequest{Id: 7})
+	r.Equal(codes.PermissionDenied, status.Code(err))
+
+	_, err = s.client.DeleteBanner(s.withToken("admin01", true), &pb.DeleteBannerRequest{Id: 7, Version: 2})
+	r.Equal(codes.FailedPrecondition, status.Code(err))
+
+	_, err = s.client.DeleteBanner(s.withToken("admin01", true), &pb.DeleteBannerRequest{Id: 7, Version: 3})
+	r.NoError(err)
+	r.Equal("admin01", s.uc.deletedBy)
+}
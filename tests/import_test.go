@@ -0,0 +1,207 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Alladan04/avito_test/internal/models"
+	"github.com/Alladan04/avito_test/internal/pkg/banner"
+	bannerHttp "github.com/Alladan04/avito_test/internal/pkg/banner/delivery/http"
+	"github.com/stretchr/testify/suite"
+)
+
+func importRow(row int, content string, featureId int64, tagIds ...int64) models.ImportRow {
+	return models.ImportRow{Row: row, Form: models.BannerForm{Content: models.BannerContent(content), FeatureId: featureId, TagIds: tagIds, IsActive: true}}
+}
+
+func (s *CacheTestSuite) TestImportSavesAllRows() {
+	r := s.Require()
+	report, err := s.uc.ImportBanners(context.Background(), []models.ImportRow{
+		importRow(1, `{"title": "first"}`, 2, 1),
+		importRow(2, `{"title": "second"}`, 2, 2),
+	}, false)
+	r.NoError(err)
+	r.Equal(2, report.Imported)
+	r.Empty(report.Errors)
+	r.Len(s.repo.banners, 3)
+}
+
+func (s *CacheTestSuite) TestImportIsAllOrNothing() {
+	r := s.Require()
+	invalid := importRow(2, `{"title": "second"}`, 2, 2)
+	invalid.Form.Weight = -1
+	report, err := s.uc.ImportBanners(context.Background(), []models.ImportRow{
+		importRow(1, `{"title": "first"}`, 2, 1),
+		invalid,
+		importRow(3, `{"title": "third"}`, 0, 3),
+	}, false)
+	r.NoError(err)
+	r.Zero(report.Imported)
+	r.Equal(3, report.Total)
+	// отчёт содержит и ошибки проверки, и ошибки базы, по порядку строк
+	r.Len(report.Errors, 2)
+	r.Equal(2, report.Errors[0].Row)
+	r.Equal(3, report.Errors[1].Row)
+	r.Len(s.repo.banners, 1)
+}
+
+func (s *CacheTestSuite) TestImportDryRunSavesNothing() {
+	r := s.Require()
+	report, err := s.uc.ImportBanners(context.Background(), []models.ImportRow{importRow(1, `{"title": "first"}`, 2, 1)}, true)
+	r.NoError(err)
+	r.True(report.DryRun)
+	r.Zero(report.Imported)
+	r.Empty(report.Errors)
+	r.Len(s.repo.banners, 1)
+}
+
+// importUsecaseStub records what the import handler passes on and accepts every row, the rest panic
+type importUsecaseStub struct {
+	banner.BannerUsecase
+	rows   []models.ImportRow
+	dryRun bool
+}
+
+func (uc *importUsecaseStub) ImportBanners(ctx context.Context, rows []models.ImportRow, dryRun bool) (models.ImportReport, error) {
+	uc.rows, uc.dryRun = rows, dryRun
+	report := models.ImportReport{DryRun: dryRun, Total: len(rows), Errors: make([]models.ImportRowError, 0)}
+	if !dryRun {
+		report.Imported = len(rows)
+	}
+	return report, nil
+}
+
+type ImportTestSuite struct {
+	suite.Suite
+
+	uc *importUsecaseStub
+}
+
+func TestImportSuite(t *testing.T) {
+	suite.Run(t, new(ImportTestSuite))
+}
+
+func (s *ImportTestSuite) SetupTest() {
+	s.uc = &importUsecaseStub{}
+}
+
+func (s *ImportTestSuite) post(query string, contentType string, body string) (*httptest.ResponseRecorder, models.ImportReport) {
+	req := httptest.NewRequest(http.MethodPost, "/api/banner/import?"+query, strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	bannerHttp.NewBannerHandler(s.uc).ImportBanners(w, req)
+	var report models.ImportReport
+	if w.Code != http.StatusBadRequest {
+		s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &report))
+	}
+	return w, report
+}
+
+func (s *ImportTestSuite) TestNDJSON() {
+	r := s.Require()
+	w, report := s.post("", "application/x-ndjson", `{"content": {"title": "first"}, "feature_id": 1, "tag_ids": [1, 2], "is_active": true}
+
+{"content": {"title": "second"}, "feature_id": 2, "tag_ids": [3], "is_active": false, "weight": 5}
+`)
+	r.Equal(http.StatusCreated, w.Code)
+	r.Equal(2, report.Imported)
+	r.False(s.uc.dryRun)
+	r.Len(s.uc.rows, 2)
+	// пустые строки не считаются
+	r.Equal(2, s.uc.rows[1].Row)
+	r.Equal([]int64{1, 2}, s.uc.rows[0].Form.TagIds)
+	r.JSONEq(`{"title": "second"}`, string(s.uc.rows[1].Form.Content))
+	r.Equal(int64(5), s.uc.rows[1].Form.Weight)
+}
+
+func (s *ImportTestSuite) TestCSV() {
+	r := s.Require()
+	w, _ := s.post("format=csv", "text/plain", `content,feature_id,tag_ids,is_active,active_from,active_until,weight,impression_cap
+"{""title"": ""first""}",1,1;2,true,2024-04-01T00:00:00Z,,,3
+"{""title"": ""second""}",2,,false,,,2,
+`)
+	r.Equal(http.StatusCreated, w.Code)
+	r.Len(s.uc.rows, 2)
+	first := s.uc.rows[0].Form
+	r.JSONEq(`{"title": "first"}`, string(first.Content))
+	r.Equal([]int64{1, 2}, first.TagIds)
+	r.True(first.IsActive)
+	r.NotNil(first.ActiveFrom)
+	r.Nil(first.ActiveUntil)
+	r.Equal(int64(3), first.ImpressionCap)
+	second := s.uc.rows[1].Form
+	r.Empty(second.TagIds)
+	r.Equal(int64(2), second.Weight)
+}
+
+func (s *ImportTestSuite) TestCSVWithoutImpressionCap() {
+	r := s.Require()
+	w, _ := s.post("", "text/csv", `content,feature_id,tag_ids,is_active,active_from,active_until,weight
+"{""title"": ""first""}",1,1,true,,,
+`)
+	r.Equal(http.StatusCreated, w.Code)
+	r.Len(s.uc.rows, 1)
+}
+
+func (s *ImportTestSuite) TestCSVWrongHeader() {
+	r := s.Require()
+	w, _ := s.post("", "text/csv", "title,feature_id\n")
+	r.Equal(http.StatusBadRequest, w.Code)
+	r.Nil(s.uc.rows)
+}
+
+func (s *ImportTestSuite) TestBrokenRowBlocksImport() {
+	r := s.Require()
+	w, report := s.post("format=csv", "", `content,feature_id,tag_ids,is_active,active_from,active_until,weight,impression_cap
+"{""title"": ""first""}",1,1,true,,,,
+"{""title"": ""second""}",two,1,true,,,,
+`)
+	r.Equal(http.StatusUnprocessableEntity, w.Code)
+	// разобранные строки только проверяются
+	r.True(s.uc.dryRun)
+	r.False(report.DryRun)
+	r.Equal(2, report.Total)
+	r.Len(report.Errors, 1)
+	r.Equal(2, report.Errors[0].Row)
+}
+
+func (s *ImportTestSuite) TestDryRun() {
+	r := s.Require()
+	w, report := s.post("dry_run=true", "application/x-ndjson", `{"content": {"title": "first"}, "feature_id": 1, "tag_ids": [1], "is_active": true}`)
+	r.Equal(http.StatusOK, w.Code)
+	r.True(s.uc.dryRun)
+	r.True(report.DryRun)
+	r.Zero(report.Imported)
+}
+
+func (s *ImportTestSuite) TestWrongParams() {
+	r := s.Require()
+	w, _ := s.post("format=xml", "", "")
+	r.Equal(http.StatusBadRequest, w.Code)
+	w, _ = s.post("dry_run=maybe", "", "")
+	r.Equal(http.StatusBadRequest, w.Code)
+}
+
+func (s *APITestSuite) TestImportRollsBackOnDatabaseError() {
+	r := s.Require()
+	ctx := context.Background()
+	featureId, tagId, _ := s.addFeatureBanner()
+	var before int64
+	r.NoError(s.db.QueryRow(ctx, `SELECT count(*) FROM banner;`).Scan(&before))
+
+	report, err := s.uc.ImportBanners(ctx, []models.ImportRow{
+		importRow(1, `{"title": "imported"}`, featureId, tagId+1000000),
+		importRow(2, `{"title": "imported"}`, featureId+1000000, tagId),
+	}, false)
+	r.NoError(err)
+	r.Zero(report.Imported)
+	r.NotEmpty(report.Errors)
+
+	var after int64
+	r.NoError(s.db.QueryRow(ctx, `SELECT count(*) FROM banner;`).Scan(&after))
+	r.Equal(before, after)
+}
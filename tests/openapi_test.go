@@ -0,0 +1,247 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Alladan04/avito_test/api"
+	"github.com/Alladan04/avito_test/internal/pkg/middleware"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/suite"
+)
+
+const mainSource = "../cmd/main/main.go"
+
+type OpenAPITestSuite struct {
+	suite.Suite
+
+	validator *middleware.OpenAPIValidator
+	router    *mux.Router
+	body      []byte
+	called    bool
+}
+
+func TestOpenAPISuite(t *testing.T) {
+	suite.Run(t, new(OpenAPITestSuite))
+}
+
+func (s *OpenAPITestSuite) SetupTest() {
+	validator, err := middleware.NewOpenAPIValidator(api.OpenAPI)
+	s.Require().NoError(err)
+	s.validator = validator
+	s.called = false
+	s.body = nil
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.called = true
+		s.body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+	})
+	s.router = mux.NewRouter().PathPrefix("/api").Subrouter()
+	s.router.Use(validator.Middleware)
+	s.router.Handle("/user_banner", handler).Methods(http.MethodGet)
+	s.router.Handle("/banner", handler).Methods(http.MethodPost)
+	s.router.Handle("/banner/{id}/{action:submit|approve|reject|publish|archive}", handler).Methods(http.MethodPost)
+}
+
+func (s *OpenAPITestSuite) serve(method string, target string, body string) (*httptest.ResponseRecorder, middleware.ValidationErrorResponse) {
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+	var response middleware.ValidationErrorResponse
+	if w.Code == http.StatusBadRequest {
+		s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	}
+	return w, response
+}
+
+func (s *OpenAPITestSuite) TestEveryRouteIsDescribed() {
+	r := s.Require()
+	var spec struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	r.NoError(json.Unmarshal(api.OpenAPI, &spec))
+	routes := mainRoutes(s.T())
+	r.NotEmpty(routes)
+	for _, route := range routes {
+		path := middleware.SpecPath(route.path)
+		_, ok := spec.Paths[path][strings.ToLower(route.method)]
+		s.Truef(ok, "%s %s from cmd/main is missing in api/openapi.json", route.method, path)
+	}
+}
+
+func (s *OpenAPITestSuite) TestValidRequestPasses() {
+	r := s.Require()
+	body := `{"content": {"title": "sale"}, "feature_id": 1, "tag_ids": [1, 2], "active_until": null}`
+	w, _ := s.serve(http.MethodPost, "/api/banner", body)
+	r.Equal(http.StatusOK, w.Code)
+	r.True(s.called)
+	// хендлер читает то же тело, что проверил валидатор
+	r.JSONEq(body, string(s.body))
+
+	w, _ = s.serve(http.MethodGet, "/api/user_banner?feature_id=1&tag_id=2&use_last_revision=true", "")
+	r.Equal(http.StatusOK, w.Code)
+}
+
+func (s *OpenAPITestSuite) TestWrongQueryRejected() {
+	r := s.Require()
+	w, response := s.serve(http.MethodGet, "/api/user_banner?feature_id=abc", "")
+	r.Equal(http.StatusBadRequest, w.Code)
+	r.False(s.called)
+	r.ElementsMatch([]middleware.FieldError{
+		{In: "query", Name: "feature_id", Message: "must be an integer"},
+		{In: "query", Name: "tag_id", Message: "parameter is required"},
+	}, response.Errors)
+}
+
+func (s *OpenAPITestSuite) TestWrongBodyRejected() {
+	r := s.Require()
+	w, response := s.serve(http.MethodPost, "/api/banner", `{"content": "text", "feature_id": 1, "tag_ids": [1, "x"], "weight": -1}`)
+	r.Equal(http.StatusBadRequest, w.Code)
+	r.False(s.called)
+	names := make([]string, 0, len(response.Errors))
+	for _, item := range response.Errors {
+		r.Equal("body", item.In)
+		names = append(names, item.Name)
+	}
+	r.ElementsMatch([]string{"/content", "/tag_ids/1", "/weight"}, names)
+
+	w, response = s.serve(http.MethodPost, "/api/banner", "")
+	r.Equal(http.StatusBadRequest, w.Code)
+	r.Equal("request body is required", response.Errors[0].Message)
+}
+
+func (s *OpenAPITestSuite) TestPathParameters() {
+	w, response := s.serve(http.MethodPost, "/api/banner/abc/submit", "")
+	s.Equal(http.StatusBadRequest, w.Code)
+	s.Equal([]middleware.FieldError{{In: "path", Name: "id", Message: "must be an integer"}}, response.Errors)
+
+	w, _ = s.serve(http.MethodPost, "/api/banner/7/submit", "")
+	s.Equal(http.StatusOK, w.Code)
+}
+
+func (s *OpenAPITestSuite) TestServeSpec() {
+	w := httptest.NewRecorder()
+	s.validator.ServeSpec(w, httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil))
+	s.Equal(http.StatusOK, w.Code)
+	s.True(bytes.Equal(api.OpenAPI, w.Body.Bytes()))
+}
+
+type mainRoute struct {
+	method string
+	path   string
+}
+
+// mainRoutes collects routes registered in cmd/main as router.Handle(path, ...).Methods(...),
+// following the prefixes of routers made by PathPrefix(...).Subrouter()
+func mainRoutes(t *testing.T) []mainRoute {
+	file, err := parser.ParseFile(token.NewFileSet(), mainSource, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prefixes := make(map[string]string)
+	var routes []mainRoute
+	ast.Inspect(file, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.AssignStmt:
+			for i, lhs := range node.Lhs {
+				name, ok := lhs.(*ast.Ident)
+				if !ok || i >= len(node.Rhs) {
+					continue
+				}
+				if prefix, ok := routerPrefix(node.Rhs[i], prefixes); ok {
+					prefixes[name.Name] = prefix
+				}
+			}
+		case *ast.CallExpr:
+			methods, ok := selectorCall(node, "Methods")
+			if !ok {
+				return true
+			}
+			handle, ok := methods.X.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			handleSelector, ok := selectorCall(handle, "Handle")
+			if !ok || len(handle.Args) == 0 {
+				return true
+			}
+			router, ok := handleSelector.X.(*ast.Ident)
+			if !ok {
+				return true
+			}
+			prefix, ok := prefixes[router.Name]
+			if !ok {
+				return true
+			}
+			path, ok := stringLiteral(handle.Args[0])
+			if !ok {
+				t.Fatalf("route of %s is not a string literal", router.Name)
+			}
+			for _, arg := range node.Args {
+				method, ok := arg.(*ast.SelectorExpr)
+				if !ok {
+					continue
+				}
+				name := strings.ToUpper(strings.TrimPrefix(method.Sel.Name, "Method"))
+				if name != http.MethodOptions {
+					routes = append(routes, mainRoute{method: name, path: prefix + path})
+				}
+			}
+		}
+		return true
+	})
+	return routes
+}
+
+// routerPrefix returns the path prefix of the router the expression makes, if it makes one
+func routerPrefix(expr ast.Expr, prefixes map[string]string) (string, bool) {
+	switch expr := expr.(type) {
+	case *ast.Ident:
+		prefix, ok := prefixes[expr.Name]
+		return prefix, ok
+	case *ast.CallExpr:
+		selector, ok := expr.Fun.(*ast.SelectorExpr)
+		if !ok {
+			return "", false
+		}
+		switch selector.Sel.Name {
+		case "NewRouter":
+			return "", true
+		case "Subrouter":
+			return routerPrefix(selector.X, prefixes)
+		case "PathPrefix":
+			prefix, ok := routerPrefix(selector.X, prefixes)
+			if !ok || len(expr.Args) != 1 {
+				return "", false
+			}
+			path, ok := stringLiteral(expr.Args[0])
+			return prefix + path, ok
+		}
+	}
+	return "", false
+}
+
+func selectorCall(call *ast.CallExpr, name string) (*ast.SelectorExpr, bool) {
+	selector, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok || selector.Sel.Name != name {
+		return nil, false
+	}
+	return selector, true
+}
+
+func stringLiteral(expr ast.Expr) (string, bool) {
+	literal, ok := expr.(*ast.BasicLit)
+	if !ok || literal.Kind != token.STRING {
+		return "", false
+	}
+	value, err := strconv.Unquote(literal.Value)
+	return value, err == nil
+}
@@ -0,0 +1,70 @@
+package tests
+
+import (
+	"context"
+	"time"
+
+	"github.com/Alladan04/avito_test/internal/models"
+	bannerRepo "github.com/Alladan04/avito_test/internal/pkg/banner/repo"
+	workflowRepo "github.com/Alladan04/avito_test/internal/pkg/workflow/repo"
+	workflowUsecase "github.com/Alladan04/avito_test/internal/pkg/workflow/usecase"
+)
+
+// subscribe registers a webhook for every event, so changes of banners are queued in the outbox
+func (s *APITestSuite) subscribe() {
+	_, err := s.db.Exec(context.Background(), `INSERT INTO webhook (url, secret, events, create_time) VALUES ('http://localhost', 'secret', $1, CURRENT_TIMESTAMP);`, models.WebhookEvents)
+	s.Require().NoError(err)
+}
+
+// outboxEvents lists distinct events queued for the banner
+func (s *APITestSuite) outboxEvents(id int64) []string {
+	rows, err := s.db.Query(context.Background(), `SELECT DISTINCT event FROM webhook_outbox WHERE (payload->>'banner_id')::bigint = $1;`, id)
+	s.Require().NoError(err)
+	defer rows.Close()
+	result := make([]string, 0)
+	for rows.Next() {
+		var event string
+		s.Require().NoError(rows.Scan(&event))
+		result = append(result, event)
+	}
+	return result
+}
+
+func (s *APITestSuite) TestOutboxDeleteFilteredAndRestore() {
+	r := s.Require()
+	s.subscribe()
+	featureId, _, bannerId := s.addFeatureBanner()
+
+	_, deleted, err := s.repo.DeleteFiltered(context.Background(), featureId, 0, 10, "testadmin")
+	r.NoError(err)
+	r.Equal(int64(1), deleted)
+	r.ElementsMatch([]string{models.WebhookEventBannerDeleted}, s.outboxEvents(bannerId))
+
+	r.NoError(s.uc.RestoreBanner(context.Background(), bannerId))
+	r.ElementsMatch([]string{models.WebhookEventBannerDeleted, models.WebhookEventBannerCreated}, s.outboxEvents(bannerId))
+}
+
+func (s *APITestSuite) TestOutboxApplySchedule() {
+	r := s.Require()
+	s.subscribe()
+	_, _, bannerId := s.addFeatureBanner()
+	_, err := s.db.Exec(context.Background(), `UPDATE banner SET active_until=$1 WHERE id=$2;`, time.Now().UTC().Add(-time.Minute), bannerId)
+	r.NoError(err)
+
+	_, err = s.repo.ApplySchedule(context.Background(), time.Now().UTC())
+	r.NoError(err)
+
+	r.ElementsMatch([]string{models.WebhookEventBannerUpdated}, s.outboxEvents(bannerId))
+}
+
+func (s *APITestSuite) TestOutboxArchive() {
+	r := s.Require()
+	s.subscribe()
+	_, _, bannerId := s.addFeatureBanner()
+	uc := workflowUsecase.NewWorkflowUsecase(workflowRepo.NewWorkflowRepo(s.db, *s.conn), bannerRepo.NewCacheRepo(*s.redisdb))
+
+	_, err := uc.Apply(context.Background(), bannerId, models.WorkflowActionArchive)
+	r.NoError(err)
+
+	r.ElementsMatch([]string{models.WebhookEventBannerUpdated}, s.outboxEvents(bannerId))
+}
@@ -0,0 +1,66 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/Alladan04/avito_test/internal/models"
+	bannerHttp "github.com/Alladan04/avito_test/internal/pkg/banner/delivery/http"
+	"github.com/jackc/pgx/v4"
+)
+
+// addInactive adds a switched off banner of feature 3 on tag 1
+func (s *CacheTestSuite) addInactive() {
+	s.repo.banners[2] = models.BannerForm{Content: models.BannerContent(`{"title": "preview"}`), FeatureId: 3, TagIds: []int64{1}}
+}
+
+func (s *CacheTestSuite) TestAdminPreviewIsNotServedToUsers() {
+	r := s.Require()
+	s.addInactive()
+
+	result, err := s.uc.GetOne(asAdmin("admin"), 3, 1, false)
+	r.NoError(err)
+	r.Equal(int64(2), result.Id)
+	r.False(result.IsActive)
+	r.True(s.redis.Exists("admin:3:1"))
+
+	// админская выдача лежит под своим ключом и пользователю не достаётся
+	_, err = s.uc.GetOne(asUser("alice"), 3, 1, false)
+	r.ErrorIs(err, pgx.ErrNoRows)
+	r.False(s.redis.Exists("3:1"))
+}
+
+func (s *CacheTestSuite) TestUserNotFoundDoesNotHideAdminPreview() {
+	r := s.Require()
+	s.addInactive()
+
+	_, err := s.uc.GetOne(asUser("alice"), 3, 1, false)
+	r.ErrorIs(err, pgx.ErrNoRows)
+	_, err = s.uc.GetOne(asUser("alice"), 3, 1, false)
+	r.ErrorIs(err, pgx.ErrNoRows)
+
+	result, err := s.uc.GetOne(asAdmin("admin"), 3, 1, false)
+	r.NoError(err)
+	r.Equal(int64(2), result.Id)
+}
+
+func (s *CacheTestSuite) TestAdminPreviewIsMarkedInactive() {
+	r := s.Require()
+	s.addInactive()
+	h := bannerHttp.NewBannerHandler(s.uc)
+
+	serve := func(ctx context.Context, query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.GetOne(w, httptest.NewRequest(http.MethodGet, "/api/user_banner?"+query, nil).WithContext(ctx))
+		return w
+	}
+	w := serve(asAdmin("admin"), "feature_id=3&tag_id=1")
+	r.Equal(http.StatusOK, w.Code)
+	r.Equal("true", w.Header().Get(bannerHttp.InactiveHeader))
+	r.Equal(http.StatusNotFound, serve(asUser("alice"), "feature_id=3&tag_id=1").Code)
+
+	w = serve(asAdmin("admin"), "feature_id=1&tag_id=1")
+	r.Equal(http.StatusOK, w.Code)
+	r.Empty(w.Header().Get(bannerHttp.InactiveHeader))
+}
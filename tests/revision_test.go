@@ -0,0 +1,123 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/Alladan04/avito_test/internal/models"
+	"github.com/Alladan04/avito_test/internal/pkg/banner"
+	bannerHttp "github.com/Alladan04/avito_test/internal/pkg/banner/delivery/http"
+	"github.com/gorilla/mux"
+)
+
+// restoreUsecaseStub fails RestoreRevision with err, the rest panic
+type restoreUsecaseStub struct {
+	banner.BannerUsecase
+	err error
+}
+
+func (uc *restoreUsecaseStub) RestoreRevision(ctx context.Context, id int64, revision int64) error {
+	return uc.err
+}
+
+func (s *CacheTestSuite) TestRestoreRevisionEvictsOldAndNewPairs() {
+	r := s.Require()
+	s.repo.revisions = map[int64][]models.BannerRevision{
+		1: {{BannerId: 1, Revision: 1, Content: models.BannerContent(`{"title": "first"}`), FeatureId: 2, TagIds: []int64{3}, IsActive: true}},
+	}
+	r.JSONEq(`{"title": "old"}`, string(s.cached(1, 1).Content))
+	s.missing(2, 3)
+
+	r.NoError(s.uc.RestoreRevision(context.Background(), 1, 1))
+
+	// баннер ушёл с пары 1:1 и вернулся на 2:3
+	_, err := s.uc.GetOne(context.Background(), 1, 1, false)
+	r.Error(err)
+	result, err := s.uc.GetOne(context.Background(), 2, 3, false)
+	r.NoError(err)
+	r.JSONEq(`{"title": "first"}`, string(result.Content))
+}
+
+func (s *CacheTestSuite) TestRestoreMissingRevision() {
+	r := s.Require()
+	r.ErrorIs(s.uc.RestoreRevision(context.Background(), 1, 5), banner.ErrRevisionNotFound)
+	r.JSONEq(`{"title": "old"}`, string(s.repo.banners[1].Content))
+}
+
+func (s *CacheTestSuite) TestRestoredRevisionIsCheckedAgainstSchema() {
+	r := s.Require()
+	s.repo.revisions = map[int64][]models.BannerRevision{
+		1: {{BannerId: 1, Revision: 1, Content: models.BannerContent(`{"text": "no title"}`), FeatureId: 1, TagIds: []int64{1}, IsActive: true}},
+	}
+	// схему фичи задали уже после ревизии
+	s.repo.schemas = map[int64][]byte{1: []byte(titleSchema)}
+
+	err := s.uc.RestoreRevision(context.Background(), 1, 1)
+	r.ErrorIs(err, banner.ErrContentMismatch)
+	r.JSONEq(`{"title": "old"}`, string(s.repo.banners[1].Content))
+}
+
+func (s *CacheTestSuite) TestRestoreRevisionStatusCodes() {
+	r := s.Require()
+	for err, code := range map[error]int{
+		nil:                        http.StatusOK,
+		banner.ErrRevisionNotFound: http.StatusNotFound,
+		banner.ErrBannerNotFound:   http.StatusNotFound,
+		banner.ErrContentMismatch:  http.StatusBadRequest,
+		banner.ErrInvalidWindow:    http.StatusBadRequest,
+		banner.ErrBannerConflict:   http.StatusConflict,
+	} {
+		router := mux.NewRouter()
+		router.HandleFunc("/api/banner/{id}/versions/{revision}/restore", bannerHttp.NewBannerHandler(&restoreUsecaseStub{err: err}).RestoreRevision)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/banner/1/versions/1/restore", nil))
+		r.Equalf(code, w.Code, "%v", err)
+	}
+}
+
+func (s *APITestSuite) TestRestoreRevisionOfTrashedBanner() {
+	r := s.Require()
+	ctx := asAdmin("admin")
+	var tagId int64
+	r.NoError(s.db.QueryRow(ctx, `INSERT INTO tag (id) VALUES (DEFAULT) RETURNING id;`).Scan(&tagId))
+	item, err := s.uc.AddItem(ctx, models.BannerForm{Content: models.BannerContent(`{"title": "first"}`), FeatureId: 1, TagIds: []int64{tagId}, IsActive: true})
+	r.NoError(err)
+	r.NoError(s.uc.DeleteBanner(ctx, item.Id, nil))
+
+	// ревизии баннера в корзине остаются, но откатить его можно только после восстановления
+	r.ErrorIs(s.uc.RestoreRevision(ctx, item.Id, 1), banner.ErrBannerNotFound)
+}
+
+func (s *APITestSuite) TestRestoreRevisionAddsRevision() {
+	r := s.Require()
+	ctx := asAdmin("admin")
+	var tagId int64
+	r.NoError(s.db.QueryRow(ctx, `INSERT INTO tag (id) VALUES (DEFAULT) RETURNING id;`).Scan(&tagId))
+	item, err := s.uc.AddItem(ctx, models.BannerForm{Content: models.BannerContent(`{"title": "first"}`), FeatureId: 1, TagIds: []int64{tagId}, IsActive: true})
+	r.NoError(err)
+	content := models.BannerContent(`{"title": "second"}`)
+	_, err = s.uc.UpdateBanner(ctx, models.BannerUpdateForm{Content: &content}, item.Id, nil)
+	r.NoError(err)
+
+	r.NoError(s.uc.RestoreRevision(asAdmin("other"), item.Id, 1))
+
+	current, err := s.uc.GetBanner(ctx, item.Id)
+	r.NoError(err)
+	r.JSONEq(`{"title": "first"}`, string(current.Content))
+	// откат не переписывает историю, а добавляет ревизию
+	revisions, err := s.uc.GetRevisions(ctx, item.Id)
+	r.NoError(err)
+	r.Len(revisions, 3)
+	var last models.BannerRevision
+	for _, revision := range revisions {
+		if revision.Revision > last.Revision {
+			last = revision
+		}
+	}
+	r.Equal(int64(3), last.Revision)
+	r.JSONEq(`{"title": "first"}`, string(last.Content))
+	r.Equal("other", last.Author)
+
+	r.ErrorIs(s.uc.RestoreRevision(ctx, item.Id, 10), banner.ErrRevisionNotFound)
+}
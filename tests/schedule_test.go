@@ -0,0 +1,136 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Alladan04/avito_test/internal/models"
+	"github.com/Alladan04/avito_test/internal/pkg/banner"
+	bannerRepo "github.com/Alladan04/avito_test/internal/pkg/banner/repo"
+	bannerWorker "github.com/Alladan04/avito_test/internal/pkg/banner/worker"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/suite"
+)
+
+// scheduleRepoStub switches a fixed set of pairs once
+type scheduleRepoStub struct {
+	banner.BannerRepo
+	keys  []models.FeatureTag
+	calls chan time.Time
+}
+
+func (r *scheduleRepoStub) ApplySchedule(ctx context.Context, now time.Time) ([]models.FeatureTag, error) {
+	r.calls <- now
+	keys := r.keys
+	r.keys = nil
+	return keys, nil
+}
+
+// evictionsStub passes evicted pairs to the test as they come
+type evictionsStub struct {
+	cacheStub
+	evicted chan []models.FeatureTag
+}
+
+func (c *evictionsStub) DeleteBanners(ctx context.Context, keys []models.FeatureTag) error {
+	c.evicted <- keys
+	return nil
+}
+
+type ScheduleTestSuite struct {
+	suite.Suite
+}
+
+func TestScheduleSuite(t *testing.T) {
+	suite.Run(t, new(ScheduleTestSuite))
+}
+
+func (s *ScheduleTestSuite) TestWorkerEvictsSwitchedPairs() {
+	r := s.Require()
+	repo := &scheduleRepoStub{keys: []models.FeatureTag{{FeatureId: 1, TagId: 2}}, calls: make(chan time.Time, 1)}
+	cache := &evictionsStub{evicted: make(chan []models.FeatureTag, 1)}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go bannerWorker.NewScheduleWorker(repo, cache).Run(ctx)
+
+	// расписание применяется сразу при старте, не дожидаясь первого тика
+	select {
+	case now := <-repo.calls:
+		r.WithinDuration(time.Now().UTC(), now, time.Second)
+	case <-time.After(time.Second):
+		r.Fail("schedule was not applied on start")
+	}
+	select {
+	case keys := <-cache.evicted:
+		r.Equal([]models.FeatureTag{{FeatureId: 1, TagId: 2}}, keys)
+	case <-time.After(time.Second):
+		r.Fail("switched pairs were not evicted")
+	}
+}
+
+func (s *ScheduleTestSuite) TestCachedBannerExpiresWithWindow() {
+	r := s.Require()
+	client := redis.NewClient(&redis.Options{Addr: miniredis.RunT(s.T()).Addr()})
+	cache := bannerRepo.NewCacheRepo(*client)
+	until := time.Now().UTC().Add(50 * time.Millisecond)
+	content := models.BannerContent(`{"title": "sale"}`)
+	r.NoError(cache.AddBanners(context.Background(), 1, 1, false, []models.UserBanner{{Id: 1, Content: content, Hash: content.Hash(), IsActive: true, ActiveUntil: &until}}))
+
+	_, err := cache.GetBanners(context.Background(), 1, 1, false)
+	r.NoError(err)
+	// кеш не отдаёт баннер после конца окна, даже если воркер ещё не успел его выключить
+	r.Eventually(func() bool {
+		_, err := cache.GetBanners(context.Background(), 1, 1, false)
+		return err != nil
+	}, time.Second, 10*time.Millisecond)
+}
+
+// addScheduledBanner inserts a published banner of feature 1 on a new tag with the given window and flag
+func (s *APITestSuite) addScheduledBanner(from *time.Time, until *time.Time, active bool) (int64, int64) {
+	r := s.Require()
+	var tagId, id int64
+	r.NoError(s.db.QueryRow(context.Background(), `INSERT INTO tag (id) VALUES (DEFAULT) RETURNING id;`).Scan(&tagId))
+	r.NoError(s.db.QueryRow(context.Background(), `INSERT INTO banner (content, feature_id, create_time, update_time, is_active, active_from, active_until, status)
+		VALUES ('{"title": "scheduled"}', 1, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, $1, $2, $3, 'published') RETURNING id;`, active, from, until).Scan(&id))
+	_, err := s.db.Exec(context.Background(), `INSERT INTO banner_tag (banner_id, tag_id, feature_id) VALUES ($1, $2, 1);`, id, tagId)
+	r.NoError(err)
+	return id, tagId
+}
+
+func (s *APITestSuite) bannerActive(id int64) bool {
+	var active bool
+	s.Require().NoError(s.db.QueryRow(context.Background(), `SELECT is_active FROM banner WHERE id=$1;`, id).Scan(&active))
+	return active
+}
+
+func (s *APITestSuite) TestApplyScheduleFollowsWindow() {
+	r := s.Require()
+	now := time.Now().UTC()
+	past, future := now.Add(-time.Hour), now.Add(time.Hour)
+	started, startedTag := s.addScheduledBanner(&past, &future, false)
+	ended, endedTag := s.addScheduledBanner(&past, &past, true)
+	pending, _ := s.addScheduledBanner(&future, nil, false)
+	manual, _ := s.addScheduledBanner(nil, nil, false)
+
+	keys, err := s.repo.ApplySchedule(context.Background(), now)
+	r.NoError(err)
+	r.Subset(keys, []models.FeatureTag{{FeatureId: 1, TagId: startedTag}, {FeatureId: 1, TagId: endedTag}})
+	r.True(s.bannerActive(started))
+	r.False(s.bannerActive(ended))
+	r.False(s.bannerActive(pending))
+	// без окна флаг принадлежит админу
+	r.False(s.bannerActive(manual))
+
+	keys, err = s.repo.ApplySchedule(context.Background(), now)
+	r.NoError(err)
+	r.NotContains(keys, models.FeatureTag{FeatureId: 1, TagId: startedTag})
+	r.NotContains(keys, models.FeatureTag{FeatureId: 1, TagId: endedTag})
+
+	// окно открылось - баннер включается
+	_, err = s.repo.ApplySchedule(context.Background(), future.Add(time.Minute))
+	r.NoError(err)
+	r.True(s.bannerActive(pending))
+	r.False(s.bannerActive(started))
+}
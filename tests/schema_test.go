@@ -0,0 +1,101 @@
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Alladan04/avito_test/internal/models"
+	"github.com/Alladan04/avito_test/internal/pkg/banner"
+	"github.com/Alladan04/avito_test/internal/pkg/feature"
+	featureUsecase "github.com/Alladan04/avito_test/internal/pkg/feature/usecase"
+	"github.com/stretchr/testify/suite"
+)
+
+const titleSchema = `{"type": "object", "required": ["title"], "properties": {"title": {"type": "string", "maxLength": 10}}}`
+
+func (s *CacheTestSuite) TestContentIsCheckedAgainstSchema() {
+	r := s.Require()
+	s.repo.schemas = map[int64][]byte{2: []byte(titleSchema)}
+
+	_, err := s.uc.AddItem(context.Background(), models.BannerForm{Content: models.BannerContent(`{"title": "sale"}`), FeatureId: 2, TagIds: []int64{1}})
+	r.NoError(err)
+
+	_, err = s.uc.AddItem(context.Background(), models.BannerForm{Content: models.BannerContent(`{"text": "no title"}`), FeatureId: 2, TagIds: []int64{2}})
+	r.ErrorIs(err, banner.ErrContentMismatch)
+	r.True(banner.IsValidationError(err))
+
+	// у фичи без схемы подходит любой объект
+	_, err = s.uc.AddItem(context.Background(), models.BannerForm{Content: models.BannerContent(`{"text": "no title"}`), FeatureId: 3, TagIds: []int64{2}})
+	r.NoError(err)
+}
+
+func (s *CacheTestSuite) TestUpdateIsCheckedAgainstSchema() {
+	r := s.Require()
+	s.repo.schemas = map[int64][]byte{1: []byte(titleSchema)}
+
+	content := models.BannerContent(`{"title": "far too long title"}`)
+	_, err := s.uc.UpdateBanner(context.Background(), models.BannerUpdateForm{Content: &content}, 1, nil)
+	r.ErrorIs(err, banner.ErrContentMismatch)
+	r.JSONEq(`{"title": "old"}`, string(s.repo.banners[1].Content))
+
+	// при переносе в фичу со схемой проверяется и старое содержимое
+	s.repo.schemas = map[int64][]byte{2: []byte(`{"type": "object", "required": ["url"]}`)}
+	featureId := int64(2)
+	_, err = s.uc.UpdateBanner(context.Background(), models.BannerUpdateForm{FeatureId: &featureId}, 1, nil)
+	r.ErrorIs(err, banner.ErrContentMismatch)
+}
+
+func (s *CacheTestSuite) TestContentMustBeObject() {
+	r := s.Require()
+	_, err := s.uc.AddItem(context.Background(), models.BannerForm{Content: models.BannerContent(`["title"]`), FeatureId: 2, TagIds: []int64{1}})
+	r.ErrorIs(err, banner.ErrInvalidContent)
+}
+
+// featureRepoStub stores content schemas set through the usecase, the rest panic
+type featureRepoStub struct {
+	feature.FeatureRepo
+	schemas map[int64][]byte
+}
+
+func (r *featureRepoStub) SetContentSchema(ctx context.Context, id int64, schema []byte, actor string) (models.Feature, error) {
+	r.schemas[id] = schema
+	return models.Feature{Id: id}, nil
+}
+
+type SchemaTestSuite struct {
+	suite.Suite
+
+	repo *featureRepoStub
+	uc   *featureUsecase.FeatureUsecase
+}
+
+func TestSchemaSuite(t *testing.T) {
+	suite.Run(t, new(SchemaTestSuite))
+}
+
+func (s *SchemaTestSuite) SetupTest() {
+	s.repo = &featureRepoStub{schemas: make(map[int64][]byte)}
+	s.uc = featureUsecase.NewFeatureUsecase(s.repo, &cacheStub{})
+}
+
+func (s *SchemaTestSuite) TestValidSchemaIsStored() {
+	r := s.Require()
+	_, err := s.uc.SetContentSchema(context.Background(), 1, []byte(titleSchema))
+	r.NoError(err)
+	r.JSONEq(titleSchema, string(s.repo.schemas[1]))
+}
+
+func (s *SchemaTestSuite) TestInvalidSchemaIsRejected() {
+	r := s.Require()
+	_, err := s.uc.SetContentSchema(context.Background(), 1, []byte(`{"type": "no such type"}`))
+	r.ErrorIs(err, feature.ErrInvalidSchema)
+	r.NotContains(s.repo.schemas, int64(1))
+}
+
+func (s *SchemaTestSuite) TestNullSchemaIsRemoved() {
+	r := s.Require()
+	s.repo.schemas[1] = []byte(titleSchema)
+	_, err := s.uc.SetContentSchema(context.Background(), 1, []byte(" null "))
+	r.NoError(err)
+	r.Nil(s.repo.schemas[1])
+}
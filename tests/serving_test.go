@@ -0,0 +1,295 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/Alladan04/avito_test/internal/models"
+	"github.com/Alladan04/avito_test/internal/pkg/banner"
+	bannerHttp "github.com/Alladan04/avito_test/internal/pkg/banner/delivery/http"
+	bannerRepo "github.com/Alladan04/avito_test/internal/pkg/banner/repo"
+	bannerUsecase "github.com/Alladan04/avito_test/internal/pkg/banner/usecase"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/jackc/pgx/v4"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/suite"
+)
+
+// pairRepoStub serves fixed banners of pairs and feature defaults the way BannerRepo does
+type pairRepoStub struct {
+	banner.BannerRepo
+	pairs     map[models.FeatureTag][]models.UserBanner
+	fallbacks map[int64]models.UserBanner
+	batches   int
+}
+
+func (r *pairRepoStub) pair(key models.FeatureTag) ([]models.UserBanner, bool) {
+	if banners, ok := r.pairs[key]; ok {
+		return banners, true
+	}
+	if item, ok := r.fallbacks[key.FeatureId]; ok {
+		item.Fallback = true
+		return []models.UserBanner{item}, true
+	}
+	return nil, false
+}
+
+func (r *pairRepoStub) GetOne(ctx context.Context, featureId int64, tagId int64, withInactive bool) ([]models.UserBanner, error) {
+	banners, ok := r.pair(models.FeatureTag{FeatureId: featureId, TagId: tagId})
+	if !ok {
+		return nil, pgx.ErrNoRows
+	}
+	return banners, nil
+}
+
+func (r *pairRepoStub) GetMany(ctx context.Context, keys []models.FeatureTag, withInactive bool) (map[models.FeatureTag][]models.UserBanner, error) {
+	r.batches++
+	result := make(map[models.FeatureTag][]models.UserBanner)
+	for _, key := range keys {
+		if banners, ok := r.pair(key); ok {
+			result[key] = banners
+		}
+	}
+	return result, nil
+}
+
+func (r *pairRepoStub) GetFallback(ctx context.Context, featureId int64, withInactive bool) (models.UserBanner, error) {
+	item, ok := r.fallbacks[featureId]
+	if !ok {
+		return models.UserBanner{}, pgx.ErrNoRows
+	}
+	item.Fallback = true
+	return item, nil
+}
+
+func servedBanner(id int64, weight int64, impressionCap int64) models.UserBanner {
+	content := models.BannerContent(`{"title": "banner"}`)
+	return models.UserBanner{Id: id, Content: content, Hash: content.Hash(), IsActive: true, Weight: weight, ImpressionCap: impressionCap}
+}
+
+func asUser(username string) context.Context {
+	return context.WithValue(context.Background(), models.PayloadContextKey, models.JwtPayload{Username: username})
+}
+
+type ServingTestSuite struct {
+	suite.Suite
+
+	redis    *miniredis.Miniredis
+	repo     *pairRepoStub
+	counters *counterStub
+	uc       *bannerUsecase.BannerUsecase
+}
+
+func TestServingSuite(t *testing.T) {
+	suite.Run(t, new(ServingTestSuite))
+}
+
+func (s *ServingTestSuite) SetupTest() {
+	s.redis = miniredis.RunT(s.T())
+	client := redis.NewClient(&redis.Options{Addr: s.redis.Addr()})
+	s.repo = &pairRepoStub{
+		pairs:     make(map[models.FeatureTag][]models.UserBanner),
+		fallbacks: make(map[int64]models.UserBanner),
+	}
+	s.counters = &counterStub{}
+	s.uc = bannerUsecase.NewBannerUsecase(s.repo, &cacheStub{}, nil, bannerRepo.NewImpressionRepo(*client), s.counters, nil)
+}
+
+func (s *ServingTestSuite) getMany(ctx context.Context, keys ...models.FeatureTag) map[string]models.UserBannerResult {
+	result, err := s.uc.GetMany(ctx, keys, false)
+	s.Require().NoError(err)
+	return result
+}
+
+func (s *ServingTestSuite) TestCappedVariantIsSkipped() {
+	r := s.Require()
+	s.repo.pairs[models.FeatureTag{FeatureId: 1, TagId: 1}] = []models.UserBanner{servedBanner(1, 1, 1), servedBanner(2, 1, 1)}
+
+	seen := make(map[int64]bool)
+	for i := 0; i < 2; i++ {
+		result, err := s.uc.GetOne(asUser("alice"), 1, 1, false)
+		r.NoError(err)
+		seen[result.Id] = true
+	}
+	r.Equal(map[int64]bool{1: true, 2: true}, seen)
+
+	_, err := s.uc.GetOne(asUser("alice"), 1, 1, false)
+	r.ErrorIs(err, pgx.ErrNoRows)
+	// ограничение считается для каждого пользователя отдельно
+	_, err = s.uc.GetOne(asUser("bob"), 1, 1, false)
+	r.NoError(err)
+}
+
+func (s *ServingTestSuite) TestGetManyRespectsCap() {
+	r := s.Require()
+	key := models.FeatureTag{FeatureId: 1, TagId: 1}
+	s.repo.pairs[key] = []models.UserBanner{servedBanner(1, 0, 2)}
+
+	// показ через GetOne тоже идёт в счёт ограничения
+	_, err := s.uc.GetOne(asUser("alice"), 1, 1, false)
+	r.NoError(err)
+	r.True(s.getMany(asUser("alice"), key)[key.String()].Found)
+	r.False(s.getMany(asUser("alice"), key)[key.String()].Found)
+}
+
+func (s *ServingTestSuite) TestGetManyCappedBannerFallsBackToDefault() {
+	r := s.Require()
+	key := models.FeatureTag{FeatureId: 1, TagId: 2}
+	s.repo.pairs[key] = []models.UserBanner{servedBanner(1, 0, 1)}
+	s.repo.fallbacks[1] = servedBanner(2, 0, 0)
+
+	r.False(s.getMany(asUser("alice"), key)[key.String()].Fallback)
+	result := s.getMany(asUser("alice"), key)[key.String()]
+	r.True(result.Found)
+	r.True(result.Fallback)
+}
+
+func (s *ServingTestSuite) TestAdminIgnoresCap() {
+	r := s.Require()
+	key := models.FeatureTag{FeatureId: 1, TagId: 1}
+	s.repo.pairs[key] = []models.UserBanner{servedBanner(1, 0, 1)}
+
+	for i := 0; i < 3; i++ {
+		r.True(s.getMany(asAdmin("admin"), key)[key.String()].Found)
+	}
+}
+
+func (s *ServingTestSuite) TestGetManyCountsImpressions() {
+	r := s.Require()
+	s.repo.pairs[models.FeatureTag{FeatureId: 1, TagId: 1}] = []models.UserBanner{servedBanner(1, 0, 0)}
+	s.repo.pairs[models.FeatureTag{FeatureId: 1, TagId: 2}] = []models.UserBanner{servedBanner(2, 0, 0)}
+
+	s.getMany(asUser("alice"), models.FeatureTag{FeatureId: 1, TagId: 1}, models.FeatureTag{FeatureId: 1, TagId: 2}, models.FeatureTag{FeatureId: 1, TagId: 3})
+
+	r.ElementsMatch([]int64{1, 2}, s.counters.impressions)
+}
+
+// userBanner serves GET /api/user_banner for the user with the given If-None-Match
+func (s *ServingTestSuite) userBanner(username string, ifNoneMatch string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/api/user_banner?feature_id=1&tag_id=1", nil).WithContext(asUser(username))
+	if ifNoneMatch != "" {
+		req.Header.Set("If-None-Match", ifNoneMatch)
+	}
+	w := httptest.NewRecorder()
+	bannerHttp.NewBannerHandler(s.uc).GetOne(w, req)
+	return w
+}
+
+func (s *ServingTestSuite) TestUserBannerSendsETag() {
+	r := s.Require()
+	item := servedBanner(1, 0, 0)
+	s.repo.pairs[models.FeatureTag{FeatureId: 1, TagId: 1}] = []models.UserBanner{item}
+
+	w := s.userBanner("alice", "")
+	r.Equal(http.StatusOK, w.Code)
+	r.Equal(strconv.Quote(item.Hash), w.Header().Get("ETag"))
+
+	w = s.userBanner("alice", strconv.Quote(item.Hash))
+	r.Equal(http.StatusNotModified, w.Code)
+	r.Empty(w.Body.Bytes())
+
+	w = s.userBanner("alice", `"outdated"`)
+	r.Equal(http.StatusOK, w.Code)
+}
+
+func (s *ServingTestSuite) TestNotModifiedIsNotCounted() {
+	r := s.Require()
+	item := servedBanner(1, 0, 1)
+	s.repo.pairs[models.FeatureTag{FeatureId: 1, TagId: 1}] = []models.UserBanner{item}
+
+	for i := 0; i < 3; i++ {
+		r.Equal(http.StatusNotModified, s.userBanner("alice", strconv.Quote(item.Hash)).Code)
+	}
+	r.Empty(s.counters.impressions)
+
+	// ограничение в один показ не потрачено на ответы 304
+	r.Equal(http.StatusOK, s.userBanner("alice", "").Code)
+	r.Equal([]int64{1}, s.counters.impressions)
+	r.Equal(http.StatusNotFound, s.userBanner("alice", "").Code)
+}
+
+func (s *ServingTestSuite) TestVariantIsStickyForUser() {
+	r := s.Require()
+	s.repo.pairs[models.FeatureTag{FeatureId: 1, TagId: 1}] = []models.UserBanner{servedBanner(1, 1, 0), servedBanner(2, 1, 0)}
+
+	first, err := s.uc.GetOne(asUser("alice"), 1, 1, false)
+	r.NoError(err)
+	for i := 0; i < 5; i++ {
+		result, err := s.uc.GetOne(asUser("alice"), 1, 1, false)
+		r.NoError(err)
+		r.Equal(first.Id, result.Id)
+	}
+}
+
+func (s *ServingTestSuite) TestVariantsFollowWeights() {
+	r := s.Require()
+	s.repo.pairs[models.FeatureTag{FeatureId: 1, TagId: 1}] = []models.UserBanner{servedBanner(1, 1, 0), servedBanner(2, 3, 0)}
+
+	seen := make(map[int64]int)
+	const users = 2000
+	for i := 0; i < users; i++ {
+		result, err := s.uc.GetOne(asUser(fmt.Sprintf("user%d", i)), 1, 1, false)
+		r.NoError(err)
+		seen[result.Id]++
+	}
+	r.InDelta(users/4, seen[1], users/20)
+	r.InDelta(users*3/4, seen[2], users/20)
+}
+
+func (s *ServingTestSuite) TestVariantHeaderIsSent() {
+	r := s.Require()
+	s.repo.pairs[models.FeatureTag{FeatureId: 1, TagId: 1}] = []models.UserBanner{servedBanner(7, 1, 0)}
+
+	w := s.userBanner("alice", "")
+	r.Equal(http.StatusOK, w.Code)
+	r.Equal("7", w.Header().Get(bannerHttp.VariantHeader))
+}
+
+func (s *ServingTestSuite) TestPairWithoutBannerGetsDefault() {
+	r := s.Require()
+	s.repo.pairs[models.FeatureTag{FeatureId: 1, TagId: 1}] = []models.UserBanner{servedBanner(1, 0, 0)}
+	s.repo.fallbacks[1] = servedBanner(2, 0, 0)
+
+	result, err := s.uc.GetOne(asUser("alice"), 1, 1, false)
+	r.NoError(err)
+	r.Equal(int64(1), result.Id)
+	r.False(result.Fallback)
+
+	result, err = s.uc.GetOne(asUser("alice"), 1, 2, false)
+	r.NoError(err)
+	r.Equal(int64(2), result.Id)
+	r.True(result.Fallback)
+
+	// у другой фичи баннера по умолчанию нет
+	_, err = s.uc.GetOne(asUser("alice"), 2, 2, false)
+	r.ErrorIs(err, pgx.ErrNoRows)
+}
+
+func (s *ServingTestSuite) TestMatchHeader() {
+	r := s.Require()
+	s.repo.fallbacks[1] = servedBanner(2, 0, 0)
+
+	w := s.userBanner("alice", "")
+	r.Equal(http.StatusOK, w.Code)
+	r.Equal("fallback", w.Header().Get(bannerHttp.MatchHeader))
+
+	s.repo.pairs[models.FeatureTag{FeatureId: 1, TagId: 1}] = []models.UserBanner{servedBanner(1, 0, 0)}
+	w = s.userBanner("alice", "")
+	r.Equal("direct", w.Header().Get(bannerHttp.MatchHeader))
+}
+
+func (s *ServingTestSuite) TestGetManyMarksDefault() {
+	r := s.Require()
+	s.repo.pairs[models.FeatureTag{FeatureId: 1, TagId: 1}] = []models.UserBanner{servedBanner(1, 0, 0)}
+	s.repo.fallbacks[1] = servedBanner(2, 0, 0)
+
+	result := s.getMany(asUser("alice"), models.FeatureTag{FeatureId: 1, TagId: 1}, models.FeatureTag{FeatureId: 1, TagId: 2}, models.FeatureTag{FeatureId: 2, TagId: 1})
+	r.False(result["1:1"].Fallback)
+	r.True(result["1:2"].Found)
+	r.True(result["1:2"].Fallback)
+	r.False(result["2:1"].Found)
+}
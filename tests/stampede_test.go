@@ -0,0 +1,126 @@
+package tests
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Alladan04/avito_test/internal/models"
+	"github.com/Alladan04/avito_test/internal/pkg/banner"
+	bannerRepo "github.com/Alladan04/avito_test/internal/pkg/banner/repo"
+	bannerUsecase "github.com/Alladan04/avito_test/internal/pkg/banner/usecase"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/suite"
+)
+
+// slowBannerRepo answers GetOne after a delay and counts the queries, like a database under load
+type slowBannerRepo struct {
+	banner.BannerRepo
+	delay   time.Duration
+	queries atomic.Int64
+}
+
+func (r *slowBannerRepo) GetOne(ctx context.Context, featureId int64, tagId int64, withInactive bool) ([]models.UserBanner, error) {
+	r.queries.Add(1)
+	time.Sleep(r.delay)
+	content := models.BannerContent(`{"title": "hot"}`)
+	return []models.UserBanner{{Id: 1, Content: content, Hash: content.Hash(), IsActive: true}}, nil
+}
+
+type StampedeTestSuite struct {
+	suite.Suite
+
+	redis  *miniredis.Miniredis
+	client *redis.Client
+	repo   *slowBannerRepo
+}
+
+func TestStampedeSuite(t *testing.T) {
+	suite.Run(t, new(StampedeTestSuite))
+}
+
+func (s *StampedeTestSuite) SetupTest() {
+	s.redis = miniredis.RunT(s.T())
+	s.client = redis.NewClient(&redis.Options{Addr: s.redis.Addr()})
+	s.repo = &slowBannerRepo{delay: 50 * time.Millisecond}
+}
+
+// newReplica builds the usecase of one replica, replicas share redis and the database
+func (s *StampedeTestSuite) newReplica(withLock bool) *bannerUsecase.BannerUsecase {
+	var lock banner.LockRepo
+	if withLock {
+		lock = bannerRepo.NewLockRepo(*s.client)
+	}
+	return bannerUsecase.NewBannerUsecase(s.repo, bannerRepo.NewCacheRepo(*s.client), nil, nil, &counterStub{}, lock)
+}
+
+// burst sends requests concurrent requests for the hot pair to every replica at once
+func (s *StampedeTestSuite) burst(replicas []*bannerUsecase.BannerUsecase, requests int) {
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	var failed atomic.Int64
+	for _, uc := range replicas {
+		for i := 0; i < requests; i++ {
+			wg.Add(1)
+			go func(uc *bannerUsecase.BannerUsecase) {
+				defer wg.Done()
+				<-start
+				result, err := uc.GetOne(context.Background(), 1, 1, false)
+				if err != nil || result.Id != 1 {
+					failed.Add(1)
+				}
+			}(uc)
+		}
+	}
+	close(start)
+	wg.Wait()
+	s.Require().Zero(failed.Load())
+}
+
+func (s *StampedeTestSuite) TestOneQueryPerExpiry() {
+	uc := s.newReplica(false)
+	for expiry := int64(1); expiry <= 3; expiry++ {
+		s.burst([]*bannerUsecase.BannerUsecase{uc}, 200)
+		s.Equal(expiry, s.repo.queries.Load())
+		s.redis.FastForward(bannerRepo.DefaultExpTime)
+	}
+}
+
+func (s *StampedeTestSuite) TestLockSharesRebuildBetweenReplicas() {
+	replicas := []*bannerUsecase.BannerUsecase{s.newReplica(true), s.newReplica(true), s.newReplica(true)}
+	for expiry := int64(1); expiry <= 3; expiry++ {
+		s.burst(replicas, 100)
+		s.Equal(expiry, s.repo.queries.Load())
+		s.redis.FastForward(bannerRepo.DefaultExpTime)
+	}
+	// после пересборки блокировка отпущена
+	s.False(s.redis.Exists("lock:1:1"))
+}
+
+func (s *StampedeTestSuite) TestReplicasWithoutLockQueryOnce() {
+	replicas := []*bannerUsecase.BannerUsecase{s.newReplica(false), s.newReplica(false), s.newReplica(false)}
+	s.burst(replicas, 100)
+	s.LessOrEqual(s.repo.queries.Load(), int64(len(replicas)))
+}
+
+func (s *StampedeTestSuite) TestCancelledCallerDoesNotFailOthers() {
+	r := s.Require()
+	uc := s.newReplica(false)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		_, err := uc.GetOne(ctx, 1, 1, false)
+		done <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	r.ErrorIs(<-done, context.Canceled)
+
+	result, err := uc.GetOne(context.Background(), 1, 1, false)
+	r.NoError(err)
+	r.Equal(int64(1), result.Id)
+	r.Equal(int64(1), s.repo.queries.Load())
+}
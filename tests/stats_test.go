@@ -0,0 +1,160 @@
+package tests
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Alladan04/avito_test/internal/models"
+	"github.com/Alladan04/avito_test/internal/pkg/banner"
+	"github.com/Alladan04/avito_test/internal/pkg/stats"
+	statsUsecase "github.com/Alladan04/avito_test/internal/pkg/stats/usecase"
+	"github.com/jackc/pgx/v4"
+	"github.com/stretchr/testify/suite"
+)
+
+type statsRepoStub struct {
+	stats   []models.BannerStats
+	content map[int64]models.BannerContent
+}
+
+func (r *statsRepoStub) AddStats(ctx context.Context, counters []models.BannerStats) error {
+	r.stats = append(r.stats, counters...)
+	return nil
+}
+
+func (r *statsRepoStub) GetStats(ctx context.Context, bannerId int64, from time.Time, to time.Time) ([]models.BannerStats, error) {
+	result := make([]models.BannerStats, 0)
+	for _, item := range r.stats {
+		if item.BannerId == bannerId && !item.Day.Before(from) && !item.Day.After(to) {
+			result = append(result, item)
+		}
+	}
+	return result, nil
+}
+
+func (r *statsRepoStub) GetBannerContent(ctx context.Context, bannerId int64) (models.BannerContent, error) {
+	content, ok := r.content[bannerId]
+	if !ok {
+		return nil, pgx.ErrNoRows
+	}
+	return content, nil
+}
+
+func (r *statsRepoStub) BannerExists(ctx context.Context, bannerId int64) (bool, error) {
+	_, ok := r.content[bannerId]
+	return ok, nil
+}
+
+type counterStub struct {
+	mu          sync.Mutex
+	impressions []int64
+	clicks      []int64
+}
+
+func (c *counterStub) AddImpression(ctx context.Context, bannerId int64, at time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.impressions = append(c.impressions, bannerId)
+	return nil
+}
+
+func (c *counterStub) AddClick(ctx context.Context, bannerId int64, at time.Time) error {
+	c.clicks = append(c.clicks, bannerId)
+	return nil
+}
+
+func (c *counterStub) TakeCounters(ctx context.Context) (string, []models.BannerStats, error) {
+	return "", nil, nil
+}
+
+func (c *counterStub) AckCounters(ctx context.Context, batch string) error {
+	return nil
+}
+
+func (c *counterStub) RestoreCounters(ctx context.Context, batch string, counters []models.BannerStats) error {
+	return nil
+}
+
+func (c *counterStub) RecoverCounters(ctx context.Context, olderThan time.Duration) (int, error) {
+	return 0, nil
+}
+
+type StatsTestSuite struct {
+	suite.Suite
+
+	repo     *statsRepoStub
+	counters *counterStub
+	uc       *statsUsecase.StatsUsecase
+}
+
+func TestStatsSuite(t *testing.T) {
+	suite.Run(t, new(StatsTestSuite))
+}
+
+func (s *StatsTestSuite) SetupTest() {
+	s.repo = &statsRepoStub{content: map[int64]models.BannerContent{
+		1: models.BannerContent(`{"url": "https://example.com/promo"}`),
+		2: models.BannerContent(`{"title": "no link"}`),
+		3: models.BannerContent(`{"url": "javascript:alert(1)"}`),
+	}}
+	s.counters = &counterStub{}
+	s.uc = statsUsecase.NewStatsUsecase(s.repo, s.counters)
+}
+
+func day(value string) time.Time {
+	result, _ := time.Parse(time.DateOnly, value)
+	return result
+}
+
+func (s *StatsTestSuite) TestClickRedirectsToContentUrl() {
+	r := s.Require()
+
+	target, err := s.uc.Click(context.Background(), 1)
+	r.NoError(err)
+	r.Equal("https://example.com/promo", target)
+	r.Equal([]int64{1}, s.counters.clicks)
+}
+
+func (s *StatsTestSuite) TestClickWithoutUrl() {
+	r := s.Require()
+
+	_, err := s.uc.Click(context.Background(), 2)
+	r.ErrorIs(err, stats.ErrNoUrl)
+	_, err = s.uc.Click(context.Background(), 3)
+	r.ErrorIs(err, stats.ErrNoUrl)
+	_, err = s.uc.Click(context.Background(), 42)
+	r.ErrorIs(err, banner.ErrBannerNotFound)
+	r.Empty(s.counters.clicks)
+}
+
+func (s *StatsTestSuite) TestStatsPerDay() {
+	r := s.Require()
+	s.repo.stats = []models.BannerStats{
+		{BannerId: 1, Day: day("2024-04-01"), Impressions: 100, Clicks: 5},
+		{BannerId: 1, Day: day("2024-04-03"), Impressions: 50, Clicks: 10},
+		{BannerId: 2, Day: day("2024-04-02"), Impressions: 7},
+	}
+	from, to := day("2024-04-01"), day("2024-04-03")
+
+	result, err := s.uc.GetStats(context.Background(), 1, &from, &to)
+	r.NoError(err)
+	r.Len(result.Days, 3)
+	r.Equal(models.BannerDayStats{Day: "2024-04-01", Impressions: 100, Clicks: 5, CTR: 0.05}, result.Days[0])
+	r.Equal(models.BannerDayStats{Day: "2024-04-02"}, result.Days[1])
+	r.Equal(0.2, result.Days[2].CTR)
+	r.Equal(int64(150), result.Impressions)
+	r.Equal(int64(15), result.Clicks)
+	r.Equal(0.1, result.CTR)
+}
+
+func (s *StatsTestSuite) TestStatsInvalidPeriod() {
+	r := s.Require()
+	from, to := day("2024-04-03"), day("2024-04-01")
+
+	_, err := s.uc.GetStats(context.Background(), 1, &from, &to)
+	r.ErrorIs(err, stats.ErrInvalidPeriod)
+	_, err = s.uc.GetStats(context.Background(), 42, nil, nil)
+	r.ErrorIs(err, banner.ErrBannerNotFound)
+}
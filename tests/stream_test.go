@@ -0,0 +1,107 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Alladan04/avito_test/internal/models"
+	"github.com/Alladan04/avito_test/internal/pkg/banner"
+	bannerHttp "github.com/Alladan04/avito_test/internal/pkg/banner/delivery/http"
+	bannerUsecase "github.com/Alladan04/avito_test/internal/pkg/banner/usecase"
+	"github.com/stretchr/testify/suite"
+)
+
+// streamUsecaseStub serves the current banner and reports picks and counted impressions, the rest panic
+type streamUsecaseStub struct {
+	banner.BannerUsecase
+	mu      sync.Mutex
+	current models.UserBanner
+	picked  chan string
+	counted chan string
+}
+
+func (uc *streamUsecaseStub) PickOne(ctx context.Context, featureId int64, tagId int64, showLastRevision bool) (models.UserBanner, error) {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	uc.picked <- uc.current.Hash
+	return uc.current, nil
+}
+
+func (uc *streamUsecaseStub) CountImpression(ctx context.Context, item models.UserBanner) {
+	uc.counted <- item.Hash
+}
+
+func (uc *streamUsecaseStub) set(hash string) {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	uc.current = models.UserBanner{Content: []byte(`{"title": "` + hash + `"}`), Hash: hash}
+}
+
+type StreamTestSuite struct {
+	suite.Suite
+
+	uc     *streamUsecaseStub
+	hub    *bannerUsecase.UpdatesHub
+	server *httptest.Server
+}
+
+func TestStreamSuite(t *testing.T) {
+	suite.Run(t, new(StreamTestSuite))
+}
+
+func (s *StreamTestSuite) SetupTest() {
+	s.uc = &streamUsecaseStub{picked: make(chan string, 10), counted: make(chan string, 10)}
+	s.uc.set("a")
+	s.hub = bannerUsecase.NewUpdatesHub(&cacheStub{})
+	s.server = httptest.NewServer(http.HandlerFunc(bannerHttp.NewStreamHandler(s.uc, s.hub).Stream))
+}
+
+func (s *StreamTestSuite) TearDownTest() {
+	s.server.CloseClientConnections()
+	s.server.Close()
+}
+
+// nextEvent reads the stream up to the data line of the next event
+func nextEvent(r *bufio.Reader) (string, error) {
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+		if strings.HasPrefix(line, "data: ") {
+			return strings.TrimSpace(strings.TrimPrefix(line, "data: ")), nil
+		}
+	}
+}
+
+func (s *StreamTestSuite) TestUnchangedBannerIsNotCounted() {
+	r := s.Require()
+	resp, err := http.Get(s.server.URL + "?feature_id=1&tag_id=2")
+	r.NoError(err)
+	defer resp.Body.Close()
+	events := bufio.NewReader(resp.Body)
+
+	data, err := nextEvent(events)
+	r.NoError(err)
+	r.JSONEq(`{"title": "a"}`, data)
+	r.Equal("a", <-s.uc.picked)
+	r.Equal("a", <-s.uc.counted)
+
+	// уведомление без изменений перечитывает баннер, но клиенту ничего не уходит и показ не считается
+	s.hub.Notify([]models.FeatureTag{{FeatureId: 1, TagId: 2}})
+	r.Equal("a", <-s.uc.picked)
+
+	s.uc.set("b")
+	s.hub.Notify([]models.FeatureTag{{FeatureId: 1, TagId: 2}})
+	data, err = nextEvent(events)
+	r.NoError(err)
+	r.JSONEq(`{"title": "b"}`, data)
+	r.Equal("b", <-s.uc.picked)
+	r.Equal("b", <-s.uc.counted)
+	r.Empty(s.uc.counted)
+}
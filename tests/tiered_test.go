@@ -0,0 +1,206 @@
+package tests
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Alladan04/avito_test/internal/models"
+	bannerRepo "github.com/Alladan04/avito_test/internal/pkg/banner/repo"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/suite"
+)
+
+type TieredCacheTestSuite struct {
+	suite.Suite
+
+	redis  *miniredis.Miniredis
+	client *redis.Client
+}
+
+func TestTieredCacheSuite(t *testing.T) {
+	suite.Run(t, new(TieredCacheTestSuite))
+}
+
+func (s *TieredCacheTestSuite) SetupTest() {
+	s.redis = miniredis.RunT(s.T())
+	s.client = redis.NewClient(&redis.Options{Addr: s.redis.Addr()})
+}
+
+func (s *TieredCacheTestSuite) newRepo(size int, ttl time.Duration) *bannerRepo.TieredCacheRepo {
+	return bannerRepo.NewTieredCacheRepo(bannerRepo.NewCacheRepo(*s.client), size, ttl)
+}
+
+func tieredBanners(id int64) []models.UserBanner {
+	content := models.BannerContent(`{"title": "banner"}`)
+	return []models.UserBanner{{Id: id, Content: content, Hash: content.Hash(), IsActive: true}}
+}
+
+func (s *TieredCacheTestSuite) TestLocalHitSkipsRedis() {
+	r := s.Require()
+	repo := s.newRepo(10, time.Minute)
+	r.NoError(repo.AddBanners(context.Background(), 1, 1, false, tieredBanners(1)))
+	// ключ пропал из redis, но локальная копия ещё жива
+	s.redis.Del("1:1")
+
+	result, err := repo.GetBanners(context.Background(), 1, 1, false)
+	r.NoError(err)
+	r.Equal(int64(1), result[0].Id)
+	stats := repo.Stats()
+	r.Equal(int64(1), stats.Local.Hits)
+	r.Equal(int64(0), stats.Redis.Hits+stats.Redis.Misses)
+}
+
+func (s *TieredCacheTestSuite) TestCapacityEvictsLeastRecentlyUsed() {
+	r := s.Require()
+	repo := s.newRepo(2, time.Minute)
+	r.NoError(repo.AddBanners(context.Background(), 1, 1, false, tieredBanners(1)))
+	r.NoError(repo.AddBanners(context.Background(), 1, 2, false, tieredBanners(2)))
+	_, err := repo.GetBanners(context.Background(), 1, 1, false)
+	r.NoError(err)
+	r.NoError(repo.AddBanners(context.Background(), 1, 3, false, tieredBanners(3)))
+
+	stats := repo.Stats()
+	r.Equal(int64(1), stats.Local.Evictions)
+	r.Equal(int64(2), stats.Local.Size)
+	// вытесненная пара читается из redis
+	result, err := repo.GetBanners(context.Background(), 1, 2, false)
+	r.NoError(err)
+	r.Equal(int64(2), result[0].Id)
+	r.Equal(int64(1), repo.Stats().Redis.Hits)
+}
+
+func (s *TieredCacheTestSuite) TestLocalEntriesExpire() {
+	r := s.Require()
+	repo := s.newRepo(10, 20*time.Millisecond)
+	r.NoError(repo.AddBanners(context.Background(), 1, 1, false, tieredBanners(1)))
+	time.Sleep(40 * time.Millisecond)
+
+	_, err := repo.GetBanners(context.Background(), 1, 1, false)
+	r.NoError(err)
+	stats := repo.Stats()
+	r.Equal(int64(1), stats.Local.Misses)
+	r.Equal(int64(1), stats.Redis.Hits)
+}
+
+func (s *TieredCacheTestSuite) TestLocalEntriesExpireWithBanner() {
+	r := s.Require()
+	repo := s.newRepo(10, time.Minute)
+	banners := tieredBanners(1)
+	activeUntil := time.Now().UTC().Add(20 * time.Millisecond)
+	banners[0].ActiveUntil = &activeUntil
+	r.NoError(repo.AddBanners(context.Background(), 1, 1, false, banners))
+	time.Sleep(40 * time.Millisecond)
+
+	_, _ = repo.GetBanners(context.Background(), 1, 1, false)
+	r.Equal(int64(1), repo.Stats().Local.Misses)
+}
+
+func (s *TieredCacheTestSuite) TestDeleteEvictsBothTiers() {
+	r := s.Require()
+	repo := s.newRepo(10, time.Minute)
+	r.NoError(repo.AddBanners(context.Background(), 1, 1, false, tieredBanners(1)))
+	r.NoError(repo.AddBanners(context.Background(), 1, 1, true, tieredBanners(1)))
+
+	r.NoError(repo.DeleteBanners(context.Background(), []models.FeatureTag{{FeatureId: 1, TagId: 1}}))
+
+	for _, admin := range []bool{false, true} {
+		_, err := repo.GetBanners(context.Background(), 1, 1, admin)
+		r.ErrorIs(err, redis.Nil)
+	}
+	r.Equal(int64(0), repo.Stats().Local.Size)
+}
+
+func (s *TieredCacheTestSuite) TestUpdatesOfOtherReplicaEvictLocal() {
+	r := s.Require()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reader, writer := s.newRepo(10, time.Minute), s.newRepo(10, time.Minute)
+	updates := reader.SubscribeUpdates(ctx)
+	r.NoError(reader.AddBanners(context.Background(), 1, 1, false, tieredBanners(1)))
+
+	// подписка устанавливается асинхронно, публикуем, пока читатель не получит сообщение
+	r.Eventually(func() bool {
+		r.NoError(writer.DeleteBanners(context.Background(), []models.FeatureTag{{FeatureId: 1, TagId: 1}}))
+		select {
+		case <-updates:
+			return true
+		case <-time.After(10 * time.Millisecond):
+			return false
+		}
+	}, time.Second, time.Millisecond)
+
+	_, err := reader.GetBanners(context.Background(), 1, 1, false)
+	r.ErrorIs(err, redis.Nil)
+}
+
+func (s *TieredCacheTestSuite) TestGetManyMergesTiers() {
+	r := s.Require()
+	repo := s.newRepo(10, time.Minute)
+	r.NoError(repo.AddBanners(context.Background(), 1, 1, false, tieredBanners(1)))
+	r.NoError(bannerRepo.NewCacheRepo(*s.client).AddBanners(context.Background(), 1, 2, false, tieredBanners(2)))
+
+	result, _, err := repo.GetManyBanners(context.Background(), []models.FeatureTag{{FeatureId: 1, TagId: 1}, {FeatureId: 1, TagId: 2}, {FeatureId: 1, TagId: 3}}, nil, false)
+	r.NoError(err)
+	r.Equal(int64(1), result[0][0].Id)
+	r.Equal(int64(2), result[1][0].Id)
+	r.Nil(result[2])
+	stats := repo.Stats()
+	r.Equal(int64(1), stats.Local.Hits)
+	r.Equal(int64(1), stats.Redis.Hits)
+	r.Equal(int64(1), stats.Redis.Misses)
+}
+
+func (s *TieredCacheTestSuite) TestConcurrentAccess() {
+	repo := s.newRepo(5, time.Minute)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				tagId := int64((i + j) % 10)
+				_ = repo.AddBanners(context.Background(), 1, tagId, false, tieredBanners(tagId))
+				result, err := repo.GetBanners(context.Background(), 1, tagId, false)
+				if err == nil && len(result) > 0 {
+					// копия из кеша не должна разделяться с другими запросами
+					result[0].Id = -1
+				}
+				if j%10 == 0 {
+					_ = repo.DeleteBanners(context.Background(), []models.FeatureTag{{FeatureId: 1, TagId: tagId}})
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	s.LessOrEqual(repo.Stats().Local.Size, int64(5))
+	for tagId := int64(0); tagId < 10; tagId++ {
+		result, err := repo.GetBanners(context.Background(), 1, tagId, false)
+		if err == nil {
+			s.Equal(tagId, result[0].Id)
+		}
+	}
+}
+
+func (s *TieredCacheTestSuite) TestGetManyReadsFallbacksWithPairs() {
+	r := s.Require()
+	repo := s.newRepo(10, time.Minute)
+	r.NoError(repo.AddFallbacks(context.Background(), false, map[int64]models.UserBanner{1: tieredBanners(5)[0]}))
+	r.NoError(bannerRepo.NewCacheRepo(*s.client).AddFallbacks(context.Background(), false, map[int64]models.UserBanner{2: tieredBanners(6)[0]}))
+	r.NoError(repo.AddBanners(context.Background(), 1, 1, false, []models.UserBanner{}))
+
+	banners, fallbacks, err := repo.GetManyBanners(context.Background(), []models.FeatureTag{{FeatureId: 1, TagId: 1}}, []int64{1, 2, 3}, false)
+	r.NoError(err)
+	r.NotNil(banners[0])
+	r.Empty(banners[0])
+	r.Equal(int64(5), fallbacks[0].Id)
+	r.Equal(int64(6), fallbacks[1].Id)
+	r.Nil(fallbacks[2])
+	stats := repo.Stats()
+	r.Equal(int64(2), stats.Local.Hits)
+	r.Equal(int64(1), stats.Redis.Hits)
+	r.Equal(int64(1), stats.Redis.Misses)
+}
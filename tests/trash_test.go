@@ -0,0 +1,116 @@
+package tests
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Alladan04/avito_test/internal/models"
+	"github.com/Alladan04/avito_test/internal/pkg/banner"
+	bannerWorker "github.com/Alladan04/avito_test/internal/pkg/banner/worker"
+	"github.com/stretchr/testify/suite"
+)
+
+// purgeRepoStub purges the trash in the given batches and records the deadlines it was asked for
+type purgeRepoStub struct {
+	banner.BannerRepo
+	mu        sync.Mutex
+	batches   []int64
+	deadlines []time.Time
+}
+
+func (r *purgeRepoStub) PurgeDeleted(ctx context.Context, before time.Time, limit int64) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.deadlines = append(r.deadlines, before)
+	if len(r.batches) == 0 {
+		return 0, nil
+	}
+	purged := min(r.batches[0], limit)
+	r.batches = r.batches[1:]
+	return purged, nil
+}
+
+func (r *purgeRepoStub) calls() []time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]time.Time(nil), r.deadlines...)
+}
+
+type TrashTestSuite struct {
+	suite.Suite
+}
+
+func TestTrashSuite(t *testing.T) {
+	suite.Run(t, new(TrashTestSuite))
+}
+
+func (s *TrashTestSuite) TestWorkerPurgesUntilTrashIsEmpty() {
+	r := s.Require()
+	repo := &purgeRepoStub{batches: []int64{100, 100, 30}}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go bannerWorker.NewPurgeWorker(repo, time.Hour).Run(ctx)
+
+	// неполная пачка значит, что корзина разобрана, до следующего тика воркер ждёт
+	r.Eventually(func() bool { return len(repo.calls()) == 3 }, time.Second, time.Millisecond)
+	r.Never(func() bool { return len(repo.calls()) > 3 }, 50*time.Millisecond, 5*time.Millisecond)
+	for _, deadline := range repo.calls() {
+		r.WithinDuration(time.Now().UTC().Add(-time.Hour), deadline, time.Second)
+	}
+}
+
+func (s *APITestSuite) TestRestoreConflictKeepsBannerInTrash() {
+	r := s.Require()
+	ctx := asAdmin("admin")
+	id, tagId := s.addPublishedBanner()
+	r.NoError(s.uc.DeleteBanner(ctx, id, nil))
+	// пока баннер в корзине, его пару занял другой
+	_, err := s.uc.AddItem(ctx, models.BannerForm{Content: models.BannerContent(`{"title": "replacement"}`), FeatureId: 1, TagIds: []int64{tagId}, IsActive: true})
+	r.NoError(err)
+
+	r.ErrorIs(s.uc.RestoreBanner(ctx, id), banner.ErrBannerConflict)
+	trashed, err := s.uc.GetBanner(ctx, id)
+	r.NoError(err)
+	r.NotNil(trashed.DeletedAt)
+}
+
+func (s *APITestSuite) TestRestoreBringsBannerBack() {
+	r := s.Require()
+	ctx := asAdmin("admin")
+	id, tagId := s.addPublishedBanner()
+	r.NoError(s.uc.DeleteBanner(ctx, id, nil))
+	_, err := s.uc.GetOne(ctx, 1, tagId, false)
+	r.Error(err)
+
+	r.NoError(s.uc.RestoreBanner(ctx, id))
+	result, err := s.uc.GetOne(ctx, 1, tagId, false)
+	r.NoError(err)
+	r.Equal(id, result.Id)
+	r.ErrorIs(s.uc.RestoreBanner(ctx, id), banner.ErrBannerNotFound)
+}
+
+func (s *APITestSuite) TestPurgeRemovesOnlyExpiredTrash() {
+	r := s.Require()
+	ctx := context.Background()
+	expired, _ := s.addPublishedBanner()
+	recent, _ := s.addPublishedBanner()
+	r.NoError(s.uc.DeleteBanner(ctx, expired, nil))
+	r.NoError(s.uc.DeleteBanner(ctx, recent, nil))
+	_, err := s.db.Exec(ctx, `UPDATE banner SET deleted_at = $1 WHERE id = $2;`, time.Now().UTC().Add(-48*time.Hour), expired)
+	r.NoError(err)
+
+	for {
+		purged, err := s.repo.PurgeDeleted(ctx, time.Now().UTC().Add(-24*time.Hour), 100)
+		r.NoError(err)
+		if purged < 100 {
+			break
+		}
+	}
+	var count int64
+	r.NoError(s.db.QueryRow(ctx, `SELECT count(*) FROM banner WHERE id = $1;`, expired).Scan(&count))
+	r.Zero(count)
+	r.NoError(s.db.QueryRow(ctx, `SELECT count(*) FROM banner WHERE id = $1;`, recent).Scan(&count))
+	r.Equal(int64(1), count)
+}
@@ -0,0 +1,85 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Alladan04/avito_test/internal/models"
+	bannerUsecase "github.com/Alladan04/avito_test/internal/pkg/banner/usecase"
+	"github.com/stretchr/testify/suite"
+)
+
+type UpdatesHubTestSuite struct {
+	suite.Suite
+
+	cache *cacheStub
+	hub   *bannerUsecase.UpdatesHub
+}
+
+func TestUpdatesHubSuite(t *testing.T) {
+	suite.Run(t, new(UpdatesHubTestSuite))
+}
+
+func (s *UpdatesHubTestSuite) SetupTest() {
+	s.cache = &cacheStub{updates: make(chan []models.FeatureTag)}
+	s.hub = bannerUsecase.NewUpdatesHub(s.cache)
+}
+
+func signalled(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	case <-time.After(time.Second):
+		return false
+	}
+}
+
+func (s *UpdatesHubTestSuite) TestNotifiesOnlyMatchingPair() {
+	r := s.Require()
+	go s.hub.Run(context.Background())
+	matching, unsubscribe := s.hub.Subscribe(models.FeatureTag{FeatureId: 1, TagId: 2})
+	defer unsubscribe()
+	other, unsubscribeOther := s.hub.Subscribe(models.FeatureTag{FeatureId: 1, TagId: 3})
+	defer unsubscribeOther()
+
+	s.cache.updates <- []models.FeatureTag{{FeatureId: 1, TagId: 2}, {FeatureId: 5, TagId: 5}}
+	r.True(signalled(matching))
+	select {
+	case <-other:
+		r.Fail("other pair was notified")
+	default:
+	}
+}
+
+func (s *UpdatesHubTestSuite) TestMergesUnreadUpdates() {
+	r := s.Require()
+	key := models.FeatureTag{FeatureId: 1, TagId: 2}
+	updates, unsubscribe := s.hub.Subscribe(key)
+	defer unsubscribe()
+
+	s.hub.Notify([]models.FeatureTag{key})
+	s.hub.Notify([]models.FeatureTag{key})
+	r.True(signalled(updates))
+	select {
+	case <-updates:
+		r.Fail("updates were not merged")
+	default:
+	}
+}
+
+func (s *UpdatesHubTestSuite) TestStopClosesStreams() {
+	r := s.Require()
+	updates, unsubscribe := s.hub.Subscribe(models.FeatureTag{FeatureId: 1, TagId: 2})
+	defer unsubscribe()
+	done := make(chan struct{})
+	go func() {
+		s.hub.Run(context.Background())
+		close(done)
+	}()
+
+	close(s.cache.updates)
+	<-done
+	_, ok := <-updates
+	r.False(ok)
+}
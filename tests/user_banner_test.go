@@ -13,7 +13,9 @@ import (
 	bannerDelivery "github.com/Alladan04/avito_test/internal/pkg/banner/delivery/http"
 	bannerRepo "github.com/Alladan04/avito_test/internal/pkg/banner/repo"
 	bannerUsecase "github.com/Alladan04/avito_test/internal/pkg/banner/usecase"
+	jobRepo "github.com/Alladan04/avito_test/internal/pkg/job/repo"
 	"github.com/Alladan04/avito_test/internal/pkg/middleware"
+	statsRepo "github.com/Alladan04/avito_test/internal/pkg/stats/repo"
 	"github.com/Alladan04/avito_test/internal/pkg/utils"
 	"github.com/gorilla/mux"
 	"github.com/jackc/pgx/v4"
@@ -88,7 +90,7 @@ func (s *APITestSuite) initDeps() {
 	// Init domain deps
 	repo := bannerRepo.NewBannerRepo(s.db, *s.conn)
 	cacherepo := bannerRepo.NewCacheRepo(*s.redisdb)
-	uc := bannerUsecase.NewBannerUsecase(repo, cacherepo)
+	uc := bannerUsecase.NewBannerUsecase(repo, cacherepo, jobRepo.NewJobRepo(s.db), bannerRepo.NewImpressionRepo(*s.redisdb), statsRepo.NewCounterRepo(*s.redisdb), nil)
 	h := bannerDelivery.NewBannerHandler(uc)
 	s.repo = repo
 	s.uc = uc
@@ -122,7 +124,7 @@ func (s *APITestSuite) TestUserBanner() {
 
 func (s *APITestSuite) populateDB() error {
 	const (
-		insertBanner  = "INSERT INTO banner (title, banner_data, feature_id, url, create_time, update_time, is_active) VALUES ('some title', 'some data', 1, 'some url', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, true);"
+		insertBanner  = `INSERT INTO banner (content, feature_id, create_time, update_time, is_active, status) VALUES ('{"title": "some title", "text": "some data", "url": "some url"}', 1, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, true, 'published');`
 		insertFeature = "INSERT INTO feature (id) VALUES (DEFAULT);"
 		insertTag     = "INSERT INTO tag(id) VALUES (DEFAULT);"
 		insertBT      = "INSERT INTO banner_tag (banner_id, tag_id,feature_id) VALUES (1,1,1);"
@@ -0,0 +1,141 @@
+package tests
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Alladan04/avito_test/internal/models"
+	webhookWorker "github.com/Alladan04/avito_test/internal/pkg/webhook/worker"
+	"github.com/stretchr/testify/suite"
+)
+
+type failedDelivery struct {
+	id          int64
+	nextAttempt time.Time
+	dead        bool
+}
+
+type webhookRepoStub struct {
+	due       []models.WebhookDelivery
+	delivered []int64
+	failed    []failedDelivery
+}
+
+func (r *webhookRepoStub) AddWebhook(ctx context.Context, item models.Webhook) (models.Webhook, error) {
+	return item, nil
+}
+
+func (r *webhookRepoStub) GetWebhooks(ctx context.Context) ([]models.Webhook, error) {
+	return nil, nil
+}
+
+func (r *webhookRepoStub) DeleteWebhook(ctx context.Context, id int64) error {
+	return nil
+}
+
+func (r *webhookRepoStub) GetDeadLetters(ctx context.Context, count int64, offset int64) ([]models.WebhookDelivery, error) {
+	return nil, nil
+}
+
+func (r *webhookRepoStub) ClaimDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int64) ([]models.WebhookDelivery, error) {
+	result := r.due
+	r.due = nil
+	return result, nil
+}
+
+func (r *webhookRepoStub) MarkDelivered(ctx context.Context, id int64) error {
+	r.delivered = append(r.delivered, id)
+	return nil
+}
+
+func (r *webhookRepoStub) MarkFailed(ctx context.Context, id int64, lastError string, nextAttempt time.Time, dead bool) error {
+	r.failed = append(r.failed, failedDelivery{id: id, nextAttempt: nextAttempt, dead: dead})
+	return nil
+}
+
+type WebhookTestSuite struct {
+	suite.Suite
+
+	repo   *webhookRepoStub
+	server *httptest.Server
+	worker *webhookWorker.DeliveryWorker
+	// тело и подпись последнего запроса, статус ответа задаёт тест
+	body      []byte
+	signature string
+	status    int
+}
+
+func TestWebhookSuite(t *testing.T) {
+	suite.Run(t, new(WebhookTestSuite))
+}
+
+func (s *WebhookTestSuite) SetupTest() {
+	s.status = http.StatusOK
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.body, _ = io.ReadAll(r.Body)
+		s.signature = r.Header.Get(webhookWorker.SignatureHeader)
+		w.WriteHeader(s.status)
+	}))
+	s.repo = &webhookRepoStub{}
+	s.worker = webhookWorker.NewDeliveryWorker(s.repo, s.server.Client())
+}
+
+func (s *WebhookTestSuite) TearDownTest() {
+	s.server.Close()
+}
+
+func (s *WebhookTestSuite) delivery(id int64, attempts int64) models.WebhookDelivery {
+	return models.WebhookDelivery{
+		Id:       id,
+		Url:      s.server.URL,
+		Secret:   "secret",
+		Event:    models.WebhookEventBannerUpdated,
+		Payload:  []byte(`{"event":"banner.updated","banner_id":1}`),
+		Attempts: attempts,
+	}
+}
+
+func (s *WebhookTestSuite) TestDeliverySigned() {
+	r := s.Require()
+	s.repo.due = []models.WebhookDelivery{s.delivery(1, 0)}
+
+	s.worker.Deliver(context.Background())
+	r.Equal([]int64{1}, s.repo.delivered)
+	r.Empty(s.repo.failed)
+	r.Equal(webhookWorker.Signature("secret", s.body), s.signature)
+}
+
+func (s *WebhookTestSuite) TestFailedDeliveryRetried() {
+	r := s.Require()
+	s.status = http.StatusInternalServerError
+	s.repo.due = []models.WebhookDelivery{s.delivery(1, 2)}
+
+	before := time.Now().UTC()
+	s.worker.Deliver(context.Background())
+	r.Empty(s.repo.delivered)
+	r.Len(s.repo.failed, 1)
+	r.False(s.repo.failed[0].dead)
+	r.WithinDuration(before.Add(webhookWorker.Backoff(3)), s.repo.failed[0].nextAttempt, time.Second)
+}
+
+func (s *WebhookTestSuite) TestLastAttemptGoesToDeadLetters() {
+	r := s.Require()
+	s.status = http.StatusBadGateway
+	s.repo.due = []models.WebhookDelivery{s.delivery(1, webhookWorker.MaxAttempts-1)}
+
+	s.worker.Deliver(context.Background())
+	r.Len(s.repo.failed, 1)
+	r.True(s.repo.failed[0].dead)
+}
+
+func (s *WebhookTestSuite) TestBackoffGrows() {
+	r := s.Require()
+
+	r.Equal(30*time.Second, webhookWorker.Backoff(1))
+	r.Equal(time.Minute, webhookWorker.Backoff(2))
+	r.Equal(6*time.Hour, webhookWorker.Backoff(20))
+}
@@ -0,0 +1,168 @@
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Alladan04/avito_test/internal/models"
+	"github.com/Alladan04/avito_test/internal/pkg/workflow"
+	workflowUsecase "github.com/Alladan04/avito_test/internal/pkg/workflow/usecase"
+	"github.com/stretchr/testify/suite"
+)
+
+type workflowRepoStub struct {
+	status  string
+	history []models.BannerTransition
+}
+
+func (r *workflowRepoStub) GetStatus(ctx context.Context, bannerId int64) (string, error) {
+	return r.status, nil
+}
+
+func (r *workflowRepoStub) GetTransitions(ctx context.Context, bannerId int64) ([]models.BannerTransition, error) {
+	return r.history, nil
+}
+
+func (r *workflowRepoStub) AddTransition(ctx context.Context, transition models.BannerTransition) ([]models.FeatureTag, error) {
+	r.status = transition.ToStatus
+	r.history = append(r.history, transition)
+	return []models.FeatureTag{{FeatureId: 1, TagId: 1}}, nil
+}
+
+type cacheStub struct {
+	deleted []models.FeatureTag
+	updates chan []models.FeatureTag
+}
+
+func (c *cacheStub) GetBanners(ctx context.Context, featureId int64, tagId int64, admin bool) ([]models.UserBanner, error) {
+	return nil, nil
+}
+
+func (c *cacheStub) AddBanners(ctx context.Context, featureId int64, tagId int64, admin bool, banners []models.UserBanner) error {
+	return nil
+}
+
+func (c *cacheStub) GetManyBanners(ctx context.Context, keys []models.FeatureTag, featureIds []int64, admin bool) ([][]models.UserBanner, []*models.UserBanner, error) {
+	return make([][]models.UserBanner, len(keys)), make([]*models.UserBanner, len(featureIds)), nil
+}
+
+func (c *cacheStub) AddManyBanners(ctx context.Context, admin bool, banners map[models.FeatureTag][]models.UserBanner) error {
+	return nil
+}
+
+func (c *cacheStub) GetFallbacks(ctx context.Context, featureIds []int64, admin bool) ([]*models.UserBanner, error) {
+	return make([]*models.UserBanner, len(featureIds)), nil
+}
+
+func (c *cacheStub) AddFallbacks(ctx context.Context, admin bool, banners map[int64]models.UserBanner) error {
+	return nil
+}
+
+func (c *cacheStub) DeleteFallback(ctx context.Context, featureId int64) error {
+	return nil
+}
+
+func (c *cacheStub) SubscribeUpdates(ctx context.Context) <-chan []models.FeatureTag {
+	return c.updates
+}
+
+func (c *cacheStub) AddNotFound(ctx context.Context, featureId int64, tagId int64, admin bool) error {
+	return nil
+}
+
+func (c *cacheStub) IsNotFound(ctx context.Context, featureId int64, tagId int64, admin bool) (bool, error) {
+	return false, nil
+}
+
+func (c *cacheStub) DeleteNotFound(ctx context.Context, keys []models.FeatureTag) error {
+	return nil
+}
+
+func (c *cacheStub) DeleteBanners(ctx context.Context, keys []models.FeatureTag) error {
+	c.deleted = append(c.deleted, keys...)
+	return nil
+}
+
+type WorkflowTestSuite struct {
+	suite.Suite
+
+	repo  *workflowRepoStub
+	cache *cacheStub
+	uc    *workflowUsecase.WorkflowUsecase
+}
+
+func TestWorkflowSuite(t *testing.T) {
+	suite.Run(t, new(WorkflowTestSuite))
+}
+
+func (s *WorkflowTestSuite) SetupTest() {
+	s.repo = &workflowRepoStub{status: models.BannerStatusDraft}
+	s.cache = &cacheStub{}
+	s.uc = workflowUsecase.NewWorkflowUsecase(s.repo, s.cache)
+}
+
+func asAdmin(username string) context.Context {
+	return context.WithValue(context.Background(), models.PayloadContextKey, models.JwtPayload{Username: username, IsAdmin: true})
+}
+
+func (s *WorkflowTestSuite) TestFullLifecycle() {
+	r := s.Require()
+	steps := []struct {
+		actor  string
+		action string
+		status string
+	}{
+		{"author", models.WorkflowActionSubmit, models.BannerStatusInReview},
+		{"reviewer", models.WorkflowActionApprove, models.BannerStatusApproved},
+		{"author", models.WorkflowActionPublish, models.BannerStatusPublished},
+		{"author", models.WorkflowActionArchive, models.BannerStatusArchived},
+	}
+	for _, step := range steps {
+		result, err := s.uc.Apply(asAdmin(step.actor), 1, step.action)
+		r.NoError(err, step.action)
+		r.Equal(step.status, result.ToStatus)
+		r.Equal(step.actor, result.Actor)
+	}
+	r.Len(s.repo.history, len(steps))
+	// кеш чистится при публикации и при архивации
+	r.Len(s.cache.deleted, 2)
+}
+
+func (s *WorkflowTestSuite) TestSelfApprovalIsForbidden() {
+	r := s.Require()
+	_, err := s.uc.Apply(asAdmin("author"), 1, models.WorkflowActionSubmit)
+	r.NoError(err)
+
+	_, err = s.uc.Apply(asAdmin("author"), 1, models.WorkflowActionApprove)
+	r.ErrorIs(err, workflow.ErrSelfApproval)
+	r.Equal(models.BannerStatusInReview, s.repo.status)
+}
+
+func (s *WorkflowTestSuite) TestApprovalChecksLatestSubmitter() {
+	r := s.Require()
+	for _, step := range []struct{ actor, action string }{
+		{"author", models.WorkflowActionSubmit},
+		{"reviewer", models.WorkflowActionReject},
+		{"reviewer", models.WorkflowActionSubmit},
+	} {
+		_, err := s.uc.Apply(asAdmin(step.actor), 1, step.action)
+		r.NoError(err)
+	}
+
+	_, err := s.uc.Apply(asAdmin("reviewer"), 1, models.WorkflowActionApprove)
+	r.ErrorIs(err, workflow.ErrSelfApproval)
+	_, err = s.uc.Apply(asAdmin("author"), 1, models.WorkflowActionApprove)
+	r.NoError(err)
+}
+
+func (s *WorkflowTestSuite) TestInvalidTransitions() {
+	r := s.Require()
+	_, err := s.uc.Apply(asAdmin("author"), 1, models.WorkflowActionPublish)
+	r.ErrorIs(err, workflow.ErrInvalidTransition)
+
+	_, err = s.uc.Apply(asAdmin("author"), 1, "delete")
+	r.ErrorIs(err, workflow.ErrUnknownAction)
+
+	r.Equal(models.BannerStatusDraft, s.repo.status)
+	r.Empty(s.repo.history)
+}